@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/ranking"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/service"
+	zlog "github.com/mislu/market-api/internal/utils/log"
+)
+
+var (
+	judgementFile = flag.String("judgements", "judgements.json", `judgement list: {"query": {"productID": grade}}`)
+	configFiles   = flag.String("configs", "", "comma separated ranking weight files (json), default weights are always included")
+	k             = flag.Int("k", 10, "evaluation cutoff")
+	logged        = flag.Int("logged", 200, "replay the most searched logged queries, 0 to use every judged query")
+	days          = flag.Int("days", 30, "look back window of logged queries")
+	verbose       = flag.Bool("v", false, "print per query scores")
+)
+
+type configuration struct {
+	name    string
+	weights ranking.Weights
+}
+
+func main() {
+	flag.Parse()
+
+	judgements, err := loadJudgements(*judgementFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	configs, err := loadConfigurations(*configFiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db.Init(zlog.NewLogger())
+	es.Init()
+
+	queries, err := selectQueries(judgements)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(queries) == 0 {
+		log.Fatal("no logged query has judgements")
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "config\tqueries\tNDCG@%d\tMRR@%d\n", *k, *k)
+	for _, config := range configs {
+		var ndcg, mrr float64
+		for _, query := range queries {
+			ranked, err := service.RankProducts(query, config.weights, *k)
+			if err != nil {
+				log.Fatalf("search %q with %s: %v", query, config.name, err)
+			}
+
+			queryNDCG := ranking.NDCG(ranked, judgements[query], *k)
+			queryMRR := ranking.ReciprocalRank(ranked, judgements[query], *k)
+			ndcg += queryNDCG
+			mrr += queryMRR
+
+			if *verbose {
+				fmt.Fprintf(writer, "  %s\t%q\t%.4f\t%.4f\n", config.name, query, queryNDCG, queryMRR)
+			}
+		}
+
+		count := float64(len(queries))
+		fmt.Fprintf(writer, "%s\t%d\t%.4f\t%.4f\n", config.name, len(queries), ndcg/count, mrr/count)
+	}
+	writer.Flush()
+}
+
+func loadJudgements(path string) (ranking.Judgements, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw ranking.Judgements
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse judgements: %w", err)
+	}
+
+	// 与搜索历史的归一化关键词对齐
+	judgements := make(ranking.Judgements, len(raw))
+	for query, grades := range raw {
+		judgements[strings.Join(strings.Fields(strings.ToLower(query)), " ")] = grades
+	}
+
+	return judgements, nil
+}
+
+func loadConfigurations(files string) ([]configuration, error) {
+	configs := []configuration{{name: "default", weights: ranking.DefaultWeights()}}
+	if len(files) == 0 {
+		return configs, nil
+	}
+
+	for _, file := range strings.Split(files, ",") {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		weights := ranking.DefaultWeights()
+		if err := json.Unmarshal(data, &weights); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+
+		configs = append(configs, configuration{
+			name:    strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+			weights: weights,
+		})
+	}
+
+	return configs, nil
+}
+
+// selectQueries 回放搜索日志中有评判的查询，logged为0时使用全部评判查询
+func selectQueries(judgements ranking.Judgements) ([]string, error) {
+	if *logged == 0 {
+		queries := make([]string, 0, len(judgements))
+		for query := range judgements {
+			queries = append(queries, query)
+		}
+		return queries, nil
+	}
+
+	loggedQueries, err := service.LoggedQueries(time.Now().AddDate(0, 0, -*days), *logged)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := make([]string, 0, len(loggedQueries))
+	for _, query := range loggedQueries {
+		if _, ok := judgements[query]; ok {
+			queries = append(queries, query)
+		}
+	}
+
+	return queries, nil
+}
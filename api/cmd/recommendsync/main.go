@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/mislu/market-api/internal/core/recommend"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/service"
+	zlog "github.com/mislu/market-api/internal/utils/log"
+)
+
+var (
+	task      = flag.String("task", "all", "task to run: items|users|clean|all")
+	batchSize = flag.Int("batch", 500, "rows per batch")
+)
+
+// 同步推荐服务的数据：items 回填所有商品，users 回填所有用户及兴趣标签，
+// clean 删除早期选择兴趣标签时写入的虚拟商品，all 依次执行以上任务
+func main() {
+	flag.Parse()
+
+	db.Init(zlog.NewLogger())
+	es.Init()
+	recommend.InitGlobalWorker(service.NewLocalRecommender())
+
+	tasks := []string{*task}
+	if *task == "all" {
+		tasks = []string{"items", "users", "clean"}
+	}
+
+	for _, t := range tasks {
+		switch t {
+		case "items":
+			total, err := service.BackfillRecommendItems(*batchSize)
+			if err != nil {
+				log.Fatalf("items stopped after %d products: %v", total, err)
+			}
+			log.Printf("synced %d products", total)
+		case "users":
+			total, err := service.BackfillRecommendUsers(*batchSize)
+			if err != nil {
+				log.Fatalf("users stopped after %d users: %v", total, err)
+			}
+			log.Printf("synced %d users", total)
+		case "clean":
+			deleted, err := service.CleanAllFakeRecommendItems(*batchSize)
+			if err != nil {
+				log.Fatalf("clean stopped after deleting %d items: %v", deleted, err)
+			}
+			log.Printf("deleted %d fake items", deleted)
+		default:
+			log.Fatalf("unknown task %s", t)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/service"
+	zlog "github.com/mislu/market-api/internal/utils/log"
+)
+
+// state 重建进度，中断后通过 -resume 从上次的位置继续
+type state struct {
+	Target    string    `json:"target"`
+	Index     string    `json:"index"`
+	LastID    string    `json:"lastID"`
+	StartedAt time.Time `json:"startedAt"`
+	OutboxID  uint      `json:"outboxID"` // 开始时最新的索引事件，切换别名前重放之后的事件
+}
+
+var (
+	target    = flag.String("target", "product", "index to rebuild: product|message")
+	batchSize = flag.Int("batch", 500, "documents per bulk request")
+	resume    = flag.Bool("resume", false, "resume from the state file")
+	stateFile = flag.String("state", "reindex.state.json", "checkpoint file")
+	deleteOld = flag.Bool("delete-old", false, "delete the indices previously behind the alias")
+)
+
+func main() {
+	flag.Parse()
+
+	db.Init(zlog.NewLogger())
+	es.Init()
+
+	if !es.IsElasticsearch() {
+		log.Fatal("reindex only applies to elasticsearch, the embedded engine backfills empty indices at startup")
+	}
+
+	var (
+		alias       string
+		reindex     func(index string, afterID string, batchSize int, checkpoint func(string) error) (int, error)
+		reindexFrom func(index string, since time.Time, batchSize int) (int, error)
+	)
+	switch *target {
+	case "product":
+		alias = es.ProductIndex
+		reindex = service.ReindexProducts
+		reindexFrom = service.ReindexProductsUpdatedSince
+	case "message":
+		alias = es.MessageIndex
+		reindex = service.ReindexMessages
+		reindexFrom = service.ReindexMessagesUpdatedSince
+	default:
+		log.Fatalf("unknown target %s", *target)
+	}
+
+	current, err := prepare(alias)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("rebuilding [%s] into [%s] from id %q", alias, current.Index, current.LastID)
+	total, err := reindex(current.Index, current.LastID, *batchSize, func(lastID string) error {
+		current.LastID = lastID
+		return saveState(current)
+	})
+	if err != nil {
+		log.Fatalf("reindex stopped at id %q, rerun with -resume: %v", current.LastID, err)
+	}
+	log.Printf("indexed %d documents", total)
+
+	// 补齐重建期间的变更，留一分钟余量覆盖时钟误差
+	caught, err := reindexFrom(current.Index, current.StartedAt.Add(-time.Minute), *batchSize)
+	if err != nil {
+		log.Fatalf("catch up failed, rerun with -resume: %v", err)
+	}
+	log.Printf("caught up %d changed documents", caught)
+
+	// 重放重建期间的商品事件，补齐收藏数、卖家信息和删除等不改变updated_at的变更
+	replayed := current.OutboxID
+	if *target == "product" {
+		var n int
+		replayed, n, err = service.ReplayIndexEvents(current.Index, replayed, *batchSize)
+		if err != nil {
+			log.Fatalf("replay index events failed, rerun with -resume: %v", err)
+		}
+		log.Printf("replayed %d index events", n)
+	}
+
+	if err := es.FinishIndex(current.Index); err != nil {
+		log.Fatal(err)
+	}
+
+	old, err := es.SwapAlias(alias, current.Index)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 切换前最后一次重放之后的事件可能已写入旧索引，切换后再重放一次
+	if *target == "product" {
+		if _, n, err := service.ReplayIndexEvents(current.Index, replayed, *batchSize); err != nil {
+			log.Printf("failed to replay index events after swap, the drift check will repair them: %v", err)
+		} else if n > 0 {
+			log.Printf("replayed %d index events after swap", n)
+		}
+	}
+
+	if *deleteOld {
+		if err := es.DeleteIndices(old); err != nil {
+			log.Printf("failed to delete old indices %v: %v", old, err)
+		}
+	} else if len(old) > 0 {
+		log.Printf("old indices kept: %v", old)
+	}
+
+	if err := os.Remove(*stateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("failed to remove state file: %v", err)
+	}
+}
+
+// prepare 恢复上次的进度或创建新的版本化索引
+func prepare(alias string) (*state, error) {
+	if *resume {
+		data, err := os.ReadFile(*stateFile)
+		if err != nil {
+			return nil, fmt.Errorf("read state: %w", err)
+		}
+
+		s := &state{}
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("parse state: %w", err)
+		}
+		if s.Target != *target {
+			return nil, fmt.Errorf("state file belongs to target %s", s.Target)
+		}
+		return s, nil
+	}
+
+	outboxID, err := service.LatestIndexOutboxID()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := es.CreateVersionedIndex(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &state{
+		Target:    *target,
+		Index:     index,
+		StartedAt: time.Now(),
+		OutboxID:  outboxID,
+	}
+	return s, saveState(s)
+}
+
+func saveState(s *state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(*stateFile, data, 0o644)
+}
@@ -0,0 +1,57 @@
+package experiment
+
+import (
+	"hash/fnv"
+)
+
+// Bucket 实验分组，Weight为流量占比，Strategy为该组使用的策略
+type Bucket struct {
+	Name     string
+	Weight   int
+	Strategy string
+}
+
+// Experiment 按用户id把流量稳定地分配到各分组，同一用户在同一实验中总是落在同一组
+type Experiment struct {
+	Name    string
+	Buckets []Bucket
+}
+
+// Assign 对实验名和用户id做哈希，按权重选择分组；没有有效分组时返回空分组
+func (e Experiment) Assign(unitID string) Bucket {
+	total := 0
+	for _, bucket := range e.Buckets {
+		if bucket.Weight > 0 {
+			total += bucket.Weight
+		}
+	}
+	if total == 0 {
+		return Bucket{}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{':'})
+	h.Write([]byte(unitID))
+	slot := int(h.Sum32() % uint32(total))
+
+	for _, bucket := range e.Buckets {
+		if bucket.Weight <= 0 {
+			continue
+		}
+		if slot < bucket.Weight {
+			return bucket
+		}
+		slot -= bucket.Weight
+	}
+
+	return Bucket{}
+}
+
+// Rate 计算比率，分母为0时返回0
+func Rate(numerator int64, denominator int64) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
@@ -0,0 +1,44 @@
+package experiment
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestAssignIsDeterministicAndWeighted(t *testing.T) {
+	e := Experiment{
+		Name: "feed",
+		Buckets: []Bucket{
+			{Name: "control", Weight: 1, Strategy: "latest"},
+			{Name: "treatment", Weight: 3, Strategy: "recommend"},
+			{Name: "disabled", Weight: 0, Strategy: "local"},
+		},
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		bucket := e.Assign(user)
+		if again := e.Assign(user); again != bucket {
+			t.Fatalf("user %s assigned to %s then %s", user, bucket.Name, again.Name)
+		}
+		counts[bucket.Name]++
+	}
+
+	if counts["disabled"] != 0 {
+		t.Errorf("zero-weight bucket got %d users", counts["disabled"])
+	}
+	if share := float64(counts["treatment"]) / 10000; math.Abs(share-0.75) > 0.03 {
+		t.Errorf("treatment share = %.3f, want about 0.75", share)
+	}
+}
+
+func TestAssignWithoutBuckets(t *testing.T) {
+	if bucket := (Experiment{Name: "feed"}).Assign("u1"); bucket != (Bucket{}) {
+		t.Errorf("Assign = %+v, want empty bucket", bucket)
+	}
+	if Rate(1, 0) != 0 || Rate(1, 4) != 0.25 {
+		t.Error("unexpected rate")
+	}
+}
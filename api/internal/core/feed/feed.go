@@ -0,0 +1,44 @@
+package feed
+
+// Blend 按推荐顺序取size个商品，每exploreEvery个位置插入一个新发布的商品用于探索，
+// 推荐用完后用新发布的商品和最新商品补齐；已看过和重复的商品会被跳过
+func Blend(recommended []string, fresh []string, latest []string, seen map[string]bool, size int, exploreEvery int) []string {
+	picked := make(map[string]bool, size)
+	result := make([]string, 0, size)
+
+	var ri, fi, li int
+	next := func(list []string, i *int) (string, bool) {
+		for ; *i < len(list); *i++ {
+			id := list[*i]
+			if !seen[id] && !picked[id] {
+				*i++
+				return id, true
+			}
+		}
+		return "", false
+	}
+
+	for len(result) < size {
+		id, ok := "", false
+		if exploreEvery > 0 && (len(result)+1)%exploreEvery == 0 {
+			id, ok = next(fresh, &fi)
+		}
+		if !ok {
+			id, ok = next(recommended, &ri)
+		}
+		if !ok {
+			id, ok = next(fresh, &fi)
+		}
+		if !ok {
+			id, ok = next(latest, &li)
+		}
+		if !ok {
+			break
+		}
+
+		picked[id] = true
+		result = append(result, id)
+	}
+
+	return result
+}
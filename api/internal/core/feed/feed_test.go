@@ -0,0 +1,45 @@
+package feed
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBlend(t *testing.T) {
+	recommended := []string{"r1", "r2", "seen", "r3", "r4"}
+	fresh := []string{"f1", "r2", "f2"}
+	latest := []string{"f1", "l1", "l2"}
+	seen := map[string]bool{"seen": true}
+
+	got := Blend(recommended, fresh, latest, seen, 4, 3)
+	if want := []string{"r1", "r2", "f1", "r3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Blend = %v, want %v", got, want)
+	}
+
+	// 推荐用完后依次用新发布和最新商品补齐
+	got = Blend([]string{"r1"}, fresh, latest, seen, 6, 0)
+	if want := []string{"r1", "f1", "r2", "f2", "l1", "l2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Blend = %v, want %v", got, want)
+	}
+}
+
+func TestStoreExpires(t *testing.T) {
+	store := NewStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	store.Put("a", Session{Seen: []string{"1"}})
+	if session, ok := store.Get("a"); !ok || !reflect.DeepEqual(session.Seen, []string{"1"}) {
+		t.Fatalf("Get = %v, %v", session, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := store.Get("a"); ok {
+		t.Error("session should expire")
+	}
+	store.Put("b", Session{})
+	if len(store.sessions) != 1 {
+		t.Errorf("expired sessions should be removed, got %d", len(store.sessions))
+	}
+}
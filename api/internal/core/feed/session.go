@@ -0,0 +1,62 @@
+package feed
+
+import (
+	"sync"
+	"time"
+)
+
+// Session 一次连续浏览首页的状态，推荐列表在会话开始时生成，翻页时保持不变
+type Session struct {
+	Recommended []string
+	Seen        []string
+	Bucket      string // 会话开始时用户所在的实验分组
+	expireAt    time.Time
+}
+
+// Store 保存在内存中的浏览会话，超过ttl未访问的会话会被清理
+type Store struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]Session
+	nextScan time.Time // 下次清理过期会话的时间
+	now      func() time.Time
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:      ttl,
+		sessions: make(map[string]Session),
+		now:      time.Now,
+	}
+}
+
+func (s *Store) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.now().After(session.expireAt) {
+		return Session{}, false
+	}
+
+	return session, true
+}
+
+// Put 保存会话，每个ttl周期顺带清理一次过期的会话，分摊到每次请求的开销为常数
+func (s *Store) Put(id string, session Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.nextScan) {
+		for key, existing := range s.sessions {
+			if now.After(existing.expireAt) {
+				delete(s.sessions, key)
+			}
+		}
+		s.nextScan = now.Add(s.ttl)
+	}
+
+	session.expireAt = now.Add(s.ttl)
+	s.sessions[id] = session
+}
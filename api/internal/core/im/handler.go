@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/mislu/market-api/internal/core/notify"
 	"github.com/mislu/market-api/internal/types/request"
 )
 
@@ -25,6 +26,9 @@ type Client struct {
 	userID    string
 	pendingMu sync.Mutex                 // 队列锁
 	pending   map[string]*PendingMessage // 消息ID->消息
+
+	typingSentAt map[string]time.Time // 对方userID->上次转发typingStart的时间，仅在readPump中访问
+	limiter      *tokenBucket         // 连接级限流
 }
 
 // 待确认消息结构
@@ -68,6 +72,9 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		send:    make(chan request.Message, 256),
 		userID:  userID,
 		pending: make(map[string]*PendingMessage, 0),
+
+		typingSentAt: make(map[string]time.Time),
+		limiter:      newTokenBucket(connRate, connBurst),
 	}
 
 	// 添加客户端到管理
@@ -118,6 +125,12 @@ func (c *Client) readPump() {
 				continue
 			}
 
+			if !c.limiter.allow() || (msg.Type == send && !allowSend(c.userID)) {
+				log.Printf("用户 %s 发送过于频繁，丢弃消息", c.userID)
+				handleFail(c, &msg)
+				continue
+			}
+
 			c.handleMessage(&msg)
 		} else {
 			log.Printf("用户 %s 收到非文本消息 (类型 %d)，暂不支持", c.userID, messageType)
@@ -161,6 +174,7 @@ func (c *Client) writePump() {
 
 func Init() {
 	http.HandleFunc("/api/im/ws", HandleWebSocket)
+	notify.SetRealtimePusher(pushNotification)
 
 	server := &http.Server{
 		Addr:    ":3300",
@@ -1,11 +1,14 @@
 package im
 
 import (
+	"encoding/json"
 	"log"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/mislu/market-api/internal/service"
+	"github.com/mislu/market-api/internal/types/models"
 	"github.com/mislu/market-api/internal/types/request"
 )
 
@@ -14,13 +17,26 @@ const (
 	ack
 	fail
 	withdraw
+	edit
+	read
+	typingStart
+	typingStop
+	system // 系统通知，content为通知的json
 )
 
+// 同一会话中typingStart最多每typingThrottle转发一次
+const typingThrottle = 3 * time.Second
+
 func (c *Client) handleMessage(message *request.Message) {
 	log.Printf("read message from %s\n", c.userID)
 	switch message.Type {
 	case send:
-		service.SaveMessage(message)
+		if err := service.SaveMessage(message); err != nil {
+			log.Printf("用户 %s 的消息保存失败: %v", c.userID, err)
+			handleFail(c, message)
+			return
+		}
+
 		err := sendMessage(message)
 		ackMessage(*message)
 		if err != nil {
@@ -30,9 +46,87 @@ func (c *Client) handleMessage(message *request.Message) {
 	case ack:
 
 	case withdraw:
-		message.ID = message.Content
-		sendMessage(message)
+		// 撤回消息的content为被撤回消息的id
+		recalled, err := service.RecallMessage(c.userID, message.Content)
+		if err != nil {
+			handleFail(c, message)
+			return
+		}
+
+		message.ID = recalled.ID
+		message.From = recalled.FromUserID
+		message.To = recalled.ToUserID
+		message.Content = ""
+		notifyMessage(message.To, *message)
+		ackMessage(*message)
+	case edit:
+		edited, err := service.EditMessage(c.userID, message.ID, message.Content)
+		if err != nil {
+			handleFail(c, message)
+			return
+		}
+
+		message.From = edited.FromUserID
+		message.To = edited.ToUserID
+		message.Content = edited.Content
+		notifyMessage(message.To, *message)
+		ackMessage(*message)
+	case read:
+		// 已读回执的id为已读的最后一条消息
+		message.From = c.userID
+		advanced, err := service.MarkMessagesRead(c.userID, message.To, message.ID)
+		if err != nil {
+			handleFail(c, message)
+			return
+		}
+
+		if advanced {
+			notifyMessage(message.To, *message)
+		}
+	case typingStart:
+		message.From = c.userID
+		if last, ok := c.typingSentAt[message.To]; ok && time.Since(last) < typingThrottle {
+			return
+		}
+
+		c.typingSentAt[message.To] = time.Now()
+		notifyMessage(message.To, *message)
+	case typingStop:
+		message.From = c.userID
+		if _, ok := c.typingSentAt[message.To]; !ok {
+			return
+		}
+
+		delete(c.typingSentAt, message.To)
+		notifyMessage(message.To, *message)
+	}
+}
+
+// pushNotification 将通知中心的通知以系统消息推送给在线用户
+func pushNotification(userID string, notification models.Notification) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("通知 %d 序列化失败: %v", notification.ID, err)
+		return
+	}
+
+	notifyMessage(userID, request.Message{
+		ID:      strconv.FormatInt(notification.ID, 10),
+		From:    "system",
+		To:      userID,
+		Content: string(data),
+		Type:    system,
+	})
+}
+
+// notifyMessage 向在线用户推送消息，离线用户在拉取消息列表时获取最新状态
+func notifyMessage(userID string, message request.Message) {
+	client, online := getClient(userID)
+	if !online {
+		return
 	}
+
+	client.send <- message
 }
 
 func handleRetry(client *Client, msgID string) {
@@ -65,8 +159,7 @@ func handleRetry(client *Client, msgID string) {
 func sendMessage(message *request.Message) error {
 	toClient, online := getClient(message.To)
 	if !online {
-		// handle offline
-		service.RecordLastReadMessage(message.From, message.To, message.ID)
+		// 离线消息已落盘，上线后通过未读数和消息列表获取
 		log.Println("用户下线", toClient)
 		return nil
 	}
@@ -0,0 +1,91 @@
+package im
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// 单个连接的所有帧
+	connRate  = 10.0
+	connBurst = 20.0
+
+	// 单个用户发送的消息，跨连接累计
+	userSendRate  = 30.0 / 60
+	userSendBurst = 10.0
+
+	// 超过该时长未使用的用户令牌桶会被清理
+	userBucketIdle = 10 * time.Minute
+)
+
+// tokenBucket 令牌桶，rate为每秒补充的令牌数
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) idleSince(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last)
+}
+
+var (
+	userBuckets   = make(map[string]*tokenBucket) // userID -> 发送令牌桶
+	userBucketsMu sync.Mutex
+)
+
+// allowSend 用户级发送限流，重连不会重置配额
+func allowSend(userID string) bool {
+	userBucketsMu.Lock()
+	bucket, ok := userBuckets[userID]
+	if !ok {
+		pruneUserBuckets()
+		bucket = newTokenBucket(userSendRate, userSendBurst)
+		userBuckets[userID] = bucket
+	}
+	userBucketsMu.Unlock()
+
+	return bucket.allow()
+}
+
+// pruneUserBuckets 调用方需持有userBucketsMu
+func pruneUserBuckets() {
+	now := time.Now()
+	for userID, bucket := range userBuckets {
+		if bucket.idleSince(now) > userBucketIdle {
+			delete(userBuckets, userID)
+		}
+	}
+}
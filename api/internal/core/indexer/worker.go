@@ -0,0 +1,131 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/mq"
+	"github.com/mislu/market-api/internal/core/mq/memory"
+)
+
+type EventType string
+
+const (
+	ProductUpserted EventType = "product_upserted" // 商品新增或任意字段变化
+	ProductDeleted  EventType = "product_deleted"
+	SellerUpdated   EventType = "seller_updated" // 卖家昵称、头像或信誉变化
+
+	// 以下事件在同步es后还需匹配用户保存的搜索
+	ProductListed       EventType = "product_listed"
+	ProductPriceDropped EventType = "product_price_dropped"
+)
+
+const (
+	queueSize      = 1024
+	maxRetries     = 3
+	publishTimeout = time.Second
+)
+
+// Event 只携带id，处理时从MySQL重新读取最新数据，重复投递不影响结果
+type Event struct {
+	Type     EventType `json:"type"`
+	ID       string    `json:"id"`                 // 商品id或卖家id
+	OutboxID uint      `json:"outboxID,omitempty"` // 对应的outbox记录，处理成功后删除
+}
+
+// Handler 处理索引事件，由service注册
+type Handler func(ctx context.Context, event Event) error
+
+var GlobalWorker *Worker
+
+// Worker 消费商品变更事件并同步到es
+type Worker struct {
+	queue   mq.Queue
+	handler Handler
+}
+
+func NewWorker(queue mq.Queue, handler Handler) *Worker {
+	return &Worker{
+		queue:   queue,
+		handler: handler,
+	}
+}
+
+func InitGlobalWorker(handler Handler) {
+	GlobalWorker = NewWorker(memory.NewInMemoryQueue(queueSize), handler)
+	go GlobalWorker.Work(context.Background())
+}
+
+// Publish 发布索引事件，worker未初始化时丢弃，由outbox重投
+func Publish(event Event) {
+	if GlobalWorker == nil {
+		return
+	}
+
+	if err := GlobalWorker.Publish(event); err != nil {
+		log.Printf("索引事件 %s(%s) 发布失败: %v", event.Type, event.ID, err)
+	}
+}
+
+func (w *Worker) Publish(event Event) error {
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	return w.queue.Publish(ctx, mq.Message{
+		ID:      fmt.Sprintf("%s-%s-%d", event.Type, event.ID, event.OutboxID),
+		Content: content,
+	})
+}
+
+func (w *Worker) Work(ctx context.Context) error {
+	msgChan, err := w.queue.Consume(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start consumer: %w", err)
+	}
+
+	for {
+		select {
+		case msg, ok := <-msgChan:
+			if !ok {
+				log.Println("Index consumer channel closed")
+				return nil
+			}
+
+			if err := w.process(ctx, msg); err != nil {
+				log.Printf("Failed to process index event %s: %v", msg.ID, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, msg mq.Message) error {
+	var event Event
+	if err := json.Unmarshal(msg.Content, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if err = w.handler(ctx, event); err == nil {
+			return nil
+		}
+
+		select {
+		case <-time.After(time.Second * time.Duration(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
+}
@@ -0,0 +1,199 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	_ "image/gif"
+)
+
+const (
+	ThumbnailMaxEdge  = 240
+	CompressedMaxEdge = 1280
+	compressedQuality = 75
+	MaxImagePixels    = 40_000_000
+)
+
+var ErrImageTooLarge = errors.New("media: image dimensions exceed limit")
+
+// ImageVariants 图片的派生版本，均为jpeg编码
+type ImageVariants struct {
+	Width      int
+	Height     int
+	Thumbnail  []byte
+	Compressed []byte
+}
+
+// ProcessImage 生成缩略图和压缩图，无法解码的格式(如webp)返回ok=false
+func ProcessImage(data []byte) (variants ImageVariants, ok bool, err error) {
+	// 先读取声明的尺寸，避免小文件声明超大尺寸时解码占用大量内存
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if err == image.ErrFormat {
+			return variants, false, nil
+		}
+		return variants, false, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return variants, false, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if err == image.ErrFormat {
+			return variants, false, nil
+		}
+		return variants, false, err
+	}
+
+	bounds := img.Bounds()
+	variants.Width = bounds.Dx()
+	variants.Height = bounds.Dy()
+
+	variants.Thumbnail, err = encodeJPEG(resize(img, ThumbnailMaxEdge), compressedQuality)
+	if err != nil {
+		return variants, false, err
+	}
+
+	variants.Compressed, err = encodeJPEG(resize(img, CompressedMaxEdge), compressedQuality)
+	if err != nil {
+		return variants, false, err
+	}
+
+	return variants, true, nil
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resize 等比缩放到最长边不超过maxEdge，使用区域平均采样
+func resize(src image.Image, maxEdge int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return src
+	}
+
+	dw, dh := maxEdge, h*maxEdge/w
+	if h > w {
+		dw, dh = w*maxEdge/h, maxEdge
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0 := bounds.Min.Y + y*h/dh
+		sy1 := max(bounds.Min.Y+(y+1)*h/dh, sy0+1)
+		for x := 0; x < dw; x++ {
+			sx0 := bounds.Min.X + x*w/dw
+			sx1 := max(bounds.Min.X+(x+1)*w/dw, sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
+
+// StripMetadata 去除jpeg/png中的EXIF等元数据(含GPS信息)，其他格式原样返回
+func StripMetadata(data []byte, mime string) []byte {
+	switch mime {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	default:
+		return data
+	}
+}
+
+// stripJPEG 移除APP1(EXIF/XMP)、APP13(IPTC)和COM段，其余段保持不变
+func stripJPEG(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return data
+		}
+
+		marker := data[i+1]
+		// SOS之后为压缩数据，直接拷贝剩余部分
+		if marker == 0xDA {
+			return append(out, data[i:]...)
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return data
+		}
+
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	return data
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// stripPNG 移除eXIf和文本类chunk
+func stripPNG(data []byte) []byte {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return data
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	i := len(pngSignature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return data
+		}
+
+		chunk := data[i:end]
+		switch string(chunk[4:8]) {
+		case "eXIf", "tEXt", "iTXt", "zTXt":
+		default:
+			out = append(out, chunk...)
+		}
+		i = end
+	}
+
+	if _, err := png.DecodeConfig(bytes.NewReader(out)); err != nil {
+		return data
+	}
+	return out
+}
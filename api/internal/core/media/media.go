@@ -0,0 +1,52 @@
+package media
+
+import (
+	"errors"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	CategoryImage = "image"
+	CategoryVideo = "video"
+)
+
+var ErrUnsupportedType = errors.New("media: unsupported file type")
+
+// 允许上传的MIME类型 -> 分类
+var mimeToCategory = map[string]string{
+	"image/jpeg": CategoryImage,
+	"image/png":  CategoryImage,
+	"image/gif":  CategoryImage,
+	"image/webp": CategoryImage,
+	"image/bmp":  CategoryImage,
+
+	"video/mp4":        CategoryVideo,
+	"video/quicktime":  CategoryVideo,
+	"video/webm":       CategoryVideo,
+	"video/x-matroska": CategoryVideo,
+	"video/x-msvideo":  CategoryVideo,
+}
+
+// Detected 文件内容嗅探结果
+type Detected struct {
+	MIME     string
+	Ext      string // 带"."的扩展名
+	Category string
+}
+
+// Sniff 根据文件内容而不是扩展名判断文件类型
+func Sniff(data []byte) (Detected, error) {
+	mtype := mimetype.Detect(data)
+	for m := mtype; m != nil; m = m.Parent() {
+		if category, ok := mimeToCategory[m.String()]; ok {
+			return Detected{
+				MIME:     m.String(),
+				Ext:      m.Extension(),
+				Category: category,
+			}, nil
+		}
+	}
+
+	return Detected{MIME: mtype.String()}, ErrUnsupportedType
+}
@@ -0,0 +1,143 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func pngChunk(typ string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk[:4], uint32(len(payload)))
+	copy(chunk[4:8], typ)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestSniffIgnoresExtension(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(4, 4)))
+
+	detected, err := Sniff(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, "image/png", detected.MIME)
+	require.Equal(t, CategoryImage, detected.Category)
+
+	_, err = Sniff([]byte("#!/bin/sh\nrm -rf /\n"))
+	require.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, testImage(8, 8), nil))
+	raw := buf.Bytes()
+
+	exif := append([]byte("Exif\x00\x00"), []byte("GPS-SECRET")...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(exif)+2))
+	app1 = append(app1, exif...)
+
+	withExif := append(append(append([]byte{}, raw[:2]...), app1...), raw[2:]...)
+	stripped := StripMetadata(withExif, "image/jpeg")
+
+	require.False(t, bytes.Contains(stripped, []byte("GPS-SECRET")))
+	require.Equal(t, raw, stripped)
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(4, 4)))
+	raw := buf.Bytes()
+
+	// 在IHDR之后插入文本chunk
+	ihdrEnd := len(pngSignature) + 12 + 13
+	withText := append(append(append([]byte{}, raw[:ihdrEnd]...), pngChunk("tEXt", []byte("Comment\x00secret"))...), raw[ihdrEnd:]...)
+
+	stripped := StripMetadata(withText, "image/png")
+	require.Equal(t, raw, stripped)
+}
+
+func TestProcessImage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(1600, 800)))
+
+	variants, ok, err := ProcessImage(buf.Bytes())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1600, variants.Width)
+	require.Equal(t, 800, variants.Height)
+
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(variants.Thumbnail))
+	require.NoError(t, err)
+	require.Equal(t, ThumbnailMaxEdge, thumb.Width)
+	require.Equal(t, ThumbnailMaxEdge/2, thumb.Height)
+
+	compressed, err := jpeg.DecodeConfig(bytes.NewReader(variants.Compressed))
+	require.NoError(t, err)
+	require.Equal(t, CompressedMaxEdge, compressed.Width)
+}
+
+func TestProcessImageRejectsDecompressionBomb(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(4, 4)))
+
+	// 改写IHDR中声明的尺寸并重新计算校验和
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:20], 50000)
+	binary.BigEndian.PutUint32(data[20:24], 50000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	_, _, err := ProcessImage(data)
+	require.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(b[:4], uint32(8+len(content)))
+	copy(b[4:], typ)
+	return append(b, content...)
+}
+
+func TestProbeVideoMalformedLargeSize(t *testing.T) {
+	// size为1表示使用64位的largesize，这里的值与偏移相加会溢出
+	largesize := make([]byte, 8)
+	binary.BigEndian.PutUint64(largesize, ^uint64(0)-7)
+	data := append(box("ftyp", []byte("isom")), append([]byte{0, 0, 0, 1, 'm', 'o', 'o', 'v'}, largesize...)...)
+
+	_, err := ProbeVideo(data, "video/mp4")
+	require.ErrorIs(t, err, ErrDurationUnknown)
+}
+
+func TestProbeVideoDuration(t *testing.T) {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:16], 1000)  // timescale
+	binary.BigEndian.PutUint32(mvhd[16:20], 90500) // duration
+
+	data := append(box("ftyp", []byte("isom")), box("moov", box("mvhd", mvhd))...)
+
+	info, err := ProbeVideo(data, "video/mp4")
+	require.NoError(t, err)
+	require.Equal(t, 90500*time.Millisecond, info.Duration)
+
+	_, err = ProbeVideo(data, "video/webm")
+	require.ErrorIs(t, err, ErrDurationUnknown)
+}
@@ -0,0 +1,122 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var ErrDurationUnknown = errors.New("media: video duration unknown")
+
+// VideoInfo 视频元信息
+type VideoInfo struct {
+	Duration time.Duration
+}
+
+// PosterExtractor 抽取视频封面帧，返回jpeg数据
+type PosterExtractor interface {
+	ExtractPoster(data []byte, mime string) ([]byte, error)
+}
+
+type noopPosterExtractor struct{}
+
+func (noopPosterExtractor) ExtractPoster([]byte, string) ([]byte, error) {
+	return nil, nil
+}
+
+var posterExtractor PosterExtractor = noopPosterExtractor{}
+
+// SetPosterExtractor 注册封面抽取实现(如基于ffmpeg)，默认不抽取
+func SetPosterExtractor(extractor PosterExtractor) {
+	if extractor == nil {
+		extractor = noopPosterExtractor{}
+	}
+	posterExtractor = extractor
+}
+
+// ExtractPoster 使用已注册的实现抽取封面，未注册时返回nil
+func ExtractPoster(data []byte, mime string) ([]byte, error) {
+	return posterExtractor.ExtractPoster(data, mime)
+}
+
+// ProbeVideo 读取视频时长，目前支持mp4/mov(ISO BMFF)容器，其他格式返回ErrDurationUnknown
+func ProbeVideo(data []byte, mime string) (VideoInfo, error) {
+	switch mime {
+	case "video/mp4", "video/quicktime":
+		moov, ok := findBox(data, "moov")
+		if !ok {
+			return VideoInfo{}, ErrDurationUnknown
+		}
+
+		mvhd, ok := findBox(moov, "mvhd")
+		if !ok {
+			return VideoInfo{}, ErrDurationUnknown
+		}
+
+		return parseMvhd(mvhd)
+	default:
+		return VideoInfo{}, ErrDurationUnknown
+	}
+}
+
+// findBox 在同一层级中查找指定类型的box，返回box的内容(不含头部)
+func findBox(data []byte, typ string) ([]byte, bool) {
+	for i := 0; i+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[i : i+4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - i)
+		case 1:
+			if i+16 > len(data) {
+				return nil, false
+			}
+			size = binary.BigEndian.Uint64(data[i+8 : i+16])
+			header = 16
+		}
+
+		// 与剩余字节数比较，避免64位的largesize相加后溢出
+		if size < header || size > uint64(len(data)-i) {
+			return nil, false
+		}
+
+		if string(data[i+4:i+8]) == typ {
+			return data[i+int(header) : i+int(size)], true
+		}
+		i += int(size)
+	}
+
+	return nil, false
+}
+
+func parseMvhd(mvhd []byte) (VideoInfo, error) {
+	if len(mvhd) < 4 {
+		return VideoInfo{}, ErrDurationUnknown
+	}
+
+	var timescale, duration uint64
+	switch mvhd[0] {
+	case 0:
+		if len(mvhd) < 20 {
+			return VideoInfo{}, ErrDurationUnknown
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	case 1:
+		if len(mvhd) < 32 {
+			return VideoInfo{}, ErrDurationUnknown
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	default:
+		return VideoInfo{}, ErrDurationUnknown
+	}
+
+	if timescale == 0 {
+		return VideoInfo{}, ErrDurationUnknown
+	}
+
+	return VideoInfo{
+		Duration: time.Duration(float64(duration) / float64(timescale) * float64(time.Second)),
+	}, nil
+}
@@ -0,0 +1,133 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Action int
+
+const (
+	Pass   Action = iota // 放行
+	Mask                 // 替换敏感内容后放行
+	Reject               // 拒绝发送
+)
+
+// Result 内容审核结果
+type Result struct {
+	Action  Action
+	Content string // Mask时为替换后的内容
+	Reason  string
+}
+
+// Filter 内容过滤器
+type Filter interface {
+	Check(content string) Result
+}
+
+// Chain 依次执行过滤器，遇到Reject立即返回，Mask的结果传递给下一个过滤器
+type Chain []Filter
+
+func (c Chain) Check(content string) Result {
+	result := Result{Action: Pass, Content: content}
+	for _, filter := range c {
+		r := filter.Check(result.Content)
+		switch r.Action {
+		case Reject:
+			return r
+		case Mask:
+			result = r
+		}
+	}
+	return result
+}
+
+// KeywordFilter 将关键词替换为*
+type KeywordFilter struct {
+	replacer *strings.Replacer
+}
+
+func NewKeywordFilter(keywords []string) *KeywordFilter {
+	pairs := make([]string, 0, len(keywords)*2)
+	for _, keyword := range keywords {
+		if len(keyword) == 0 {
+			continue
+		}
+		pairs = append(pairs, keyword, strings.Repeat("*", len([]rune(keyword))))
+	}
+
+	return &KeywordFilter{replacer: strings.NewReplacer(pairs...)}
+}
+
+func (f *KeywordFilter) Check(content string) Result {
+	masked := f.replacer.Replace(content)
+	if masked == content {
+		return Result{Action: Pass, Content: content}
+	}
+	return Result{Action: Mask, Content: masked, Reason: "keyword"}
+}
+
+// RegexFilter 将匹配的内容替换为*
+type RegexFilter struct {
+	patterns []*regexp.Regexp
+}
+
+func NewRegexFilter(patterns []string) (*RegexFilter, error) {
+	filter := &RegexFilter{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.patterns = append(filter.patterns, re)
+	}
+	return filter, nil
+}
+
+func (f *RegexFilter) Check(content string) Result {
+	masked := content
+	for _, re := range f.patterns {
+		masked = re.ReplaceAllStringFunc(masked, func(s string) string {
+			return strings.Repeat("*", len([]rune(s)))
+		})
+	}
+
+	if masked == content {
+		return Result{Action: Pass, Content: content}
+	}
+	return Result{Action: Mask, Content: masked, Reason: "pattern"}
+}
+
+var (
+	// 手机号，允许数字间夹杂空格和横线
+	phonePattern = regexp.MustCompile(`1[3-9](?:[\s-]?\d){9}`)
+	// 微信号，需要有"微信"等提示词在前，避免误伤普通英文
+	wechatPattern = regexp.MustCompile(`(?i)(?:微信|威信|薇信|v信|vx|wx|weixin|wechat|加v)[\s:：号是为]*[a-z][-_a-z0-9]{5,19}`)
+	qqPattern     = regexp.MustCompile(`(?i)(?:qq|扣扣)[\s:：号是为]*\d{5,11}`)
+)
+
+// ContactFilter 拦截手机号、微信号、QQ号等联系方式，防止站外交易
+type ContactFilter struct{}
+
+func (ContactFilter) Check(content string) Result {
+	normalized := toHalfWidth(content)
+	for _, re := range []*regexp.Regexp{phonePattern, wechatPattern, qqPattern} {
+		if re.MatchString(normalized) {
+			return Result{Action: Reject, Content: content, Reason: "contact"}
+		}
+	}
+	return Result{Action: Pass, Content: content}
+}
+
+// toHalfWidth 将全角字符转换为半角，避免用全角数字绕过检测
+func toHalfWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,49 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContactFilter(t *testing.T) {
+	rejected := []string{
+		"加我13812345678细聊",
+		"电话 138 1234 5678",
+		"１３８１２３４５６７８",
+		"vx: abc_12345",
+		"我的微信号是wxid_abcdef",
+		"QQ 123456789",
+	}
+	for _, content := range rejected {
+		require.Equal(t, Reject, ContactFilter{}.Check(content).Action, content)
+	}
+
+	passed := []string{
+		"还在吗？100元可以吗",
+		"成色九成新，用了2个月",
+		"型号 iPhone 13 Pro",
+	}
+	for _, content := range passed {
+		require.Equal(t, Pass, ContactFilter{}.Check(content).Action, content)
+	}
+}
+
+func TestChain(t *testing.T) {
+	regexFilter, err := NewRegexFilter([]string{`\d{4}-\d{4}`})
+	require.NoError(t, err)
+
+	chain := Chain{NewKeywordFilter([]string{"傻瓜"}), regexFilter, ContactFilter{}}
+
+	result := chain.Check("你这个傻瓜，卡号1234-5678")
+	require.Equal(t, Mask, result.Action)
+	require.Equal(t, "你这个**，卡号*********", result.Content)
+
+	result = chain.Check("傻瓜，加微信 abcdefg")
+	require.Equal(t, Reject, result.Action)
+	require.Equal(t, "contact", result.Reason)
+
+	result = chain.Check("你好")
+	require.Equal(t, Pass, result.Action)
+	require.Equal(t, "你好", result.Content)
+}
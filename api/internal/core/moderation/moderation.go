@@ -0,0 +1,34 @@
+package moderation
+
+import (
+	"github.com/mislu/market-api/internal/utils/app"
+)
+
+var globalFilter Filter = Chain{ContactFilter{}}
+
+// Init 根据配置构建全局过滤器
+func Init() error {
+	config := app.GetConfig().Moderation
+
+	regexFilter, err := NewRegexFilter(config.Patterns)
+	if err != nil {
+		return err
+	}
+
+	chain := Chain{NewKeywordFilter(config.Keywords), regexFilter}
+	if !config.AllowContact {
+		chain = append(chain, ContactFilter{})
+	}
+
+	globalFilter = chain
+	return nil
+}
+
+// SetFilter 替换全局过滤器，用于接入第三方审核服务
+func SetFilter(filter Filter) {
+	globalFilter = filter
+}
+
+func Check(content string) Result {
+	return globalFilter.Check(content)
+}
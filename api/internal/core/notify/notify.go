@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/mislu/market-api/internal/types/models"
+)
+
+// Outbound 发往站外渠道(短信/邮件/推送)的通知
+type Outbound struct {
+	UserID  string
+	Phone   string
+	Type    string
+	Title   string
+	Content string
+}
+
+// Channel 站外通知渠道
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Outbound) error
+}
+
+// LogChannel 仅打印日志，用于本地开发
+type LogChannel struct{}
+
+func (LogChannel) Name() string {
+	return "log"
+}
+
+func (LogChannel) Send(_ context.Context, msg Outbound) error {
+	log.Printf("[notify] to %s(%s) %s: %s", msg.UserID, msg.Phone, msg.Title, msg.Content)
+	return nil
+}
+
+var (
+	channels   = []Channel{LogChannel{}}
+	channelsMu sync.RWMutex
+
+	realtimePusher func(userID string, notification models.Notification)
+)
+
+// SetChannels 替换站外通知渠道
+func SetChannels(chs ...Channel) {
+	channelsMu.Lock()
+	defer channelsMu.Unlock()
+	channels = chs
+}
+
+// Dispatch 依次投递到所有站外渠道，单个渠道失败不影响其他渠道
+func Dispatch(ctx context.Context, msg Outbound) {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+
+	for _, ch := range channels {
+		if err := ch.Send(ctx, msg); err != nil {
+			log.Printf("通知渠道 %s 发送失败: %v", ch.Name(), err)
+		}
+	}
+}
+
+// SetRealtimePusher 由im模块注册，用于通过WebSocket实时推送
+func SetRealtimePusher(pusher func(userID string, notification models.Notification)) {
+	realtimePusher = pusher
+}
+
+func PushRealtime(userID string, notification models.Notification) {
+	if realtimePusher == nil {
+		return
+	}
+
+	realtimePusher(userID, notification)
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"text/template"
+)
+
+const (
+	TypeOrderCreated = "order_created"
+	TypeOrderPaid    = "order_paid"
+	TypeOrderShipped = "order_shipped"
+	TypeOrderDone    = "order_done"
+	TypeComment      = "comment"
+	TypeLike         = "like"
+	TypeOffer        = "offer" // 收藏的商品降价
+
+	TypeSavedSearch       = "saved_search"        // 保存的搜索有新匹配
+	TypeSavedSearchDigest = "saved_search_digest" // 保存的搜索每日汇总
+)
+
+// Types 所有通知类型，用于展示偏好设置
+var Types = []string{
+	TypeOrderCreated,
+	TypeOrderPaid,
+	TypeOrderShipped,
+	TypeOrderDone,
+	TypeComment,
+	TypeLike,
+	TypeOffer,
+	TypeSavedSearch,
+	TypeSavedSearchDigest,
+}
+
+var ErrUnknownType = errors.New("unknown notification type")
+
+type messageTemplate struct {
+	title   string
+	content *template.Template
+}
+
+func newTemplate(title, content string) messageTemplate {
+	return messageTemplate{
+		title:   title,
+		content: template.Must(template.New(title).Parse(content)),
+	}
+}
+
+var templates = map[string]messageTemplate{
+	TypeOrderCreated: newTemplate("新订单", "你的商品「{{.product}}」已被拍下，等待买家付款"),
+	TypeOrderPaid:    newTemplate("买家已付款", "你的商品「{{.product}}」买家已付款，请尽快发货"),
+	TypeOrderShipped: newTemplate("订单已发货", "你购买的「{{.product}}」已发货，请注意查收"),
+	TypeOrderDone:    newTemplate("交易完成", "买家已确认收到「{{.product}}」，交易完成"),
+	TypeComment:      newTemplate("收到新评价", "{{.username}} 评价了「{{.product}}」：{{.comment}}"),
+	TypeLike:         newTemplate("商品被收藏", "{{.username}} 收藏了你的商品「{{.product}}」"),
+	TypeOffer:        newTemplate("收藏的商品降价了", "你收藏的「{{.product}}」降价至 ¥{{.price}}"),
+
+	TypeSavedSearch: newTemplate("订阅的搜索有新商品",
+		`「{{.name}}」{{if eq .reason "price_drop"}}有商品降价{{else}}有新商品{{end}}：{{.product}} ¥{{.price}}`),
+	TypeSavedSearchDigest: newTemplate("订阅的搜索汇总", "「{{.name}}」有 {{.count}} 件新匹配的商品"),
+}
+
+// Render 渲染通知标题和内容
+func Render(typ string, data map[string]any) (string, string, error) {
+	tmpl, ok := templates[typ]
+	if !ok {
+		return "", "", ErrUnknownType
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.content.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+
+	return tmpl.title, buf.String(), nil
+}
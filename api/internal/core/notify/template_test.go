@@ -0,0 +1,39 @@
+package notify
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	title, content, err := Render(TypeOffer, map[string]any{"product": "Switch", "price": 1200.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if title != "收藏的商品降价了" || content != "你收藏的「Switch」降价至 ¥1200.5" {
+		t.Errorf("unexpected render result: %s %s", title, content)
+	}
+
+	if _, _, err := Render("unknown", nil); err != ErrUnknownType {
+		t.Errorf("expected ErrUnknownType, got %v", err)
+	}
+}
+
+func TestTemplatesCoverTypes(t *testing.T) {
+	for _, typ := range Types {
+		if _, ok := templates[typ]; !ok {
+			t.Errorf("missing template for %s", typ)
+		}
+	}
+}
+
+func TestRenderSavedSearch(t *testing.T) {
+	_, content, err := Render(TypeSavedSearch, map[string]any{
+		"name": "switch", "reason": "price_drop", "product": "Switch OLED", "price": 1500.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content != "「switch」有商品降价：Switch OLED ¥1500" {
+		t.Errorf("unexpected render result: %s", content)
+	}
+}
@@ -0,0 +1,55 @@
+package ranking
+
+import (
+	"math"
+	"sort"
+)
+
+// Judgements 查询 -> 商品id -> 相关等级，0为不相关
+type Judgements map[string]map[string]int
+
+// NDCG 前k个结果的归一化折损累计增益，没有相关商品时返回0
+func NDCG(ranked []string, grades map[string]int, k int) float64 {
+	ideal := make([]int, 0, len(grades))
+	for _, grade := range grades {
+		if grade > 0 {
+			ideal = append(ideal, grade)
+		}
+	}
+	if len(ideal) == 0 {
+		return 0
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ideal)))
+
+	idealDCG := dcg(ideal, k)
+	gains := make([]int, 0, len(ranked))
+	for _, id := range ranked {
+		gains = append(gains, grades[id])
+	}
+
+	return dcg(gains, k) / idealDCG
+}
+
+func dcg(gains []int, k int) float64 {
+	score := 0.0
+	for i, gain := range gains {
+		if i >= k {
+			break
+		}
+		score += (math.Pow(2, float64(gain)) - 1) / math.Log2(float64(i+2))
+	}
+	return score
+}
+
+// ReciprocalRank 第一个相关结果排名的倒数，前k个中没有相关结果时返回0
+func ReciprocalRank(ranked []string, grades map[string]int, k int) float64 {
+	for i, id := range ranked {
+		if i >= k {
+			break
+		}
+		if grades[id] > 0 {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
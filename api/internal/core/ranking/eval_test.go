@@ -0,0 +1,48 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNDCG(t *testing.T) {
+	grades := map[string]int{"a": 3, "b": 2, "c": 0}
+
+	if score := NDCG([]string{"a", "b", "c"}, grades, 3); math.Abs(score-1) > 1e-9 {
+		t.Errorf("ideal ranking should score 1, got %f", score)
+	}
+
+	reversed := NDCG([]string{"c", "b", "a"}, grades, 3)
+	if reversed >= 1 || reversed <= 0 {
+		t.Errorf("reversed ranking should score between 0 and 1, got %f", reversed)
+	}
+
+	if score := NDCG([]string{"a"}, map[string]int{"x": 0}, 3); score != 0 {
+		t.Errorf("no relevant items should score 0, got %f", score)
+	}
+}
+
+func TestReciprocalRank(t *testing.T) {
+	grades := map[string]int{"b": 1}
+
+	if rr := ReciprocalRank([]string{"a", "b"}, grades, 10); rr != 0.5 {
+		t.Errorf("expected 0.5, got %f", rr)
+	}
+	if rr := ReciprocalRank([]string{"a", "b"}, grades, 1); rr != 0 {
+		t.Errorf("expected 0 outside cutoff, got %f", rr)
+	}
+}
+
+func TestFunctionScoreSkipsDisabledSignals(t *testing.T) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+
+	if wrapped := FunctionScore(query, Weights{}, nil); wrapped["match_all"] == nil {
+		t.Errorf("query should be unchanged when all weights are zero")
+	}
+
+	wrapped := FunctionScore(query, Weights{Likes: 1, Distance: 1}, nil)
+	functions := wrapped["function_score"].(map[string]interface{})["functions"].([]map[string]interface{})
+	if len(functions) != 1 {
+		t.Errorf("distance without origin should be skipped, got %d functions", len(functions))
+	}
+}
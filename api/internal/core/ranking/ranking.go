@@ -0,0 +1,121 @@
+package ranking
+
+import "fmt"
+
+// Weights 各排序信号在相关性之外的加分权重，为0时不使用该信号
+type Weights struct {
+	Freshness      float64 `json:"freshness"`      // 发布时间衰减
+	FreshnessScale string  `json:"freshnessScale"` // 分数衰减到一半的时长，如7d
+	Reputation     float64 `json:"reputation"`     // 卖家信誉
+	Likes          float64 `json:"likes"`          // 收藏数
+	Views          float64 `json:"views"`          // 浏览数
+	Discount       float64 `json:"discount"`       // 相对原价的折扣
+	Distance       float64 `json:"distance"`       // 与搜索位置的距离衰减，仅附近搜索
+}
+
+func DefaultWeights() Weights {
+	return Weights{
+		Freshness:      1,
+		FreshnessScale: "7d",
+		Reputation:     0.5,
+		Likes:          0.5,
+		Views:          0.2,
+		Discount:       0.5,
+		Distance:       1,
+	}
+}
+
+// Origin 距离衰减的中心点，Scale为分数衰减到一半的距离，公里
+type Origin struct {
+	Lat   float64
+	Lon   float64
+	Scale float64
+}
+
+// discountScript 折扣比例 (原价 - 现价) / 原价，原价缺失或不高于现价时为0
+const discountScript = `double o = doc['original_price'].size() == 0 ? 0 : doc['original_price'].value;
+double p = doc['price'].size() == 0 ? 0 : doc['price'].value;
+return o > p && o > 0 ? (o - p) / o : 0;`
+
+// FunctionScore 用function_score包装query，各信号的得分按权重与文本相关性相加
+func FunctionScore(query map[string]interface{}, weights Weights, origin *Origin) map[string]interface{} {
+	functions := []map[string]interface{}{}
+
+	if weights.Freshness > 0 {
+		scale := weights.FreshnessScale
+		if len(scale) == 0 {
+			scale = DefaultWeights().FreshnessScale
+		}
+		functions = append(functions, map[string]interface{}{
+			"gauss": map[string]interface{}{
+				"publish_at": map[string]interface{}{
+					"origin": "now",
+					"scale":  scale,
+					"decay":  0.5,
+				},
+			},
+			"weight": weights.Freshness,
+		})
+	}
+
+	for _, signal := range []struct {
+		field  string
+		weight float64
+	}{
+		{"seller_reputation", weights.Reputation},
+		{"like_count", weights.Likes},
+		{"view_count", weights.Views},
+	} {
+		if signal.weight <= 0 {
+			continue
+		}
+		functions = append(functions, map[string]interface{}{
+			"field_value_factor": map[string]interface{}{
+				"field":    signal.field,
+				"modifier": "log1p",
+				"missing":  0,
+			},
+			"weight": signal.weight,
+		})
+	}
+
+	if weights.Discount > 0 {
+		functions = append(functions, map[string]interface{}{
+			"script_score": map[string]interface{}{
+				"script": map[string]interface{}{
+					"source": discountScript,
+				},
+			},
+			"weight": weights.Discount,
+		})
+	}
+
+	if weights.Distance > 0 && origin != nil {
+		functions = append(functions, map[string]interface{}{
+			"gauss": map[string]interface{}{
+				"geo": map[string]interface{}{
+					"origin": map[string]interface{}{
+						"lat": origin.Lat,
+						"lon": origin.Lon,
+					},
+					"scale": fmt.Sprintf("%gkm", origin.Scale),
+					"decay": 0.5,
+				},
+			},
+			"weight": weights.Distance,
+		})
+	}
+
+	if len(functions) == 0 {
+		return query
+	}
+
+	return map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query":      query,
+			"functions":  functions,
+			"score_mode": "sum",
+			"boost_mode": "sum",
+		},
+	}
+}
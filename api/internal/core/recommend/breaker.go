@@ -0,0 +1,92 @@
+package recommend
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker 主推荐连续失败达到阈值后熔断，冷却期内直接使用兜底推荐，
+// 冷却结束后放行一个请求探测主推荐是否恢复；主推荐没有结果时也使用兜底推荐
+type CircuitBreaker struct {
+	primary   Recommender
+	fallback  Recommender
+	threshold int
+	cooldown  time.Duration
+	timeout   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(primary Recommender, fallback Recommender, threshold int, cooldown time.Duration, timeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		primary:   primary,
+		fallback:  fallback,
+		threshold: threshold,
+		cooldown:  cooldown,
+		timeout:   timeout,
+		now:       time.Now,
+	}
+}
+
+func (b *CircuitBreaker) Recommend(ctx context.Context, userID string, n int) ([]string, error) {
+	if b.allow() {
+		primaryCtx, cancel := context.WithTimeout(ctx, b.timeout)
+		ids, err := b.primary.Recommend(primaryCtx, userID, n)
+		cancel()
+
+		b.record(err)
+		if err == nil && len(ids) > 0 {
+			return ids, nil
+		}
+		if err != nil {
+			log.Printf("primary recommender failed, using fallback: %v", err)
+		}
+	}
+
+	return b.fallback.Recommend(ctx, userID, n)
+}
+
+// Open 是否处于熔断状态
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures >= b.threshold
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,65 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type stubRecommender struct {
+	ids   []string
+	err   error
+	calls int
+}
+
+func (s *stubRecommender) Recommend(_ context.Context, _ string, _ int) ([]string, error) {
+	s.calls++
+	return s.ids, s.err
+}
+
+func TestCircuitBreakerOpensAfterFailures(t *testing.T) {
+	primary := &stubRecommender{err: errors.New("unavailable")}
+	fallback := &stubRecommender{ids: []string{"popular"}}
+	breaker := NewCircuitBreaker(primary, fallback, 2, time.Minute, time.Second)
+
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ids, err := breaker.Recommend(context.Background(), "u1", 10)
+		if err != nil || !reflect.DeepEqual(ids, []string{"popular"}) {
+			t.Fatalf("call %d: got %v, %v", i, ids, err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary should not be called while open, calls = %d", primary.calls)
+	}
+	if !breaker.Open() {
+		t.Error("breaker should be open")
+	}
+
+	// 冷却结束后探测成功，恢复使用主推荐
+	now = now.Add(2 * time.Minute)
+	primary.err = nil
+	primary.ids = []string{"personal"}
+	ids, _ := breaker.Recommend(context.Background(), "u1", 10)
+	if !reflect.DeepEqual(ids, []string{"personal"}) || breaker.Open() {
+		t.Errorf("breaker should close after a successful probe, got %v", ids)
+	}
+}
+
+func TestCircuitBreakerFallsBackOnEmptyResult(t *testing.T) {
+	primary := &stubRecommender{}
+	fallback := &stubRecommender{ids: []string{"popular"}}
+	breaker := NewCircuitBreaker(primary, fallback, 1, time.Minute, time.Second)
+
+	ids, err := breaker.Recommend(context.Background(), "", 10)
+	if err != nil || !reflect.DeepEqual(ids, []string{"popular"}) {
+		t.Fatalf("got %v, %v", ids, err)
+	}
+	if breaker.Open() {
+		t.Error("an empty result should not count as a failure")
+	}
+}
@@ -0,0 +1,30 @@
+package recommend
+
+import (
+	"context"
+
+	"github.com/zhenghaoz/gorse/client"
+)
+
+// Recommender 为用户生成推荐的商品id，按推荐程度从高到低
+type Recommender interface {
+	Recommend(ctx context.Context, userID string, n int) ([]string, error)
+}
+
+// GorseRecommender 使用Gorse的个性化推荐
+type GorseRecommender struct {
+	client *client.GorseClient
+}
+
+func NewGorseRecommender(gorseClient *client.GorseClient) *GorseRecommender {
+	return &GorseRecommender{client: gorseClient}
+}
+
+// Recommend 未登录用户没有推荐结果，交给兜底推荐
+func (r *GorseRecommender) Recommend(ctx context.Context, userID string, n int) ([]string, error) {
+	if len(userID) == 0 {
+		return nil, nil
+	}
+
+	return r.client.GetRecommend(ctx, userID, "", n)
+}
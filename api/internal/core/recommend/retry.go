@@ -0,0 +1,65 @@
+package recommend
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	maxSyncAttempts = 20              // 约一小时后放弃，由cmd/recommendsync回填修正
+	syncRetryBase   = time.Second     // 第一次重试前的等待时间，之后指数增长
+	syncRetryMax    = 5 * time.Minute // 单次等待上限，覆盖熔断冷却期
+)
+
+// syncVersions 记录每个物品或用户最新一次同步任务的版本。熔断期间失败的任务会延迟重新入队，
+// 重试的旧任务已被新任务取代时直接跳过，避免旧的隐藏或删除覆盖之后的状态
+type syncVersions struct {
+	mu       sync.Mutex
+	next     int64
+	versions map[string]int64
+}
+
+func newSyncVersions() *syncVersions {
+	return &syncVersions{versions: make(map[string]int64)}
+}
+
+// issue 为target分配新版本，之前的任务都变为过期
+func (s *syncVersions) issue(target string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	s.versions[target] = s.next
+	return s.next
+}
+
+// current 任务是否仍是target最新的版本
+func (s *syncVersions) current(target string, version int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest, ok := s.versions[target]
+	return ok && latest == version
+}
+
+// done 最新版本处理完成或放弃后移除记录，之后到达的旧任务都视为过期
+func (s *syncVersions) done(target string, version int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.versions[target] == version {
+		delete(s.versions, target)
+	}
+}
+
+// retryDelay 第attempt次重试前的等待时间
+func retryDelay(attempt int) time.Duration {
+	delay := syncRetryBase
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= syncRetryMax {
+			return syncRetryMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,45 @@
+package recommend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyncVersionsSkipsSupersededTasks(t *testing.T) {
+	versions := newSyncVersions()
+
+	hide := versions.issue("item-1")
+	unhide := versions.issue("item-1")
+	if versions.current("item-1", hide) {
+		t.Error("older task should be superseded")
+	}
+	if !versions.current("item-1", unhide) {
+		t.Error("latest task should be current")
+	}
+
+	versions.done("item-1", hide)
+	if !versions.current("item-1", unhide) {
+		t.Error("finishing a superseded task should not clear the latest version")
+	}
+
+	versions.done("item-1", unhide)
+	if versions.current("item-1", hide) || versions.current("item-1", unhide) {
+		t.Error("tasks retried after the latest one finished should be stale")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		5:  16 * time.Second,
+		9:  256 * time.Second,
+		10: syncRetryMax,
+		20: syncRetryMax,
+	}
+	for attempt, want := range cases {
+		if got := retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
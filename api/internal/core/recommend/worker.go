@@ -3,6 +3,7 @@ package recommend
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -16,6 +17,40 @@ import (
 
 var GlobalWorker *RecommendationWorker
 
+// breaker 带熔断的推荐，Gorse不可用时使用兜底推荐
+var breaker *CircuitBreaker
+
+// ErrUnavailable 推荐服务处于熔断状态
+var ErrUnavailable = errors.New("recommender unavailable")
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30   // 秒
+	defaultBreakerTimeout   = 1000 // 毫秒
+
+	writeTimeout   = 5 * time.Second        // 写入Gorse的超时，写入比推荐查询慢，单独设置
+	publishTimeout = 100 * time.Millisecond // 队列已满时等待的时间
+)
+
+// 队列中的任务类型，物品和用户的同步与反馈共用队列，避免在业务流程中同步调用Gorse
+const (
+	taskFeedback   = "feedback"
+	taskInsertItem = "insert_item"
+	taskDeleteItem = "delete_item"
+	taskInsertUser = "insert_user"
+)
+
+// 反馈类型，Gorse中需要把like、purchase等配置为正反馈，view配置为已读反馈
+const (
+	FeedbackView     = "view"
+	FeedbackClick    = "click"
+	FeedbackLike     = "like"
+	FeedbackUnlike   = "unlike"
+	FeedbackChat     = "chat"
+	FeedbackPurchase = "purchase"
+	FeedbackShare    = "share"
+)
+
 // Feedback represents the structure of a recommendation feedback message
 type Feedback struct {
 	UserId       string `json:"userId"`
@@ -24,10 +59,25 @@ type Feedback struct {
 	Timestamp    int64  `json:"timestamp"`
 }
 
+// task 队列中的消息
+type task struct {
+	Type     string       `json:"type"`
+	Feedback *Feedback    `json:"feedback,omitempty"`
+	Item     *client.Item `json:"item,omitempty"`
+	ItemID   string       `json:"itemId,omitempty"`
+	User     *client.User `json:"user,omitempty"`
+
+	// 物品和用户同步任务的重试状态，Target为同步的对象，Version用于跳过已被取代的任务
+	Target  string `json:"target,omitempty"`
+	Version int64  `json:"version,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+}
+
 // RecommendationWorker receives messages and forwards them to Gorse
 type RecommendationWorker struct {
 	consumer    mq.Queue
 	gorseClient *client.GorseClient
+	versions    *syncVersions
 }
 
 func NewRecommendationWorker(consumer mq.Queue) *RecommendationWorker {
@@ -36,10 +86,12 @@ func NewRecommendationWorker(consumer mq.Queue) *RecommendationWorker {
 	return &RecommendationWorker{
 		consumer:    consumer,
 		gorseClient: gorseClient,
+		versions:    newSyncVersions(),
 	}
 }
 
-func InitGlobalWorker() {
+// InitGlobalWorker fallback为Gorse失败或没有结果时的兜底推荐
+func InitGlobalWorker(fallback Recommender) {
 	gorseConfig := app.GetConfig().Gorse
 	var (
 		err   error
@@ -58,6 +110,24 @@ func InitGlobalWorker() {
 
 	GlobalWorker = NewRecommendationWorker(queue)
 	go GlobalWorker.Work(context.Background())
+
+	breakerConfig := gorseConfig.Breaker
+	if breakerConfig.Threshold <= 0 {
+		breakerConfig.Threshold = defaultBreakerThreshold
+	}
+	if breakerConfig.Cooldown <= 0 {
+		breakerConfig.Cooldown = defaultBreakerCooldown
+	}
+	if breakerConfig.Timeout <= 0 {
+		breakerConfig.Timeout = defaultBreakerTimeout
+	}
+	breaker = NewCircuitBreaker(
+		NewGorseRecommender(GlobalWorker.gorseClient),
+		fallback,
+		breakerConfig.Threshold,
+		time.Duration(breakerConfig.Cooldown)*time.Second,
+		time.Duration(breakerConfig.Timeout)*time.Millisecond,
+	)
 }
 
 // Work starts consuming messages from the queue and processes them with Gorse
@@ -93,26 +163,94 @@ func (w *RecommendationWorker) Work(ctx context.Context) error {
 // processMessage handles a single message by parsing it and sending to Gorse
 func (w *RecommendationWorker) processMessage(ctx context.Context, msg mq.Message) error {
 	// Parse message content
-	var feedback Feedback
-	if err := json.Unmarshal(msg.Content, &feedback); err != nil {
+	var t task
+	if err := json.Unmarshal(msg.Content, &t); err != nil {
 		return fmt.Errorf("failed to unmarshal message: %w", err)
 	}
 
+	switch t.Type {
+	case taskFeedback:
+		if t.Feedback == nil {
+			return errors.New("missing feedback")
+		}
+		return w.sendFeedback(ctx, *t.Feedback)
+	case taskInsertItem, taskDeleteItem, taskInsertUser:
+		return w.sync(msg.ID, t)
+	default:
+		return fmt.Errorf("unknown task type %s", t.Type)
+	}
+}
+
+// sync 写入物品或用户，失败（包括熔断期间）时延迟重新入队，已被新任务取代的直接跳过
+func (w *RecommendationWorker) sync(id string, t task) error {
+	if !w.versions.current(t.Target, t.Version) {
+		return nil
+	}
+
+	var err error
+	switch t.Type {
+	case taskInsertItem:
+		if t.Item == nil {
+			err = errors.New("missing item")
+			break
+		}
+		err = insertItem(*t.Item)
+	case taskDeleteItem:
+		err = DeleteItem(t.ItemID)
+	case taskInsertUser:
+		if t.User == nil {
+			err = errors.New("missing user")
+			break
+		}
+		err = insertUser(*t.User)
+	}
+	if err != nil {
+		w.retry(id, t)
+		return err
+	}
+
+	w.versions.done(t.Target, t.Version)
+	return nil
+}
+
+// retry 按指数退避延迟重新入队，超过次数后放弃，由cmd/recommendsync回填修正
+func (w *RecommendationWorker) retry(id string, t task) {
+	if t.Attempt >= maxSyncAttempts {
+		log.Printf("recommend task %s dropped after %d attempts", id, t.Attempt)
+		w.versions.done(t.Target, t.Version)
+		return
+	}
+
+	t.Attempt++
+	time.AfterFunc(retryDelay(t.Attempt), func() {
+		w.publish(id, t)
+	})
+}
+
+// sendFeedback 发送反馈到Gorse，失败时重试
+func (w *RecommendationWorker) sendFeedback(ctx context.Context, feedback Feedback) error {
 	// Validate feedback
 	if feedback.UserId == "" || feedback.ItemId == "" || feedback.FeedbackType == "" {
 		return fmt.Errorf("invalid feedback data: userId=%s, itemId=%s, feedbackType=%s",
 			feedback.UserId, feedback.ItemId, feedback.FeedbackType)
 	}
 
+	timestamp := time.Now()
+	if feedback.Timestamp > 0 {
+		timestamp = time.Unix(feedback.Timestamp, 0)
+	}
+
 	// Send feedback to Gorse with retry
 	const maxRetries = 3
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		_, err := w.gorseClient.InsertFeedback(ctx, []client.Feedback{{
+		callCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		_, err := w.gorseClient.InsertFeedback(callCtx, []client.Feedback{{
 			FeedbackType: feedback.FeedbackType,
 			UserId:       feedback.UserId,
 			ItemId:       feedback.ItemId,
-			Timestamp:    time.Now().Format(time.RFC3339),
+			Timestamp:    timestamp.Format(time.RFC3339),
 		}})
+		cancel()
 		if err == nil {
 			return nil
 		}
@@ -131,7 +269,7 @@ func (w *RecommendationWorker) processMessage(ctx context.Context, msg mq.Messag
 
 func (w *RecommendationWorker) InsertFeedback(ctx context.Context, feedbacks []Feedback) error {
 	for _, feedback := range feedbacks {
-		msg, err := json.Marshal(feedback)
+		msg, err := json.Marshal(task{Type: taskFeedback, Feedback: &feedback})
 		if err != nil {
 			continue
 		}
@@ -145,24 +283,195 @@ func (w *RecommendationWorker) InsertFeedback(ctx context.Context, feedbacks []F
 	return nil
 }
 
-func CreateItem(product models.Product, categories []string, attributes []string, isHidden bool) error {
-	item := client.Item{
+// SyncItem 异步写入或覆盖物品，参数同CreateItem
+func (w *RecommendationWorker) SyncItem(product models.Product, categories []string, labels []string, isHidden bool) {
+	item := newItem(product, categories, labels, isHidden)
+	w.publish(taskInsertItem+"-"+product.ID, w.newSyncTask(task{Type: taskInsertItem, Item: &item, Target: "item-" + product.ID}))
+}
+
+// RemoveItem 异步删除物品
+func (w *RecommendationWorker) RemoveItem(itemID string) {
+	w.publish(taskDeleteItem+"-"+itemID, w.newSyncTask(task{Type: taskDeleteItem, ItemID: itemID, Target: "item-" + itemID}))
+}
+
+// SyncUser 异步写入或覆盖用户，参数同UpsertUser
+func (w *RecommendationWorker) SyncUser(userID string, labels []string) {
+	user := newUser(userID, labels)
+	w.publish(taskInsertUser+"-"+userID, w.newSyncTask(task{Type: taskInsertUser, User: &user, Target: "user-" + userID}))
+}
+
+// newSyncTask 为任务分配版本，同一物品或用户之前入队的任务随之过期
+func (w *RecommendationWorker) newSyncTask(t task) task {
+	t.Version = w.versions.issue(t.Target)
+	return t
+}
+
+// publish 投递同步任务，队列已满时同样延迟重试
+func (w *RecommendationWorker) publish(id string, t task) {
+	msg, err := json.Marshal(t)
+	if err != nil {
+		log.Printf("failed to marshal recommend task %s: %v", id, err)
+		w.versions.done(t.Target, t.Version)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := w.consumer.Publish(ctx, mq.Message{ID: id, Content: msg}); err != nil {
+		log.Printf("failed to publish recommend task %s: %v", id, err)
+		w.retry(id, t)
+	}
+}
+
+// writeContext Gorse熔断期间返回ErrUnavailable，否则返回带写入超时的context
+func writeContext() (context.Context, context.CancelFunc, error) {
+	if breaker != nil && breaker.Open() {
+		return nil, nil, ErrUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	return ctx, cancel, nil
+}
+
+// CreateItem 写入或覆盖物品，isHidden的物品不会被推荐，时间戳为商品发布时间
+func CreateItem(product models.Product, categories []string, labels []string, isHidden bool) error {
+	return insertItem(newItem(product, categories, labels, isHidden))
+}
+
+func newItem(product models.Product, categories []string, labels []string, isHidden bool) client.Item {
+	timestamp := product.PublishAt
+	if timestamp.IsZero() {
+		timestamp = product.CreatedAt
+	}
+	if labels == nil {
+		labels = []string{}
+	}
+
+	return client.Item{
 		ItemId:     product.ID,
-		IsHidden:   false,
+		IsHidden:   isHidden,
 		Categories: categories,
-		Timestamp:  time.Now().String(),
-		Labels:     attributes,
+		Timestamp:  timestamp.Format(time.RFC3339),
+		Labels:     labels,
 		Comment:    product.Describe,
 	}
+}
+
+func insertItem(item client.Item) error {
+	ctx, cancel, err := writeContext()
+	if err != nil {
+		return err
+	}
+	defer cancel()
 
-	_, err := GlobalWorker.gorseClient.InsertItem(context.Background(), item)
+	_, err = GlobalWorker.gorseClient.InsertItem(ctx, item)
+	return err
+}
+
+// DeleteItem 删除物品及其反馈
+func DeleteItem(itemID string) error {
+	ctx, cancel, err := writeContext()
 	if err != nil {
 		return err
 	}
+	defer cancel()
 
-	return nil
+	_, err = GlobalWorker.gorseClient.DeleteItem(ctx, itemID)
+	return err
 }
 
 func GetRecommendations(userID string, size int) ([]string, error) {
-	return GlobalWorker.gorseClient.GetRecommend(context.Background(), userID, "", size)
+	return breaker.Recommend(context.Background(), userID, size)
+}
+
+// GetNeighbors Gorse计算的相似物品，熔断期间直接返回ErrUnavailable
+func GetNeighbors(itemID string, size int) ([]string, error) {
+	if breaker.Open() {
+		return nil, ErrUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), breaker.timeout)
+	defer cancel()
+
+	scores, err := GlobalWorker.gorseClient.GetNeighbors(ctx, itemID, size)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(scores))
+	for _, score := range scores {
+		ids = append(ids, score.Id)
+	}
+	return ids, nil
+}
+
+// UpsertUser 写入或覆盖Gorse用户，labels为用户选择的兴趣标签，用于冷启动
+func UpsertUser(userID string, labels []string) error {
+	return insertUser(newUser(userID, labels))
+}
+
+func newUser(userID string, labels []string) client.User {
+	if labels == nil {
+		labels = []string{}
+	}
+
+	return client.User{
+		UserId: userID,
+		Labels: labels,
+	}
+}
+
+func insertUser(user client.User) error {
+	ctx, cancel, err := writeContext()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = GlobalWorker.gorseClient.InsertUser(ctx, user)
+	return err
+}
+
+// DeleteMissingItems 删除用户反馈过、但exists判断为不存在的物品，返回删除的数量
+func DeleteMissingItems(userID string, feedbackType string, exists func(itemIDs []string) (map[string]bool, error)) (int, error) {
+	ctx, cancel, err := writeContext()
+	if err != nil {
+		return 0, err
+	}
+	feedbacks, err := GlobalWorker.gorseClient.ListFeedbacks(ctx, feedbackType, userID)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	if len(feedbacks) == 0 {
+		return 0, nil
+	}
+
+	itemIDs := make([]string, 0, len(feedbacks))
+	for _, feedback := range feedbacks {
+		itemIDs = append(itemIDs, feedback.ItemId)
+	}
+
+	existing, err := exists(itemIDs)
+	if err != nil {
+		return 0, err
+	}
+	if existing == nil {
+		existing = make(map[string]bool)
+	}
+
+	deleted := 0
+	for _, itemID := range itemIDs {
+		if existing[itemID] {
+			continue
+		}
+		if err := DeleteItem(itemID); err != nil {
+			return deleted, err
+		}
+		existing[itemID] = true
+		deleted++
+	}
+
+	return deleted, nil
 }
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/mislu/market-api/internal/oss"
@@ -58,11 +59,19 @@ func GetObjectPath(bucketType BucketType, owner string, key string) string {
 		return fmt.Sprintf("users/%s/%s", owner, key)
 	case ProductBucket:
 		return fmt.Sprintf("products/%s/%s", owner, key)
+	case ConversationBucket:
+		// 会话id形如"userA:userB"，目录中使用"_"避免路径中的冒号
+		return fmt.Sprintf("conversations/%s/%s", strings.ReplaceAll(owner, ":", "_"), key)
 	default:
 		return fmt.Sprintf("temp/%s", key)
 	}
 }
 
+// GenerateVariantKey 生成同一资源的派生版本(缩略图、压缩图等)的key
+func GenerateVariantKey(key string, variant string, ext string) string {
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(key, filepath.Ext(key)), variant, ext)
+}
+
 func genUniqueFilename(origin string) string {
 	ext := filepath.Ext(origin)
 	hash := sha256.Sum256([]byte(origin + time.Now().String()))
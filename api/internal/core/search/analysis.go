@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analyze 索引时的分词，英文和数字按单词切分并转小写，连续的中日韩字符同时输出单字和相邻两字
+func Analyze(text string) []string {
+	return analyze(text, true)
+}
+
+// analyzeQuery 查询时的分词，中日韩字符只取相邻两字，单独一个字时才使用单字，减少单字匹配带来的噪音
+func analyzeQuery(text string) []string {
+	return analyze(text, false)
+}
+
+func analyze(text string, unigrams bool) []string {
+	tokens := []string{}
+
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := range cjk {
+				if unigrams {
+					tokens = append(tokens, string(cjk[i]))
+				}
+				if i+1 < len(cjk) {
+					tokens = append(tokens, string(cjk[i:i+2]))
+				}
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// isWord 拼写纠错只处理由字母组成的词
+func isWord(token string) bool {
+	for _, r := range token {
+		if !unicode.IsLetter(r) || isCJK(r) {
+			return false
+		}
+	}
+	return len(token) > 0
+}
+
+// editDistance 两个词的Levenshtein距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
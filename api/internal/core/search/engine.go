@@ -0,0 +1,461 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrIndexNotFound    = errors.New("index not found")
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+// Engine 内存中的全文索引，查询使用es DSL的常用子集，可选地定期保存快照到本地文件
+type Engine struct {
+	mu      sync.RWMutex
+	indices map[string]*index
+	path    string
+	dirty   bool
+}
+
+type index struct {
+	mapping map[string]interface{}
+	types   map[string]string // 字段路径 -> 类型
+	parents map[string]string // 多字段子字段 -> 所属字段，如describe.keyword -> describe
+	docs    map[string]*document
+
+	docFreq  map[string]map[string]int // 字段 -> 词 -> 包含该词的文档数
+	totalLen map[string]int            // 字段 -> 所有文档的词数之和
+}
+
+type document struct {
+	id      string
+	source  json.RawMessage
+	fields  map[string]interface{}
+	terms   map[string]map[string]int // 文本字段 -> 词 -> 词频
+	lengths map[string]int
+}
+
+type snapshot struct {
+	Indices map[string]snapshotIndex `json:"indices"`
+}
+
+type snapshotIndex struct {
+	Mapping map[string]interface{}     `json:"mapping"`
+	Docs    map[string]json.RawMessage `json:"docs"`
+}
+
+// Open 创建引擎，path不为空时从快照文件恢复数据，之后Flush写回同一文件
+func Open(path string) (*Engine, error) {
+	engine := &Engine{
+		indices: make(map[string]*index),
+		path:    path,
+	}
+	if len(path) == 0 {
+		return engine, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return engine, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse snapshot %s: %w", path, err)
+	}
+
+	for name, saved := range s.Indices {
+		ix := newIndex(saved.Mapping)
+		for id, source := range saved.Docs {
+			if err := ix.put(id, source); err != nil {
+				return nil, err
+			}
+		}
+		engine.indices[name] = ix
+	}
+
+	return engine, nil
+}
+
+// Flush 有变更时把所有索引写入快照文件，先写临时文件再替换，避免中断时损坏快照
+func (e *Engine) Flush() error {
+	if len(e.path) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	if !e.dirty {
+		e.mu.Unlock()
+		return nil
+	}
+
+	s := snapshot{Indices: make(map[string]snapshotIndex, len(e.indices))}
+	for name, ix := range e.indices {
+		docs := make(map[string]json.RawMessage, len(ix.docs))
+		for id, doc := range ix.docs {
+			docs[id] = doc.source
+		}
+		s.Indices[name] = snapshotIndex{Mapping: ix.mapping, Docs: docs}
+	}
+	e.dirty = false
+	e.mu.Unlock()
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, e.path)
+}
+
+// CreateIndex 创建索引，索引已存在且mapping变化时按新的mapping重新分析所有文档
+func (e *Engine) CreateIndex(name string, mapping map[string]interface{}) error {
+	normalized, err := normalize(mapping)
+	if err != nil {
+		return err
+	}
+	m, _ := normalized.(map[string]interface{})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	old, ok := e.indices[name]
+	if ok {
+		before, _ := json.Marshal(old.mapping)
+		after, _ := json.Marshal(m)
+		if string(before) == string(after) {
+			return nil
+		}
+	}
+
+	ix := newIndex(m)
+	if ok {
+		for id, doc := range old.docs {
+			if err := ix.put(id, doc.source); err != nil {
+				return err
+			}
+		}
+	}
+
+	e.indices[name] = ix
+	e.dirty = true
+	return nil
+}
+
+// Index 写入或覆盖文档
+func (e *Engine) Index(name string, id string, source json.RawMessage) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return err
+	}
+
+	if err := ix.put(id, source); err != nil {
+		return err
+	}
+
+	e.dirty = true
+	return nil
+}
+
+// Update 局部更新文档，仅覆盖partial中出现的顶层字段
+func (e *Engine) Update(name string, id string, partial json.RawMessage) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return err
+	}
+
+	doc, ok := ix.docs[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, id)
+	}
+
+	source, err := merge(doc.source, partial)
+	if err != nil {
+		return err
+	}
+
+	if err := ix.put(id, source); err != nil {
+		return err
+	}
+
+	e.dirty = true
+	return nil
+}
+
+// Delete 删除文档，文档不存在时不报错
+func (e *Engine) Delete(name string, id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ix.docs[id]; ok {
+		ix.remove(id)
+		e.dirty = true
+	}
+	return nil
+}
+
+// UpdateByQuery 把partial中的字段写入所有匹配query的文档
+func (e *Engine) UpdateByQuery(name string, query map[string]interface{}, partial json.RawMessage) error {
+	q, err := normalize(query)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return err
+	}
+
+	m := newMatcher(ix)
+	for _, id := range ix.sortedIDs() {
+		doc := ix.docs[id]
+		ok, _, err := m.eval(q, rootScope(doc))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		source, err := merge(doc.source, partial)
+		if err != nil {
+			return err
+		}
+		if err := ix.put(id, source); err != nil {
+			return err
+		}
+		e.dirty = true
+	}
+
+	return nil
+}
+
+// Get 批量获取文档，不存在的文档不会出现在结果中
+func (e *Engine) Get(name string, ids []string) (map[string]json.RawMessage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]json.RawMessage, len(ids))
+	for _, id := range ids {
+		if doc, ok := ix.docs[id]; ok {
+			result[id] = doc.source
+		}
+	}
+
+	return result, nil
+}
+
+func (e *Engine) index(name string) (*index, error) {
+	ix, ok := e.indices[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, name)
+	}
+	return ix, nil
+}
+
+func newIndex(mapping map[string]interface{}) *index {
+	ix := &index{
+		mapping:  mapping,
+		types:    make(map[string]string),
+		parents:  make(map[string]string),
+		docs:     make(map[string]*document),
+		docFreq:  make(map[string]map[string]int),
+		totalLen: make(map[string]int),
+	}
+
+	mappings, _ := mapping["mappings"].(map[string]interface{})
+	properties, _ := mappings["properties"].(map[string]interface{})
+	ix.parseProperties("", properties)
+
+	return ix
+}
+
+func (ix *index) parseProperties(prefix string, properties map[string]interface{}) {
+	for name, raw := range properties {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		path := prefix + name
+		typ, _ := field["type"].(string)
+		if len(typ) == 0 {
+			typ = "object"
+		}
+		ix.types[path] = typ
+
+		if children, ok := field["properties"].(map[string]interface{}); ok {
+			ix.parseProperties(path+".", children)
+		}
+
+		subFields, _ := field["fields"].(map[string]interface{})
+		for subName, subRaw := range subFields {
+			sub, _ := subRaw.(map[string]interface{})
+			subType, _ := sub["type"].(string)
+			ix.types[path+"."+subName] = subType
+			ix.parents[path+"."+subName] = path
+		}
+	}
+}
+
+// resolve 返回查询字段对应的文档字段和字段类型，子字段使用所属字段的值
+func (ix *index) resolve(field string) (string, string) {
+	if parent, ok := ix.parents[field]; ok {
+		return parent, ix.types[field]
+	}
+	return field, ix.types[field]
+}
+
+// textFields 需要分词的文档字段，子字段只在查询时分析所属字段的值
+func (ix *index) textFields() []string {
+	fields := []string{}
+	for path, typ := range ix.types {
+		if _, ok := ix.parents[path]; !ok && typ == "text" {
+			fields = append(fields, path)
+		}
+	}
+	return fields
+}
+
+func (ix *index) put(id string, source json.RawMessage) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(source, &fields); err != nil {
+		return fmt.Errorf("parse document %s: %w", id, err)
+	}
+
+	if _, ok := ix.docs[id]; ok {
+		ix.remove(id)
+	}
+
+	doc := &document{
+		id:      id,
+		source:  append(json.RawMessage(nil), source...),
+		fields:  fields,
+		terms:   make(map[string]map[string]int),
+		lengths: make(map[string]int),
+	}
+
+	for _, field := range ix.textFields() {
+		tf, length := termFrequencies(lookup(fields, field))
+		doc.terms[field] = tf
+		doc.lengths[field] = length
+
+		if ix.docFreq[field] == nil {
+			ix.docFreq[field] = make(map[string]int)
+		}
+		for term := range tf {
+			ix.docFreq[field][term]++
+		}
+		ix.totalLen[field] += length
+	}
+
+	ix.docs[id] = doc
+	return nil
+}
+
+func (ix *index) remove(id string) {
+	doc := ix.docs[id]
+	for field, tf := range doc.terms {
+		for term := range tf {
+			ix.docFreq[field][term]--
+			if ix.docFreq[field][term] <= 0 {
+				delete(ix.docFreq[field], term)
+			}
+		}
+		ix.totalLen[field] -= doc.lengths[field]
+	}
+
+	delete(ix.docs, id)
+}
+
+func (ix *index) sortedIDs() []string {
+	ids := make([]string, 0, len(ix.docs))
+	for id := range ix.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func termFrequencies(values []interface{}) (map[string]int, int) {
+	tf := make(map[string]int)
+	length := 0
+	for _, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		for _, token := range Analyze(text) {
+			tf[token]++
+			length++
+		}
+	}
+	return tf, length
+}
+
+func merge(source json.RawMessage, partial json.RawMessage) (json.RawMessage, error) {
+	var doc, changes map[string]interface{}
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(partial, &changes); err != nil {
+		return nil, err
+	}
+
+	for key, value := range changes {
+		doc[key] = value
+	}
+
+	return json.Marshal(doc)
+}
+
+// normalize 通过json序列化把调用方的各种map和切片类型统一为map[string]interface{}和[]interface{}
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
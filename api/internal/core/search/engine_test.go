@@ -0,0 +1,312 @@
+package search
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+var testMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{"type": "keyword"},
+			"describe": map[string]interface{}{
+				"type": "text",
+				"fields": map[string]interface{}{
+					"keyword": map[string]interface{}{"type": "keyword"},
+				},
+			},
+			"price":      map[string]interface{}{"type": "float"},
+			"city":       map[string]interface{}{"type": "keyword"},
+			"is_sold":    map[string]interface{}{"type": "boolean"},
+			"suggest":    map[string]interface{}{"type": "completion"},
+			"geo":        map[string]interface{}{"type": "geo_point"},
+			"attributes": map[string]interface{}{"type": "nested"},
+		},
+	},
+}
+
+func newTestEngine(t *testing.T, path string) *Engine {
+	t.Helper()
+
+	engine, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.CreateIndex("products", testMapping); err != nil {
+		t.Fatal(err)
+	}
+
+	docs := map[string]string{
+		"1": `{"id":"1","describe":"苹果手机 iPhone 13","price":3000,"city":"北京","is_sold":false,"suggest":["苹果手机"],"geo":{"lat":39.9,"lon":116.4},"attributes":[{"key":"颜色","value":"黑色"}]}`,
+		"2": `{"id":"2","describe":"二手手机壳","price":20,"city":"上海","is_sold":false,"suggest":["手机壳"],"geo":{"lat":31.2,"lon":121.5},"attributes":[{"key":"颜色","value":"白色"}]}`,
+		"3": `{"id":"3","describe":"山地自行车","price":800,"city":"北京","is_sold":true,"geo":{"lat":39.95,"lon":116.45}}`,
+	}
+	for id, doc := range docs {
+		if err := engine.Index("products", id, json.RawMessage(doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return engine
+}
+
+func hitIDs(result Result) []string {
+	ids := []string{}
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestAnalyze(t *testing.T) {
+	got := Analyze("iPhone13 苹果手机")
+	want := []string{"iphone13", "苹", "苹果", "果", "果手", "手", "手机", "机"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Analyze = %v, want %v", got, want)
+	}
+
+	if got := analyzeQuery("手机"); !reflect.DeepEqual(got, []string{"手机"}) {
+		t.Errorf("analyzeQuery = %v", got)
+	}
+}
+
+func TestSearchMatchAndFilter(t *testing.T) {
+	engine := newTestEngine(t, "")
+
+	result, err := engine.Search("products", map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": []map[string]interface{}{
+					{"match": map[string]interface{}{"describe": "手机"}},
+				},
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{"is_sold": false}},
+					{"range": map[string]interface{}{"price": map[string]interface{}{"lte": 100}}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := hitIDs(result); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Errorf("hits = %v, want [2]", ids)
+	}
+}
+
+func TestSearchSortPagingAndSearchAfter(t *testing.T) {
+	engine := newTestEngine(t, "")
+
+	query := map[string]interface{}{
+		"size": 2,
+		"sort": []map[string]interface{}{{"price": map[string]interface{}{"order": "desc"}}},
+	}
+	result, err := engine.Search("products", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 3 || !reflect.DeepEqual(hitIDs(result), []string{"1", "3"}) {
+		t.Fatalf("total = %d, hits = %v", result.Total, hitIDs(result))
+	}
+
+	query["search_after"] = []interface{}{800}
+	result, err = engine.Search("products", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hitIDs(result), []string{"2"}) {
+		t.Errorf("search_after hits = %v, want [2]", hitIDs(result))
+	}
+}
+
+func TestSearchGeoAndNested(t *testing.T) {
+	engine := newTestEngine(t, "")
+
+	result, err := engine.Search("products", map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"geo_distance": map[string]interface{}{
+						"distance": "20km",
+						"geo":      map[string]interface{}{"lat": 39.9, "lon": 116.4},
+					}},
+				},
+			},
+		},
+		"sort": []map[string]interface{}{{"_geo_distance": map[string]interface{}{
+			"geo":   map[string]interface{}{"lat": 39.96, "lon": 116.46},
+			"order": "asc",
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hitIDs(result), []string{"3", "1"}) {
+		t.Errorf("geo hits = %v, want [3 1]", hitIDs(result))
+	}
+
+	count, err := engine.Count("products", map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path":  "attributes",
+			"query": map[string]interface{}{"term": map[string]interface{}{"attributes.value": "白色"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("nested count = %d, want 1", count)
+	}
+}
+
+func TestAggregations(t *testing.T) {
+	engine := newTestEngine(t, "")
+
+	result, err := engine.Search("products", map[string]interface{}{
+		"size": 0,
+		"aggs": map[string]interface{}{
+			"cities": map[string]interface{}{"terms": map[string]interface{}{"field": "city"}},
+			"price": map[string]interface{}{"histogram": map[string]interface{}{
+				"field": "price", "interval": 1000, "min_doc_count": 1,
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cities struct {
+		Buckets []struct {
+			Key      string `json:"key"`
+			DocCount int    `json:"doc_count"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal(result.Aggregations["cities"], &cities); err != nil {
+		t.Fatal(err)
+	}
+	if len(cities.Buckets) != 2 || cities.Buckets[0].Key != "北京" || cities.Buckets[0].DocCount != 2 {
+		t.Errorf("cities = %+v", cities.Buckets)
+	}
+
+	var price struct {
+		Buckets []struct {
+			Key      float64 `json:"key"`
+			DocCount int     `json:"doc_count"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal(result.Aggregations["price"], &price); err != nil {
+		t.Fatal(err)
+	}
+	if len(price.Buckets) != 2 || price.Buckets[0].Key != 0 || price.Buckets[0].DocCount != 2 {
+		t.Errorf("price = %+v", price.Buckets)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	engine := newTestEngine(t, "")
+
+	result, err := engine.Suggest("products", map[string]interface{}{
+		"completion": map[string]interface{}{
+			"prefix":     "手机",
+			"completion": map[string]interface{}{"field": "suggest"},
+		},
+		"did_you_mean": map[string]interface{}{
+			"text":   "iphome",
+			"phrase": map[string]interface{}{"field": "describe"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []struct {
+		Options []struct {
+			Text string `json:"text"`
+		} `json:"options"`
+	}
+	if err := json.Unmarshal(result["completion"], &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Options) != 1 || entries[0].Options[0].Text != "手机壳" {
+		t.Errorf("completion = %s", result["completion"])
+	}
+
+	if err := json.Unmarshal(result["did_you_mean"], &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Options) != 1 || entries[0].Options[0].Text != "iphone" {
+		t.Errorf("did_you_mean = %s", result["did_you_mean"])
+	}
+}
+
+func TestUpdateDeleteAndSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	engine := newTestEngine(t, path)
+
+	if err := engine.UpdateByQuery("products",
+		map[string]interface{}{"term": map[string]interface{}{"city": "北京"}},
+		json.RawMessage(`{"city":"天津"}`),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Delete("products", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Delete("products", "missing"); err != nil {
+		t.Errorf("deleting a missing document should succeed: %v", err)
+	}
+	if err := engine.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := reopened.Count("products", map[string]interface{}{
+		"term": map[string]interface{}{"city": "天津"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("updated documents = %d, want 2", count)
+	}
+
+	docs, err := reopened.Get("products", []string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := docs["2"]; ok || len(docs) != 1 {
+		t.Errorf("documents after delete = %v", docs)
+	}
+}
+
+func TestMoreLikeThis(t *testing.T) {
+	engine := newTestEngine(t, "")
+	if err := engine.Index("products", "4", json.RawMessage(`{"id":"4","describe":"华为手机 Mate","city":"北京"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := engine.Search("products", map[string]interface{}{
+		"query": map[string]interface{}{
+			"more_like_this": map[string]interface{}{
+				"fields":        []string{"describe", "city"},
+				"like":          []map[string]interface{}{{"_id": "1"}},
+				"min_term_freq": 1,
+				"min_doc_freq":  1,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不包含like中的文档，同为手机且同城的排在前面
+	if ids := hitIDs(result); len(ids) == 0 || ids[0] != "4" || slices.Contains(ids, "1") {
+		t.Errorf("more_like_this hits = %v", ids)
+	}
+}
@@ -0,0 +1,206 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// likeTerm more_like_this选出的查询词
+type likeTerm struct {
+	field  string
+	term   string
+	idf    float64
+	weight float64
+}
+
+// evalMoreLikeThis 从like中的文档或文本选出最有代表性的词，再按这些词匹配相似文档，默认不包含like中的文档
+func (m *matcher) evalMoreLikeThis(body map[string]interface{}, s scope) (bool, float64, error) {
+	if s.doc == nil {
+		return false, 0, nil
+	}
+
+	terms, liked := m.likeTerms(body)
+	if include, _ := body["include"].(bool); !include && liked[s.doc.id] {
+		return false, 0, nil
+	}
+	if len(terms) == 0 {
+		return false, 0, nil
+	}
+
+	matched, score := 0, 0.0
+	for _, t := range terms {
+		if m.hasTerm(t.field, t.term, s) {
+			matched++
+			score += t.idf
+		}
+	}
+
+	msm, ok := body["minimum_should_match"]
+	if !ok {
+		msm = "30%"
+	}
+	required := parseMinimumShouldMatch(msm, len(terms))
+	if matched == 0 || matched < required {
+		return false, 0, nil
+	}
+
+	return true, score * boostOf(body, 1), nil
+}
+
+// likeTerms 按tf-idf选出最多max_query_terms个词，参数默认值与es一致
+func (m *matcher) likeTerms(body map[string]interface{}) ([]likeTerm, map[string]bool) {
+	minTermFreq, minDocFreq, maxQueryTerms := 2, 5, 25
+	if v, ok := body["min_term_freq"].(float64); ok {
+		minTermFreq = int(v)
+	}
+	if v, ok := body["min_doc_freq"].(float64); ok {
+		minDocFreq = int(v)
+	}
+	if v, ok := body["max_query_terms"].(float64); ok {
+		maxQueryTerms = int(v)
+	}
+
+	fields := map[string]string{} // 字段 -> 类型
+	for _, raw := range toList(body["fields"]) {
+		field, _ := raw.(string)
+		source, typ := m.ix.resolve(field)
+		if typ == "text" || typ == "keyword" {
+			fields[source] = typ
+		}
+	}
+
+	liked := map[string]bool{}
+	frequencies := map[string]map[string]int{}
+	add := func(field string, term string, count int) {
+		if frequencies[field] == nil {
+			frequencies[field] = map[string]int{}
+		}
+		frequencies[field][term] += count
+	}
+
+	for _, raw := range toList(body["like"]) {
+		switch like := raw.(type) {
+		case string:
+			for field, typ := range fields {
+				if typ == "text" {
+					for _, token := range Analyze(like) {
+						add(field, token, 1)
+					}
+				} else {
+					add(field, like, 1)
+				}
+			}
+		case map[string]interface{}:
+			id, _ := like["_id"].(string)
+			doc, ok := m.ix.docs[id]
+			if !ok {
+				continue
+			}
+			liked[id] = true
+			for field, typ := range fields {
+				if typ == "text" {
+					for term, count := range doc.terms[field] {
+						add(field, term, count)
+					}
+					continue
+				}
+				for _, value := range lookup(doc.fields, field) {
+					if text, ok := value.(string); ok {
+						add(field, text, 1)
+					}
+				}
+			}
+		}
+	}
+
+	docCount := float64(len(m.ix.docs))
+	terms := []likeTerm{}
+	for field, tf := range frequencies {
+		for term, count := range tf {
+			if count < minTermFreq {
+				continue
+			}
+			df := m.docFreq(field, fields[field], term)
+			if df < minDocFreq || df == 0 {
+				continue
+			}
+			idf := math.Log(1 + (docCount-float64(df)+0.5)/(float64(df)+0.5))
+			terms = append(terms, likeTerm{field: field, term: term, idf: idf, weight: float64(count) * idf})
+		}
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].weight != terms[j].weight {
+			return terms[i].weight > terms[j].weight
+		}
+		if terms[i].field != terms[j].field {
+			return terms[i].field < terms[j].field
+		}
+		return terms[i].term < terms[j].term
+	})
+	if len(terms) > maxQueryTerms {
+		terms = terms[:maxQueryTerms]
+	}
+
+	return terms, liked
+}
+
+// docFreq 包含该词的文档数，keyword字段在首次使用时统计
+func (m *matcher) docFreq(field string, typ string, term string) int {
+	if typ == "text" {
+		return m.ix.docFreq[field][term]
+	}
+
+	if m.keywordFreq == nil {
+		m.keywordFreq = map[string]map[string]int{}
+	}
+	freq, ok := m.keywordFreq[field]
+	if !ok {
+		freq = map[string]int{}
+		for _, doc := range m.ix.docs {
+			seen := map[string]bool{}
+			for _, value := range lookup(doc.fields, field) {
+				if text, ok := value.(string); ok && !seen[text] {
+					seen[text] = true
+					freq[text]++
+				}
+			}
+		}
+		m.keywordFreq[field] = freq
+	}
+
+	return freq[term]
+}
+
+func (m *matcher) hasTerm(field string, term string, s scope) bool {
+	if terms := s.doc.terms[field]; terms != nil {
+		return terms[term] > 0
+	}
+	for _, value := range lookup(s.fields, field) {
+		if text, ok := value.(string); ok && text == term {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMinimumShouldMatch 解析整数、"N"或"P%"形式的minimum_should_match，百分比向下取整
+func parseMinimumShouldMatch(value interface{}, clauses int) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		if percent, ok := strings.CutSuffix(v, "%"); ok {
+			if p, err := strconv.ParseFloat(percent, 64); err == nil {
+				return int(math.Floor(float64(clauses) * p / 100))
+			}
+			return 0
+		}
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return 0
+}
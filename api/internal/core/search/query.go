@@ -0,0 +1,662 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedQuery = errors.New("unsupported query")
+
+// BM25参数，与es默认值一致
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// scope 查询的作用对象，nested查询中为嵌套对象，doc为空时文本字段在查询时分词
+type scope struct {
+	fields map[string]interface{}
+	doc    *document
+}
+
+func rootScope(doc *document) scope {
+	return scope{fields: doc.fields, doc: doc}
+}
+
+type matcher struct {
+	ix  *index
+	now time.Time
+
+	keywordFreq map[string]map[string]int // more_like_this用到的keyword字段 -> 值 -> 文档数
+}
+
+func newMatcher(ix *index) *matcher {
+	return &matcher{ix: ix, now: time.Now()}
+}
+
+// eval 返回文档是否匹配query及其得分
+func (m *matcher) eval(query interface{}, s scope) (bool, float64, error) {
+	q, ok := query.(map[string]interface{})
+	if !ok || len(q) == 0 {
+		return true, 1, nil
+	}
+
+	for typ, raw := range q {
+		body, _ := raw.(map[string]interface{})
+		switch typ {
+		case "match_all":
+			return true, boostOf(body, 1), nil
+		case "match_none":
+			return false, 0, nil
+		case "bool":
+			return m.evalBool(body, s)
+		case "match":
+			return m.evalMatch(body, s)
+		case "multi_match":
+			return m.evalMultiMatch(body, s)
+		case "term":
+			return m.evalTerm(body, s)
+		case "terms":
+			return m.evalTerms(body, s)
+		case "range":
+			return m.evalRange(body, s)
+		case "prefix":
+			return m.evalPrefix(body, s)
+		case "exists":
+			field, _ := body["field"].(string)
+			source, _ := m.ix.resolve(field)
+			return len(lookup(s.fields, source)) > 0, 1, nil
+		case "ids":
+			return m.evalIDs(body, s)
+		case "nested":
+			return m.evalNested(body, s)
+		case "geo_distance":
+			return m.evalGeoDistance(body, s)
+		case "constant_score":
+			matched, _, err := m.eval(body["filter"], s)
+			return matched, boostOf(body, 1), err
+		case "function_score":
+			return m.evalFunctionScore(body, s)
+		case "more_like_this":
+			return m.evalMoreLikeThis(body, s)
+		default:
+			return false, 0, fmt.Errorf("%w: %s", ErrUnsupportedQuery, typ)
+		}
+	}
+
+	return true, 1, nil
+}
+
+func boostOf(body map[string]interface{}, fallback float64) float64 {
+	if boost, ok := body["boost"].(float64); ok {
+		return boost
+	}
+	return fallback
+}
+
+// fieldQuery 拆出{"field": value}或{"field": {"value"/"query": value, ...}}形式的查询
+func fieldQuery(body map[string]interface{}, key string) (string, interface{}, map[string]interface{}) {
+	for field, raw := range body {
+		if field == "boost" {
+			continue
+		}
+		if options, ok := raw.(map[string]interface{}); ok {
+			return field, options[key], options
+		}
+		return field, raw, map[string]interface{}{}
+	}
+	return "", nil, map[string]interface{}{}
+}
+
+func (m *matcher) evalBool(body map[string]interface{}, s scope) (bool, float64, error) {
+	score := 0.0
+
+	for _, clause := range toList(body["must"]) {
+		matched, clauseScore, err := m.eval(clause, s)
+		if err != nil || !matched {
+			return false, 0, err
+		}
+		score += clauseScore
+	}
+
+	for _, clause := range toList(body["filter"]) {
+		matched, _, err := m.eval(clause, s)
+		if err != nil || !matched {
+			return false, 0, err
+		}
+	}
+
+	for _, clause := range toList(body["must_not"]) {
+		matched, _, err := m.eval(clause, s)
+		if err != nil {
+			return false, 0, err
+		}
+		if matched {
+			return false, 0, nil
+		}
+	}
+
+	should := toList(body["should"])
+	minimumShouldMatch := 0
+	if len(toList(body["must"])) == 0 && len(toList(body["filter"])) == 0 && len(should) > 0 {
+		minimumShouldMatch = 1
+	}
+	if v, ok := body["minimum_should_match"]; ok {
+		minimumShouldMatch = parseMinimumShouldMatch(v, len(should))
+	}
+
+	matchedShould := 0
+	for _, clause := range should {
+		matched, clauseScore, err := m.eval(clause, s)
+		if err != nil {
+			return false, 0, err
+		}
+		if matched {
+			matchedShould++
+			score += clauseScore
+		}
+	}
+	if matchedShould < minimumShouldMatch {
+		return false, 0, nil
+	}
+
+	return true, score * boostOf(body, 1), nil
+}
+
+func (m *matcher) evalMatch(body map[string]interface{}, s scope) (bool, float64, error) {
+	field, value, options := fieldQuery(body, "query")
+	text := fmt.Sprint(value)
+
+	source, typ := m.ix.resolve(field)
+	if typ != "text" {
+		return m.evalTerm(map[string]interface{}{field: value}, s)
+	}
+
+	operator, _ := options["operator"].(string)
+	matched, score := m.score(source, analyzeQuery(text), strings.EqualFold(operator, "and"), s)
+	return matched, score * boostOf(options, 1), nil
+}
+
+func (m *matcher) evalMultiMatch(body map[string]interface{}, s scope) (bool, float64, error) {
+	text := fmt.Sprint(body["query"])
+	operator, _ := body["operator"].(string)
+
+	matched, best := false, 0.0
+	for _, raw := range toList(body["fields"]) {
+		field, _ := raw.(string)
+		boost := 1.0
+		if name, weight, ok := strings.Cut(field, "^"); ok {
+			field = name
+			if w, err := strconv.ParseFloat(weight, 64); err == nil {
+				boost = w
+			}
+		}
+
+		source, typ := m.ix.resolve(field)
+		if typ != "text" {
+			continue
+		}
+
+		ok, score := m.score(source, analyzeQuery(text), strings.EqualFold(operator, "and"), s)
+		if ok {
+			matched = true
+			best = math.Max(best, score*boost)
+		}
+	}
+
+	return matched, best * boostOf(body, 1), nil
+}
+
+// score 按BM25计算文本字段对查询词的得分，and为true时要求所有词都出现
+func (m *matcher) score(field string, tokens []string, and bool, s scope) (bool, float64) {
+	if len(tokens) == 0 {
+		return false, 0
+	}
+
+	var tf map[string]int
+	var length int
+	if s.doc != nil && s.doc.terms[field] != nil {
+		tf, length = s.doc.terms[field], s.doc.lengths[field]
+	} else {
+		tf, length = termFrequencies(lookup(s.fields, field))
+	}
+
+	docCount := float64(len(m.ix.docs))
+	averageLength := 1.0
+	if docCount > 0 && m.ix.totalLen[field] > 0 {
+		averageLength = float64(m.ix.totalLen[field]) / docCount
+	}
+
+	matched, score := 0, 0.0
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+
+		frequency := float64(tf[token])
+		if frequency == 0 {
+			continue
+		}
+		matched++
+
+		df := float64(m.ix.docFreq[field][token])
+		idf := math.Log(1 + (docCount-df+0.5)/(df+0.5))
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(length)/averageLength)
+		score += idf * frequency * (bm25K1 + 1) / (frequency + norm)
+	}
+
+	if matched == 0 || (and && matched < len(seen)) {
+		return false, 0
+	}
+	return true, score
+}
+
+// values 取出字段的值，文本字段的term查询按分词后的词比较
+func (m *matcher) values(field string, s scope) []interface{} {
+	source, typ := m.ix.resolve(field)
+	values := lookup(s.fields, source)
+	if typ != "text" {
+		return values
+	}
+
+	tokens := []interface{}{}
+	for _, value := range values {
+		if text, ok := value.(string); ok {
+			for _, token := range Analyze(text) {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func (m *matcher) evalTerm(body map[string]interface{}, s scope) (bool, float64, error) {
+	field, value, options := fieldQuery(body, "value")
+	values := m.values(field, s)
+	for _, v := range values {
+		if equal(v, value) {
+			return true, boostOf(options, 1), nil
+		}
+	}
+	return false, 0, nil
+}
+
+func (m *matcher) evalTerms(body map[string]interface{}, s scope) (bool, float64, error) {
+	for field, raw := range body {
+		if field == "boost" {
+			continue
+		}
+
+		values := m.values(field, s)
+		for _, expected := range toList(raw) {
+			for _, v := range values {
+				if equal(v, expected) {
+					return true, boostOf(body, 1), nil
+				}
+			}
+		}
+		return false, 0, nil
+	}
+	return false, 0, nil
+}
+
+func (m *matcher) evalRange(body map[string]interface{}, s scope) (bool, float64, error) {
+	field, _, bounds := fieldQuery(body, "")
+	values := m.values(field, s)
+
+	for _, v := range values {
+		inRange := true
+		for op, bound := range bounds {
+			var ok bool
+			switch op {
+			case "gte":
+				ok = compare(v, bound, m.now) >= 0
+			case "gt":
+				ok = compare(v, bound, m.now) > 0
+			case "lte":
+				ok = compare(v, bound, m.now) <= 0
+			case "lt":
+				ok = compare(v, bound, m.now) < 0
+			default:
+				ok = true
+			}
+			if !ok {
+				inRange = false
+				break
+			}
+		}
+		if inRange {
+			return true, boostOf(bounds, 1), nil
+		}
+	}
+	return false, 0, nil
+}
+
+func (m *matcher) evalPrefix(body map[string]interface{}, s scope) (bool, float64, error) {
+	field, value, options := fieldQuery(body, "value")
+	prefix := fmt.Sprint(value)
+	values := m.values(field, s)
+	for _, v := range values {
+		if text, ok := v.(string); ok && strings.HasPrefix(text, prefix) {
+			return true, boostOf(options, 1), nil
+		}
+	}
+	return false, 0, nil
+}
+
+func (m *matcher) evalIDs(body map[string]interface{}, s scope) (bool, float64, error) {
+	if s.doc == nil {
+		return false, 0, nil
+	}
+	for _, id := range toList(body["values"]) {
+		if fmt.Sprint(id) == s.doc.id {
+			return true, 1, nil
+		}
+	}
+	return false, 0, nil
+}
+
+// evalNested 任一嵌套对象匹配即匹配，得分取匹配对象的平均值
+func (m *matcher) evalNested(body map[string]interface{}, s scope) (bool, float64, error) {
+	path, _ := body["path"].(string)
+
+	matched, total := 0, 0.0
+	for _, child := range nestedScopes(s.fields, path) {
+		ok, score, err := m.eval(body["query"], child)
+		if err != nil {
+			return false, 0, err
+		}
+		if ok {
+			matched++
+			total += score
+		}
+	}
+
+	if matched == 0 {
+		return false, 0, nil
+	}
+	return true, total / float64(matched), nil
+}
+
+// nestedScopes 把path下的每个嵌套对象包装为独立的作用对象，字段仍使用完整路径访问
+func nestedScopes(fields map[string]interface{}, path string) []scope {
+	scopes := []scope{}
+	parts := strings.Split(path, ".")
+	for _, value := range lookup(fields, path) {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		wrapped := object
+		for i := len(parts) - 1; i >= 0; i-- {
+			wrapped = map[string]interface{}{parts[i]: wrapped}
+		}
+		scopes = append(scopes, scope{fields: wrapped})
+	}
+	return scopes
+}
+
+func (m *matcher) evalGeoDistance(body map[string]interface{}, s scope) (bool, float64, error) {
+	maxDistance, ok := parseDistance(body["distance"])
+	if !ok {
+		return false, 0, fmt.Errorf("%w: invalid geo distance %v", ErrUnsupportedQuery, body["distance"])
+	}
+
+	for field, raw := range body {
+		if field == "distance" || field == "distance_type" || field == "boost" {
+			continue
+		}
+
+		origin, ok := parseGeoPoint(raw)
+		if !ok {
+			return false, 0, fmt.Errorf("%w: invalid geo point %v", ErrUnsupportedQuery, raw)
+		}
+
+		for _, value := range lookup(s.fields, field) {
+			if point, ok := parseGeoPoint(value); ok && distance(origin, point) <= maxDistance {
+				return true, 1, nil
+			}
+		}
+		return false, 0, nil
+	}
+	return false, 0, nil
+}
+
+// evalFunctionScore 支持衰减函数、field_value_factor和weight，脚本无法执行，script_score不参与打分
+func (m *matcher) evalFunctionScore(body map[string]interface{}, s scope) (bool, float64, error) {
+	matched, queryScore, err := m.eval(body["query"], s)
+	if err != nil || !matched {
+		return false, 0, err
+	}
+
+	functions := toList(body["functions"])
+	if len(functions) == 0 {
+		functions = []interface{}{body}
+	}
+
+	scoreMode, _ := body["score_mode"].(string)
+	if len(scoreMode) == 0 {
+		scoreMode = "multiply"
+	}
+
+	scores := []float64{}
+	for _, raw := range functions {
+		function, _ := raw.(map[string]interface{})
+		if filter, ok := function["filter"]; ok {
+			ok, _, err := m.eval(filter, s)
+			if err != nil {
+				return false, 0, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		value, ok, err := m.function(function, s)
+		if err != nil {
+			return false, 0, err
+		}
+		if !ok {
+			continue
+		}
+
+		if weight, ok := function["weight"].(float64); ok {
+			value *= weight
+		}
+		scores = append(scores, value)
+	}
+
+	functionScore := combine(scores, scoreMode)
+	if maxBoost, ok := body["max_boost"].(float64); ok {
+		functionScore = math.Min(functionScore, maxBoost)
+	}
+
+	boostMode, _ := body["boost_mode"].(string)
+	score := combine([]float64{queryScore, functionScore}, boostMode)
+	if boostMode == "replace" {
+		score = functionScore
+	}
+
+	if minScore, ok := body["min_score"].(float64); ok && score < minScore {
+		return false, 0, nil
+	}
+	return true, score * boostOf(body, 1), nil
+}
+
+func combine(scores []float64, mode string) float64 {
+	if len(scores) == 0 {
+		if mode == "sum" {
+			return 0
+		}
+		return 1
+	}
+
+	result := scores[0]
+	for _, score := range scores[1:] {
+		switch mode {
+		case "sum", "avg":
+			result += score
+		case "max":
+			result = math.Max(result, score)
+		case "min":
+			result = math.Min(result, score)
+		case "first":
+		default:
+			result *= score
+		}
+	}
+	if mode == "avg" {
+		result /= float64(len(scores))
+	}
+	return result
+}
+
+// function 计算单个打分函数的值，ok为false表示该函数不参与打分
+func (m *matcher) function(function map[string]interface{}, s scope) (float64, bool, error) {
+	for name, raw := range function {
+		body, _ := raw.(map[string]interface{})
+		switch name {
+		case "gauss", "exp", "linear":
+			return m.decay(name, body, s)
+		case "field_value_factor":
+			return m.fieldValueFactor(body, s), true, nil
+		case "script_score", "random_score":
+			return 0, false, nil
+		}
+	}
+
+	// 只有weight时函数值为1，乘以weight
+	_, ok := function["weight"]
+	return 1, ok, nil
+}
+
+func (m *matcher) fieldValueFactor(body map[string]interface{}, s scope) float64 {
+	field, _ := body["field"].(string)
+	factor, ok := body["factor"].(float64)
+	if !ok {
+		factor = 1
+	}
+
+	value, ok := 0.0, false
+	for _, v := range lookup(s.fields, field) {
+		if value, ok = numeric(v, m.now); ok {
+			break
+		}
+	}
+	if !ok {
+		value, _ = body["missing"].(float64)
+	}
+	value *= factor
+
+	modifier, _ := body["modifier"].(string)
+	switch modifier {
+	case "log":
+		return math.Log10(value)
+	case "log1p":
+		return math.Log10(value + 1)
+	case "log2p":
+		return math.Log10(value + 2)
+	case "ln":
+		return math.Log(value)
+	case "ln1p":
+		return math.Log1p(value)
+	case "ln2p":
+		return math.Log(value + 2)
+	case "square":
+		return value * value
+	case "sqrt":
+		return math.Sqrt(value)
+	case "reciprocal":
+		return 1 / value
+	default:
+		return value
+	}
+}
+
+// decay 衰减函数，字段缺失时与es一样返回1
+func (m *matcher) decay(kind string, body map[string]interface{}, s scope) (float64, bool, error) {
+	for field, raw := range body {
+		if field == "multi_value_mode" {
+			continue
+		}
+		options, _ := raw.(map[string]interface{})
+		decay, ok := options["decay"].(float64)
+		if !ok {
+			decay = 0.5
+		}
+
+		values := lookup(s.fields, field)
+		if len(values) == 0 {
+			return 1, true, nil
+		}
+
+		var scale, offset float64
+		var distanceOf func(value interface{}) (float64, bool)
+		if origin, ok := parseGeoPoint(options["origin"]); ok {
+			scale, _ = parseDistance(options["scale"])
+			offset, _ = parseDistance(options["offset"])
+			distanceOf = func(value interface{}) (float64, bool) {
+				point, ok := parseGeoPoint(value)
+				return distance(origin, point), ok
+			}
+		} else {
+			origin, ok := m.decayNumber(options["origin"])
+			if !ok {
+				origin = float64(m.now.UnixMilli())
+			}
+			scale, _ = m.decayNumber(options["scale"])
+			offset, _ = m.decayNumber(options["offset"])
+			distanceOf = func(value interface{}) (float64, bool) {
+				number, ok := numeric(value, m.now)
+				return math.Abs(number - origin), ok
+			}
+		}
+		if scale <= 0 {
+			return 0, false, fmt.Errorf("%w: invalid decay scale %v", ErrUnsupportedQuery, options["scale"])
+		}
+
+		// 多值字段取距离最近的值
+		closest, found := math.Inf(1), false
+		for _, value := range values {
+			if d, ok := distanceOf(value); ok {
+				closest, found = math.Min(closest, d), true
+			}
+		}
+		if !found {
+			return 1, true, nil
+		}
+
+		d := math.Max(0, closest-offset)
+		switch kind {
+		case "exp":
+			return math.Exp(math.Log(decay) / scale * d), true, nil
+		case "linear":
+			limit := scale / (1 - decay)
+			return math.Max(0, (limit-d)/limit), true, nil
+		default:
+			sigmaSquare := -scale * scale / (2 * math.Log(decay))
+			return math.Exp(-d * d / (2 * sigmaSquare)), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// decayNumber 衰减函数中的数值、日期和时长，日期和时长统一为毫秒
+func (m *matcher) decayNumber(value interface{}) (float64, bool) {
+	if text, ok := value.(string); ok {
+		if duration, ok := parseDuration(text); ok {
+			return float64(duration.Milliseconds()), true
+		}
+	}
+	if value == nil {
+		return 0, false
+	}
+	return numeric(value, m.now)
+}
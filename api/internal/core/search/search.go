@@ -0,0 +1,606 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const defaultSize = 10
+
+// Result 与es搜索响应对应的结果，Aggregations和Suggest为es格式的json
+type Result struct {
+	Total        int64
+	Hits         []Hit
+	Aggregations map[string]json.RawMessage
+	Suggest      map[string]json.RawMessage
+}
+
+type Hit struct {
+	ID     string
+	Score  float64
+	Source json.RawMessage
+}
+
+type sortField struct {
+	field string
+	desc  bool
+	geo   *geoPoint
+}
+
+type candidate struct {
+	doc   *document
+	score float64
+	keys  []interface{}
+}
+
+// Search 执行es格式的搜索请求，支持query、sort、search_after、from/size、_source、aggs和suggest
+func (e *Engine) Search(name string, request map[string]interface{}) (Result, error) {
+	var result Result
+
+	normalized, err := normalize(request)
+	if err != nil {
+		return result, err
+	}
+	req, _ := normalized.(map[string]interface{})
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return result, err
+	}
+
+	m := newMatcher(ix)
+	candidates, err := m.collect(req["query"])
+	if err != nil {
+		return result, err
+	}
+	result.Total = int64(len(candidates))
+
+	sorts, err := parseSort(req["sort"])
+	if err != nil {
+		return result, err
+	}
+	for i := range candidates {
+		candidates[i].keys = m.sortKeys(candidates[i], sorts)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return m.less(candidates[i], candidates[j], sorts)
+	})
+
+	page := candidates
+	if after := toList(req["search_after"]); len(after) > 0 {
+		start := sort.Search(len(page), func(i int) bool {
+			return m.compareKeys(page[i].keys, after, sorts) > 0
+		})
+		page = page[start:]
+	}
+
+	from, size := 0, defaultSize
+	if v, ok := req["from"].(float64); ok {
+		from = int(v)
+	}
+	if v, ok := req["size"].(float64); ok {
+		size = int(v)
+	}
+	from = min(max(from, 0), len(page))
+	page = page[from:min(from+max(size, 0), len(page))]
+
+	result.Hits = make([]Hit, 0, len(page))
+	for _, c := range page {
+		source, err := filterSource(c.doc, req["_source"])
+		if err != nil {
+			return result, err
+		}
+		result.Hits = append(result.Hits, Hit{ID: c.doc.id, Score: c.score, Source: source})
+	}
+
+	if aggs, ok := req["aggs"].(map[string]interface{}); ok {
+		scopes := make([]scope, 0, len(candidates))
+		for _, c := range candidates {
+			scopes = append(scopes, rootScope(c.doc))
+		}
+		if result.Aggregations, err = m.aggregate(aggs, scopes); err != nil {
+			return result, err
+		}
+	}
+
+	if suggest, ok := req["suggest"].(map[string]interface{}); ok {
+		if result.Suggest, err = suggestIndex(ix, suggest); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// Count 统计匹配query的文档数
+func (e *Engine) Count(name string, query map[string]interface{}) (int64, error) {
+	normalized, err := normalize(query)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return 0, err
+	}
+
+	candidates, err := newMatcher(ix).collect(normalized)
+	return int64(len(candidates)), err
+}
+
+// Suggest 执行es格式的suggest请求
+func (e *Engine) Suggest(name string, suggest map[string]interface{}) (map[string]json.RawMessage, error) {
+	normalized, err := normalize(suggest)
+	if err != nil {
+		return nil, err
+	}
+	s, _ := normalized.(map[string]interface{})
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ix, err := e.index(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestIndex(ix, s)
+}
+
+func (m *matcher) collect(query interface{}) ([]candidate, error) {
+	candidates := []candidate{}
+	for _, id := range m.ix.sortedIDs() {
+		doc := m.ix.docs[id]
+		matched, score, err := m.eval(query, rootScope(doc))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			candidates = append(candidates, candidate{doc: doc, score: score})
+		}
+	}
+	return candidates, nil
+}
+
+// parseSort 解析sort，未指定时按得分降序，最后都按文档id升序保证顺序稳定
+func parseSort(raw interface{}) ([]sortField, error) {
+	sorts := []sortField{}
+	for _, item := range toList(raw) {
+		switch v := item.(type) {
+		case string:
+			sorts = append(sorts, sortField{field: v, desc: v == "_score"})
+		case map[string]interface{}:
+			for field, options := range v {
+				s := sortField{field: field, desc: field == "_score"}
+				switch o := options.(type) {
+				case string:
+					s.desc = o == "desc"
+				case map[string]interface{}:
+					if order, ok := o["order"].(string); ok {
+						s.desc = order == "desc"
+					}
+					if field == "_geo_distance" {
+						for key, value := range o {
+							if point, ok := parseGeoPoint(value); ok {
+								s.field = key
+								s.geo = &point
+							}
+						}
+						if s.geo == nil {
+							return nil, fmt.Errorf("%w: invalid _geo_distance sort", ErrUnsupportedQuery)
+						}
+					}
+				}
+				sorts = append(sorts, s)
+			}
+		}
+	}
+
+	if len(sorts) == 0 {
+		sorts = append(sorts, sortField{field: "_score", desc: true})
+	}
+	return sorts, nil
+}
+
+// sortKeys 多值字段升序取最小值，降序取最大值，缺失时为nil并排在最后
+func (m *matcher) sortKeys(c candidate, sorts []sortField) []interface{} {
+	keys := make([]interface{}, 0, len(sorts))
+	for _, s := range sorts {
+		switch {
+		case s.field == "_score":
+			keys = append(keys, c.score)
+		case s.field == "_id":
+			keys = append(keys, c.doc.id)
+		case s.geo != nil:
+			var key interface{}
+			for _, value := range lookup(c.doc.fields, s.field) {
+				if point, ok := parseGeoPoint(value); ok {
+					d := distance(*s.geo, point)
+					if current, ok := key.(float64); !ok || (s.desc && d > current) || (!s.desc && d < current) {
+						key = d
+					}
+				}
+			}
+			keys = append(keys, key)
+		default:
+			source, _ := m.ix.resolve(s.field)
+			var key interface{}
+			for _, value := range lookup(c.doc.fields, source) {
+				if key == nil || (s.desc && compare(value, key, m.now) > 0) || (!s.desc && compare(value, key, m.now) < 0) {
+					key = value
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m *matcher) compareKeys(a, b []interface{}, sorts []sortField) int {
+	for i, s := range sorts {
+		if i >= len(a) || i >= len(b) {
+			break
+		}
+
+		x, y := a[i], b[i]
+		switch {
+		case x == nil && y == nil:
+			continue
+		case x == nil:
+			return 1
+		case y == nil:
+			return -1
+		}
+
+		c := compare(x, y, m.now)
+		if s.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (m *matcher) less(a, b candidate, sorts []sortField) bool {
+	if c := m.compareKeys(a.keys, b.keys, sorts); c != 0 {
+		return c < 0
+	}
+	return a.doc.id < b.doc.id
+}
+
+// filterSource 按_source返回文档，false时不返回，字段列表时只保留对应的顶层字段
+func filterSource(doc *document, raw interface{}) (json.RawMessage, error) {
+	switch v := raw.(type) {
+	case bool:
+		if !v {
+			return nil, nil
+		}
+	case string:
+		return pickFields(doc, []interface{}{v})
+	case []interface{}:
+		return pickFields(doc, v)
+	}
+	return doc.source, nil
+}
+
+func pickFields(doc *document, fields []interface{}) (json.RawMessage, error) {
+	picked := make(map[string]interface{}, len(fields))
+	for _, raw := range fields {
+		field, _ := raw.(string)
+		top, _, _ := strings.Cut(field, ".")
+		if value, ok := doc.fields[top]; ok {
+			picked[top] = value
+		}
+	}
+	return json.Marshal(picked)
+}
+
+// aggregate 支持terms、histogram、range、nested、filter和min/max/avg/sum/value_count
+func (m *matcher) aggregate(aggs map[string]interface{}, scopes []scope) (map[string]json.RawMessage, error) {
+	result := make(map[string]json.RawMessage, len(aggs))
+	for name, raw := range aggs {
+		definition, _ := raw.(map[string]interface{})
+		value, err := m.aggregation(definition, scopes)
+		if err != nil {
+			return nil, fmt.Errorf("aggregation %s: %w", name, err)
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = data
+	}
+	return result, nil
+}
+
+func (m *matcher) aggregation(definition map[string]interface{}, scopes []scope) (map[string]interface{}, error) {
+	subAggs, _ := definition["aggs"].(map[string]interface{})
+	if subAggs == nil {
+		subAggs, _ = definition["aggregations"].(map[string]interface{})
+	}
+
+	withSub := func(value map[string]interface{}, scopes []scope) (map[string]interface{}, error) {
+		if len(subAggs) == 0 {
+			return value, nil
+		}
+		sub, err := m.aggregate(subAggs, scopes)
+		if err != nil {
+			return nil, err
+		}
+		for key, raw := range sub {
+			value[key] = raw
+		}
+		return value, nil
+	}
+
+	for typ, raw := range definition {
+		body, _ := raw.(map[string]interface{})
+		switch typ {
+		case "aggs", "aggregations":
+			continue
+		case "terms":
+			return m.termsAggregation(body, scopes, withSub)
+		case "histogram":
+			return m.histogramAggregation(body, scopes, withSub)
+		case "range":
+			return m.rangeAggregation(body, scopes, withSub)
+		case "nested":
+			path, _ := body["path"].(string)
+			nested := []scope{}
+			for _, s := range scopes {
+				nested = append(nested, nestedScopes(s.fields, path)...)
+			}
+			return withSub(map[string]interface{}{"doc_count": len(nested)}, nested)
+		case "filter":
+			filtered := []scope{}
+			for _, s := range scopes {
+				matched, _, err := m.eval(body, s)
+				if err != nil {
+					return nil, err
+				}
+				if matched {
+					filtered = append(filtered, s)
+				}
+			}
+			return withSub(map[string]interface{}{"doc_count": len(filtered)}, filtered)
+		case "min", "max", "avg", "sum", "value_count":
+			return m.metricAggregation(typ, body, scopes), nil
+		default:
+			return nil, fmt.Errorf("%w: aggregation %s", ErrUnsupportedQuery, typ)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: empty aggregation", ErrUnsupportedQuery)
+}
+
+type bucket struct {
+	key    interface{}
+	scopes []scope
+}
+
+type subAggregator func(value map[string]interface{}, scopes []scope) (map[string]interface{}, error)
+
+// termsAggregation 按文档数降序、值升序排列，与es的默认顺序一致
+func (m *matcher) termsAggregation(body map[string]interface{}, scopes []scope, withSub subAggregator) (map[string]interface{}, error) {
+	field, _ := body["field"].(string)
+	source, _ := m.ix.resolve(field)
+	size := defaultSize
+	if v, ok := body["size"].(float64); ok {
+		size = int(v)
+	}
+
+	groups := map[string]*bucket{}
+	for _, s := range scopes {
+		seen := map[string]bool{}
+		for _, value := range lookup(s.fields, source) {
+			key := fmt.Sprint(value)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if groups[key] == nil {
+				groups[key] = &bucket{key: value}
+			}
+			groups[key].scopes = append(groups[key].scopes, s)
+		}
+	}
+
+	ordered := make([]*bucket, 0, len(groups))
+	for _, b := range groups {
+		ordered = append(ordered, b)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if len(ordered[i].scopes) != len(ordered[j].scopes) {
+			return len(ordered[i].scopes) > len(ordered[j].scopes)
+		}
+		return compare(ordered[i].key, ordered[j].key, m.now) < 0
+	})
+
+	others := 0
+	if len(ordered) > size {
+		for _, b := range ordered[size:] {
+			others += len(b.scopes)
+		}
+		ordered = ordered[:size]
+	}
+
+	buckets, err := m.buckets(ordered, withSub)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"doc_count_error_upper_bound": 0,
+		"sum_other_doc_count":         others,
+		"buckets":                     buckets,
+	}, nil
+}
+
+func (m *matcher) histogramAggregation(body map[string]interface{}, scopes []scope, withSub subAggregator) (map[string]interface{}, error) {
+	field, _ := body["field"].(string)
+	interval, _ := body["interval"].(float64)
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: histogram interval must be positive", ErrUnsupportedQuery)
+	}
+	minDocCount := 0
+	if v, ok := body["min_doc_count"].(float64); ok {
+		minDocCount = int(v)
+	}
+
+	groups := map[float64]*bucket{}
+	for _, s := range scopes {
+		seen := map[float64]bool{}
+		for _, value := range lookup(s.fields, field) {
+			number, ok := numeric(value, m.now)
+			if !ok {
+				continue
+			}
+			key := math.Floor(number/interval) * interval
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if groups[key] == nil {
+				groups[key] = &bucket{key: key}
+			}
+			groups[key].scopes = append(groups[key].scopes, s)
+		}
+	}
+
+	ordered := make([]*bucket, 0, len(groups))
+	if len(groups) > 0 && minDocCount == 0 {
+		// min_doc_count为0时补齐区间内的空桶
+		low, high := math.Inf(1), math.Inf(-1)
+		for key := range groups {
+			low, high = math.Min(low, key), math.Max(high, key)
+		}
+		first, last := math.Round(low/interval), math.Round(high/interval)
+		for n := first; n <= last; n++ {
+			if key := n * interval; groups[key] == nil {
+				groups[key] = &bucket{key: key}
+			}
+		}
+	}
+	for _, b := range groups {
+		if len(b.scopes) >= minDocCount {
+			ordered = append(ordered, b)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].key.(float64) < ordered[j].key.(float64)
+	})
+
+	buckets, err := m.buckets(ordered, withSub)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"buckets": buckets}, nil
+}
+
+func (m *matcher) rangeAggregation(body map[string]interface{}, scopes []scope, withSub subAggregator) (map[string]interface{}, error) {
+	field, _ := body["field"].(string)
+
+	buckets := []interface{}{}
+	for _, raw := range toList(body["ranges"]) {
+		r, _ := raw.(map[string]interface{})
+		from, hasFrom := r["from"].(float64)
+		to, hasTo := r["to"].(float64)
+
+		matched := []scope{}
+		for _, s := range scopes {
+			for _, value := range lookup(s.fields, field) {
+				number, ok := numeric(value, m.now)
+				if ok && (!hasFrom || number >= from) && (!hasTo || number < to) {
+					matched = append(matched, s)
+					break
+				}
+			}
+		}
+
+		value := map[string]interface{}{"doc_count": len(matched)}
+		if key, ok := r["key"]; ok {
+			value["key"] = key
+		}
+		if hasFrom {
+			value["from"] = from
+		}
+		if hasTo {
+			value["to"] = to
+		}
+
+		value, err := withSub(value, matched)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, value)
+	}
+
+	return map[string]interface{}{"buckets": buckets}, nil
+}
+
+func (m *matcher) buckets(ordered []*bucket, withSub subAggregator) ([]interface{}, error) {
+	buckets := make([]interface{}, 0, len(ordered))
+	for _, b := range ordered {
+		value, err := withSub(map[string]interface{}{
+			"key":       b.key,
+			"doc_count": len(b.scopes),
+		}, b.scopes)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, value)
+	}
+	return buckets, nil
+}
+
+func (m *matcher) metricAggregation(typ string, body map[string]interface{}, scopes []scope) map[string]interface{} {
+	field, _ := body["field"].(string)
+
+	count, sum := 0, 0.0
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, s := range scopes {
+		for _, value := range lookup(s.fields, field) {
+			number, ok := numeric(value, m.now)
+			if !ok {
+				continue
+			}
+			count++
+			sum += number
+			low, high = math.Min(low, number), math.Max(high, number)
+		}
+	}
+
+	var value interface{}
+	switch typ {
+	case "value_count":
+		value = count
+	case "sum":
+		value = sum
+	case "min":
+		if count > 0 {
+			value = low
+		}
+	case "max":
+		if count > 0 {
+			value = high
+		}
+	case "avg":
+		if count > 0 {
+			value = sum / float64(count)
+		}
+	}
+	return map[string]interface{}{"value": value}
+}
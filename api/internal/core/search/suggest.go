@@ -0,0 +1,169 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// suggestIndex 支持completion和phrase两种suggester
+func suggestIndex(ix *index, suggest map[string]interface{}) (map[string]json.RawMessage, error) {
+	globalText, _ := suggest["text"].(string)
+
+	result := make(map[string]json.RawMessage, len(suggest))
+	for name, raw := range suggest {
+		definition, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var entries []map[string]interface{}
+		switch {
+		case definition["completion"] != nil:
+			prefix, _ := definition["prefix"].(string)
+			options, _ := definition["completion"].(map[string]interface{})
+			entries = completion(ix, prefix, options)
+		case definition["phrase"] != nil:
+			text, ok := definition["text"].(string)
+			if !ok {
+				text = globalText
+			}
+			options, _ := definition["phrase"].(map[string]interface{})
+			entries = phrase(ix, text, options)
+		default:
+			return nil, fmt.Errorf("%w: suggester %s", ErrUnsupportedQuery, name)
+		}
+
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = data
+	}
+
+	return result, nil
+}
+
+// completion 前缀匹配补全字段的输入，被更多文档使用的输入排在前面；拼音等子字段无法分析，不返回结果
+func completion(ix *index, prefix string, options map[string]interface{}) []map[string]interface{} {
+	field, _ := options["field"].(string)
+	size := 5
+	if v, ok := options["size"].(float64); ok {
+		size = int(v)
+	}
+
+	counts := map[string]int{}
+	if _, isSubField := ix.parents[field]; !isSubField && len(prefix) > 0 {
+		lower := strings.ToLower(prefix)
+		for _, doc := range ix.docs {
+			seen := map[string]bool{}
+			for _, value := range lookup(doc.fields, field) {
+				for _, input := range completionInputs(value) {
+					if !seen[input] && strings.HasPrefix(strings.ToLower(input), lower) {
+						seen[input] = true
+						counts[input]++
+					}
+				}
+			}
+		}
+	}
+
+	inputs := make([]string, 0, len(counts))
+	for input := range counts {
+		inputs = append(inputs, input)
+	}
+	sort.Slice(inputs, func(i, j int) bool {
+		if counts[inputs[i]] != counts[inputs[j]] {
+			return counts[inputs[i]] > counts[inputs[j]]
+		}
+		return inputs[i] < inputs[j]
+	})
+	if len(inputs) > size {
+		inputs = inputs[:size]
+	}
+
+	suggestions := make([]map[string]interface{}, 0, len(inputs))
+	for _, input := range inputs {
+		suggestions = append(suggestions, map[string]interface{}{
+			"text":   input,
+			"_score": float64(counts[input]),
+		})
+	}
+
+	return []map[string]interface{}{{
+		"text":    prefix,
+		"offset":  0,
+		"length":  len([]rune(prefix)),
+		"options": suggestions,
+	}}
+}
+
+// completionInputs 补全字段可以是字符串或{"input": [...]}
+func completionInputs(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[string]interface{}:
+		inputs := []string{}
+		for _, input := range toList(v["input"]) {
+			if text, ok := input.(string); ok {
+				inputs = append(inputs, text)
+			}
+		}
+		return inputs
+	}
+	return nil
+}
+
+// phrase 用字段的词典纠正拼写错误的英文单词，中文按两字切分后无法纠错，原样保留
+func phrase(ix *index, text string, options map[string]interface{}) []map[string]interface{} {
+	field, _ := options["field"].(string)
+	source, _ := ix.resolve(field)
+	dictionary := ix.docFreq[source]
+
+	changed := false
+	words := strings.Fields(text)
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		if !isWord(lower) || dictionary[lower] > 0 {
+			continue
+		}
+
+		// 短词只允许一处编辑，避免纠正成无关的词
+		maxEdits := 1
+		if len([]rune(lower)) >= 5 {
+			maxEdits = 2
+		}
+
+		best, bestDistance, bestFreq := "", maxEdits+1, 0
+		for term, freq := range dictionary {
+			if !isWord(term) {
+				continue
+			}
+			d := editDistance(lower, term)
+			if d < bestDistance || (d == bestDistance && (freq > bestFreq || (freq == bestFreq && term < best))) {
+				best, bestDistance, bestFreq = term, d, freq
+			}
+		}
+		if len(best) > 0 && bestDistance <= maxEdits {
+			words[i] = best
+			changed = true
+		}
+	}
+
+	suggestions := []map[string]interface{}{}
+	if changed {
+		suggestions = append(suggestions, map[string]interface{}{
+			"text":  strings.Join(words, " "),
+			"score": 1,
+		})
+	}
+
+	return []map[string]interface{}{{
+		"text":    text,
+		"offset":  0,
+		"length":  len([]rune(text)),
+		"options": suggestions,
+	}}
+}
@@ -0,0 +1,233 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const earthRadiusKilometers = 6371.0
+
+// lookup 按点分路径取出字段的所有值，路径上的数组会被展开
+func lookup(fields map[string]interface{}, path string) []interface{} {
+	values := []interface{}{fields}
+	for _, part := range strings.Split(path, ".") {
+		next := []interface{}{}
+		for _, value := range values {
+			node, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if child, ok := node[part]; ok && child != nil {
+				next = append(next, flatten(child)...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func flatten(value interface{}) []interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return []interface{}{value}
+	}
+
+	values := []interface{}{}
+	for _, item := range array {
+		if item != nil {
+			values = append(values, flatten(item)...)
+		}
+	}
+	return values
+}
+
+func toList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+// numeric 把数字、数字字符串和日期转换为可比较的数值，日期为毫秒时间戳
+func numeric(value interface{}, now time.Time) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if number, err := strconv.ParseFloat(v, 64); err == nil {
+			return number, true
+		}
+		if date, ok := parseDate(v, now); ok {
+			return float64(date.UnixMilli()), true
+		}
+	}
+	return 0, false
+}
+
+// compare 比较两个字段值，都能转换为数值时按数值比较，否则按字符串比较
+func compare(a, b interface{}, now time.Time) int {
+	if x, ok := numeric(a, now); ok {
+		if y, ok := numeric(b, now); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// equal term查询的相等判断，字符串与数值、布尔值之间按字面值比较
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return x == y
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", time.DateTime, time.DateOnly}
+
+// parseDate 解析日期和now、now-7d这样的日期表达式
+func parseDate(value string, now time.Time) (time.Time, bool) {
+	if strings.HasPrefix(value, "now") {
+		expression := strings.TrimPrefix(value, "now")
+		if len(expression) == 0 {
+			return now, true
+		}
+
+		sign := time.Duration(1)
+		switch expression[0] {
+		case '-':
+			sign = -1
+		case '+':
+		default:
+			return time.Time{}, false
+		}
+
+		duration, ok := parseDuration(expression[1:])
+		if !ok {
+			return time.Time{}, false
+		}
+		return now.Add(sign * duration), true
+	}
+
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseDuration 解析7d、12h、30m这样的时长
+func parseDuration(value string) (time.Duration, bool) {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"ms", time.Millisecond},
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"H", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+
+	for _, u := range units {
+		if number, ok := strings.CutSuffix(value, u.suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, false
+			}
+			return time.Duration(n * float64(u.unit)), true
+		}
+	}
+	return 0, false
+}
+
+// parseDistance 解析10km、500m这样的距离，返回公里，没有单位时按米计算
+func parseDistance(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v / 1000, true
+	case string:
+		units := []struct {
+			suffix string
+			factor float64
+		}{
+			{"km", 1},
+			{"mi", 1.609344},
+			{"m", 0.001},
+		}
+		for _, u := range units {
+			if number, ok := strings.CutSuffix(v, u.suffix); ok {
+				n, err := strconv.ParseFloat(number, 64)
+				return n * u.factor, err == nil
+			}
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		return n / 1000, err == nil
+	}
+	return 0, false
+}
+
+type geoPoint struct {
+	lat float64
+	lon float64
+}
+
+// parseGeoPoint 支持{"lat":..,"lon":..}和"lat,lon"两种格式
+func parseGeoPoint(value interface{}) (geoPoint, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		lat, latOK := v["lat"].(float64)
+		lon, lonOK := v["lon"].(float64)
+		return geoPoint{lat, lon}, latOK && lonOK
+	case string:
+		parts := strings.Split(v, ",")
+		if len(parts) != 2 {
+			return geoPoint{}, false
+		}
+		lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		return geoPoint{lat, lon}, err1 == nil && err2 == nil
+	}
+	return geoPoint{}, false
+}
+
+// distance 两点间的球面距离，公里
+func distance(a, b geoPoint) float64 {
+	toRadians := func(degree float64) float64 { return degree * math.Pi / 180 }
+
+	dLat := toRadians(b.lat - a.lat)
+	dLon := toRadians(b.lon - a.lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(a.lat))*math.Cos(toRadians(b.lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKilometers * math.Asin(math.Sqrt(h))
+}
@@ -40,6 +40,10 @@ func Init(zapLogger *zap.Logger) {
 }
 
 func autoMigrate() error {
+	if err := renameLegacySearchHistory(); err != nil {
+		return err
+	}
+
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.Product{},
@@ -51,7 +55,15 @@ func autoMigrate() error {
 		&models.ProductAttribute{},
 		&models.Message{},
 		&models.Conversation{},
+		&models.MessageDeletion{},
+		&models.MessageReport{},
+		&models.UserBlock{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.IndexOutbox{},
 		&models.SearchHistory{},
+		&models.SavedSearch{},
+		&models.SearchAlert{},
 		&models.Like{},
 		&models.Credit{},
 		&models.OrderComment{},
@@ -60,7 +72,11 @@ func autoMigrate() error {
 		&models.InterestTag{},
 		&models.UserInterests{},
 		&models.Feedback{},
+		&models.FeedImpression{},
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return migrateLegacySearchHistory()
 }
@@ -0,0 +1,34 @@
+package db
+
+import "github.com/mislu/market-api/internal/types/models"
+
+const legacySearchHistoryTable = "search_history_legacy"
+
+// renameLegacySearchHistory 旧版搜索历史每次搜索插入一行，迁移前先改名，由migrateLegacySearchHistory合并
+func renameLegacySearchHistory() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.SearchHistory{}) || migrator.HasColumn(&models.SearchHistory{}, "normalized") {
+		return nil
+	}
+
+	return migrator.RenameTable(&models.SearchHistory{}, legacySearchHistoryTable)
+}
+
+// migrateLegacySearchHistory 按用户和关键词合并旧记录，重复执行时忽略已合并的行
+func migrateLegacySearchHistory() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(legacySearchHistoryTable) {
+		return nil
+	}
+
+	err := DB.Exec(`INSERT IGNORE INTO search_history (user_id, keyword, normalized, hit_count, search_time)
+		SELECT user_id, MAX(keyword), LOWER(TRIM(keyword)), COUNT(*), MAX(search_time)
+		FROM search_history_legacy
+		WHERE user_id <> '' AND TRIM(keyword) <> ''
+		GROUP BY user_id, LOWER(TRIM(keyword))`).Error
+	if err != nil {
+		return err
+	}
+
+	return migrator.DropTable(legacySearchHistoryTable)
+}
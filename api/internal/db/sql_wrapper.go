@@ -177,6 +177,12 @@ func Dec[T genericComparableConstraint](updates map[string]T) GenericQuery {
 	}
 }
 
+func Set(updates map[string]any) GenericQuery {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.UpdateColumns(updates)
+	}
+}
+
 func Model(model any) GenericQuery {
 	return func(tx *gorm.DB) *gorm.DB {
 		return tx.Model(model)
@@ -241,6 +247,16 @@ func InArray(field string, value interface{}) GenericQuery {
 	}
 }
 
+// DeleteByQuery 删除满足查询条件的T，条件不能为空
+func DeleteByQuery[T any](query ...GenericQuery) error {
+	var model T
+	tmp := DB
+	for _, q := range query {
+		tmp = q(tmp)
+	}
+	return tmp.Delete(&model).Error
+}
+
 func Run(query ...GenericQuery) error {
 	tmp := DB
 	for _, q := range query {
@@ -251,6 +267,16 @@ func Run(query ...GenericQuery) error {
 	return tmp.Error
 }
 
+// RunAffected 执行查询并返回影响的行数，用于条件更新
+func RunAffected(query ...GenericQuery) (int64, error) {
+	tmp := DB
+	for _, q := range query {
+		tmp = q(tmp)
+	}
+
+	return tmp.RowsAffected, tmp.Error
+}
+
 func GetAny[T any](sql string, data ...interface{}) (T /* data */, error) {
 	var result T
 	err := DB.Raw(sql, data...).Scan(&result).Error
@@ -0,0 +1,309 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ErrIncompatibleMapping 映射变更无法在原索引上完成，需要重建索引
+var ErrIncompatibleMapping = errors.New("incompatible mapping")
+
+// versionSeparator 版本化索引的命名格式为 <alias>_v<n>
+const versionSeparator = "_v"
+
+// ensureAlias 确保别名存在并指向一个版本化索引
+func ensureAlias(alias string, mapping map[string]interface{}) error {
+	indices, err := AliasIndices(alias)
+	if err != nil {
+		return err
+	}
+
+	if len(indices) > 0 {
+		for _, index := range indices {
+			if err := updateMapping(index, mapping); err != nil {
+				if errors.Is(err, ErrIncompatibleMapping) {
+					log.Printf("⚠️ Index [%s] mapping is incompatible, run cmd/reindex to rebuild: %v", index, err)
+					continue
+				}
+				return err
+			}
+		}
+		return nil
+	}
+
+	exists, err := indexExists(alias)
+	if err != nil {
+		return err
+	}
+	if exists {
+		// 旧版本直接以别名为索引名创建，保持可用，等待reindex命令迁移
+		log.Printf("⚠️ Index [%s] is a concrete index, run cmd/reindex to migrate it behind an alias", alias)
+		if err := updateMapping(alias, mapping); err != nil && !errors.Is(err, ErrIncompatibleMapping) {
+			return err
+		}
+		return nil
+	}
+
+	return createIndex(alias+versionSeparator+"1", mapping, map[string]interface{}{
+		alias: map[string]interface{}{"is_write_index": true},
+	})
+}
+
+// AliasIndices 返回别名当前指向的索引
+func AliasIndices(alias string) ([]string, error) {
+	req := esapi.IndicesGetAliasRequest{Name: []string{alias}}
+	res, err := req.Do(context.Background(), client)
+	if err != nil {
+		return nil, fmt.Errorf("获取别名失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	var r map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(r))
+	for index := range r {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+
+	return indices, nil
+}
+
+func indexExists(index string) (bool, error) {
+	req := esapi.IndicesExistsRequest{Index: []string{index}}
+	res, err := req.Do(context.Background(), client)
+	if err != nil {
+		return false, fmt.Errorf("检查索引失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// CreateVersionedIndex 为别名创建下一个版本的索引，构建期间关闭刷新以加快写入
+func CreateVersionedIndex(alias string) (string, error) {
+	mapping, err := Mapping(alias)
+	if err != nil {
+		return "", err
+	}
+
+	req := esapi.IndicesGetRequest{Index: []string{alias + versionSeparator + "*"}}
+	res, err := req.Do(context.Background(), client)
+	if err != nil {
+		return "", fmt.Errorf("获取索引失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	version := 0
+	if res.StatusCode == http.StatusOK {
+		var r map[string]json.RawMessage
+		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+			return "", err
+		}
+		for index := range r {
+			if v, err := strconv.Atoi(strings.TrimPrefix(index, alias+versionSeparator)); err == nil && v > version {
+				version = v
+			}
+		}
+	}
+
+	settings := map[string]interface{}{"refresh_interval": "-1"}
+	if base, ok := mapping["settings"].(map[string]interface{}); ok {
+		for key, value := range base {
+			settings[key] = value
+		}
+	}
+	body := map[string]interface{}{
+		"settings": settings,
+		"mappings": mapping["mappings"],
+	}
+
+	index := alias + versionSeparator + strconv.Itoa(version+1)
+	if err := createIndex(index, body, nil); err != nil {
+		return "", err
+	}
+
+	return index, nil
+}
+
+// FinishIndex 恢复刷新间隔并刷新，使新索引可被搜索
+func FinishIndex(index string) error {
+	settings := esapi.IndicesPutSettingsRequest{
+		Index: []string{index},
+		Body:  strings.NewReader(`{"index":{"refresh_interval":"1s"}}`),
+	}
+	res, err := settings.Do(context.Background(), client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	refresh := esapi.IndicesRefreshRequest{Index: []string{index}}
+	refreshRes, err := refresh.Do(context.Background(), client)
+	if err != nil {
+		return err
+	}
+	defer refreshRes.Body.Close()
+
+	if refreshRes.IsError() {
+		return fmt.Errorf("%w: %s", errESRequestFailed, refreshRes.String())
+	}
+
+	return nil
+}
+
+// SwapAlias 原子地把别名切换到index，返回之前指向的索引
+func SwapAlias(alias string, index string) ([]string, error) {
+	old, err := AliasIndices(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]map[string]interface{}, 0, len(old)+2)
+	for _, oldIndex := range old {
+		if oldIndex == index {
+			continue
+		}
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]interface{}{"index": oldIndex, "alias": alias},
+		})
+	}
+
+	if len(old) == 0 {
+		// 别名同名的旧索引需在同一请求中删除，否则无法创建别名
+		exists, err := indexExists(alias)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			actions = append(actions, map[string]interface{}{
+				"remove_index": map[string]interface{}{"index": alias},
+			})
+		}
+	}
+
+	actions = append(actions, map[string]interface{}{
+		"add": map[string]interface{}{"index": index, "alias": alias, "is_write_index": true},
+	})
+
+	data, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return nil, err
+	}
+
+	req := esapi.IndicesUpdateAliasesRequest{Body: bytes.NewReader(data)}
+	res, err := req.Do(context.Background(), client)
+	if err != nil {
+		return nil, fmt.Errorf("切换别名失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	log.Printf("✅ Alias [%s] now points to [%s]", alias, index)
+	return old, nil
+}
+
+// DeleteIndices 删除不再被别名引用的旧索引
+func DeleteIndices(indices []string) error {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	req := esapi.IndicesDeleteRequest{Index: indices}
+	res, err := req.Do(context.Background(), client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	return nil
+}
+
+func (elasticEngine) BulkIndex(index string, docs map[string]interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for id, doc := range docs {
+		if err := encoder.Encode(map[string]interface{}{
+			"index": map[string]interface{}{"_index": index, "_id": id},
+		}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	req := esapi.BulkRequest{Body: &buf}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	res, err := req.Do(timeoutCtx, client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	var r struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string          `json:"_id"`
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return err
+	}
+
+	if r.Errors {
+		for _, item := range r.Items {
+			for _, result := range item {
+				if len(result.Error) > 0 {
+					return fmt.Errorf("%w: document %s: %s", errESRequestFailed, result.ID, string(result.Error))
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -9,179 +9,500 @@ import (
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/mislu/market-api/internal/utils/app"
 )
 
 var client *elasticsearch.Client
 
+// pinyinEnabled 配置开启且es安装了analysis-pinyin插件时，商品索引才包含拼音子字段
+var pinyinEnabled bool
+
+const (
+	defaultAddress      = "http://localhost:9200"
+	defaultProductIndex = "m-market"
+	defaultMessageIndex = "m-market-message"
+	pinyinPlugin        = "analysis-pinyin"
+)
+
+var (
+	// ProductIndex 商品索引的读写别名
+	ProductIndex = defaultProductIndex
+	// MessageIndex 聊天消息索引的读写别名，与商品索引分开
+	MessageIndex = defaultMessageIndex
+)
+
 func Init() {
+	config := app.GetConfig().ES
+
+	if len(config.Indices.Product) > 0 {
+		ProductIndex = config.Indices.Product
+	}
+	if len(config.Indices.Message) > 0 {
+		MessageIndex = config.Indices.Message
+	}
+
+	e, err := newEngine(config.Engine)
+	if err != nil {
+		panic(err)
+	}
+	engine = e
+
+	if err := InitIndex(); err != nil {
+		panic(err)
+	}
+}
+
+func newElasticEngine() (SearchEngine, error) {
+	config := app.GetConfig().ES
+
+	addresses := config.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{defaultAddress}
+	}
+
 	cfg := elasticsearch.Config{
-		Addresses: []string{
-			"http://localhost:9200",
-		},
+		Addresses: addresses,
+		Username:  config.Username,
+		Password:  config.Password,
 	}
 	cli, err := elasticsearch.NewClient(cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	res, err := cli.Info()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	client = cli
 
-	if err := InitIndex(); err != nil {
-		panic(err)
+	if config.Pinyin {
+		installed, err := pluginInstalled(pinyinPlugin)
+		if err != nil {
+			return nil, err
+		}
+		if !installed {
+			log.Printf("⚠️ Elasticsearch plugin %s is not installed, pinyin search is disabled", pinyinPlugin)
+		}
+		pinyinEnabled = installed
 	}
+
+	return elasticEngine{}, nil
+}
+
+// PinyinEnabled 是否可以按拼音搜索和补全
+func PinyinEnabled() bool {
+	return pinyinEnabled
+}
+
+// pluginInstalled 检查集群中所有节点是否都安装了插件
+func pluginInstalled(name string) (bool, error) {
+	res, err := client.Cat.Plugins(
+		client.Cat.Plugins.WithFormat("json"),
+		client.Cat.Plugins.WithH("name", "component"),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return false, fmt.Errorf("failed to list plugins: %s", res.String())
+	}
+
+	var plugins []struct {
+		Name      string `json:"name"`
+		Component string `json:"component"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&plugins); err != nil {
+		return false, err
+	}
+
+	nodes := map[string]bool{}
+	installed := map[string]bool{}
+	for _, plugin := range plugins {
+		nodes[plugin.Name] = true
+		if plugin.Component == name {
+			installed[plugin.Name] = true
+		}
+	}
+
+	return len(installed) > 0 && len(installed) == len(nodes), nil
 }
 
 func InitIndex() error {
-    mapping := map[string]interface{}{
-        "settings": map[string]interface{}{
-            "number_of_shards":   1,
-            "number_of_replicas": 0,
-            "analysis": map[string]interface{}{
-                "analyzer": map[string]interface{}{
-                    "ik_max_word_analyzer": map[string]interface{}{
-                        "type":      "custom",
-                        "tokenizer": "ik_max_word",
-                    },
-                },
-            },
-        },
-        // TODO 添加商品的所有信息，查询仅依赖于es
-        "mappings": map[string]interface{}{
-            "properties": map[string]interface{}{
-                "describe": map[string]interface{}{
-                    "type":     "text",
-                    "analyzer": "ik_max_word",
-                    "fields": map[string]interface{}{
-                        "keyword": map[string]interface{}{
-                            "type": "keyword",
-                        },
-                    },
-                },
-                "id": map[string]interface{}{
-                    "type": "keyword",
-                },
-                "category": map[string]interface{}{
-                    "type": "keyword",
-                },
-                "created_at": map[string]interface{}{
-                    "type":   "date",
-                    "format": "strict_date_optional_time||epoch_millis",
-                },
-                "attributes": map[string]interface{}{
-                    "type": "nested",
-                    "properties": map[string]interface{}{
-                        "key": map[string]interface{}{
-                            "type": "keyword",
-                        },
-                        "value": map[string]interface{}{
-                            "type": "keyword",
-                        },
-                    },
-                },
-            },
-        },
-    }
-
-    index := "m-market"
-    ctx := context.Background()
-
-    // 检查索引是否存在
-    existsReq := esapi.IndicesExistsRequest{Index: []string{index}}
-    existsRes, err := existsReq.Do(ctx, client)
-    if err != nil {
-        return fmt.Errorf("检查索引失败: %w", err)
-    }
-    defer existsRes.Body.Close()
-
-    // 如果索引不存在，直接创建
-    if existsRes.StatusCode != 200 {
-        body, err := json.Marshal(mapping)
-        if err != nil {
-            return fmt.Errorf("序列化映射失败: %v", err)
-        }
-        req := esapi.IndicesCreateRequest{
-            Index: index,
-            Body:  bytes.NewReader(body),
-        }
-        res, err := req.Do(ctx, client)
-        if err != nil {
-            return fmt.Errorf("创建索引失败: %w", err)
-        }
-        defer res.Body.Close()
-        if res.IsError() {
-            return fmt.Errorf("创建索引错误: %s", res.String())
-        }
-        log.Printf("✅ Index [%s] created", index)
-        return nil
-    }
-
-    // 索引存在，获取当前映射
-    getMappingReq := esapi.IndicesGetMappingRequest{Index: []string{index}}
-    getMappingRes, err := getMappingReq.Do(ctx, client)
-    if err != nil {
-        return fmt.Errorf("获取映射失败: %w", err)
-    }
-    defer getMappingRes.Body.Close()
-
-    // 解析当前映射
-    var currentMapping map[string]interface{}
-    if err := json.NewDecoder(getMappingRes.Body).Decode(&currentMapping); err != nil {
-        return fmt.Errorf("解析当前映射失败: %v", err)
-    }
-
-    // 提取索引的 mappings 部分
-    currentIndexMapping, ok := currentMapping[index].(map[string]interface{})
-    if !ok {
-        return fmt.Errorf("无法解析索引 %s 的映射", index)
-    }
-    currentMappings, ok := currentIndexMapping["mappings"].(map[string]interface{})
-    if !ok {
-        return fmt.Errorf("无法解析 mappings 部分")
-    }
-
-    // 序列化当前映射和目标映射以比较
-    currentJSON, err := json.Marshal(currentMappings)
-    if err != nil {
-        return fmt.Errorf("序列化当前映射失败: %v", err)
-    }
-    targetJSON, err := json.Marshal(mapping["mappings"])
-    if err != nil {
-        return fmt.Errorf("序列化目标映射失败: %v", err)
-    }
-
-    // 比较映射
-    if string(currentJSON) == string(targetJSON) {
-        log.Printf("🔁 Index [%s] mappings unchanged", index)
-        return nil
-    }
-
-    // 映射不同，尝试更新
-    log.Printf("🔄 Index [%s] mappings changed, updating...", index)
-    updateBody, err := json.Marshal(mapping["mappings"])
-    if err != nil {
-        return fmt.Errorf("序列化更新映射失败: %v", err)
-    }
-    updateReq := esapi.IndicesPutMappingRequest{
-        Index: []string{index},
-        Body:  bytes.NewReader(updateBody),
-    }
-    updateRes, err := updateReq.Do(ctx, client)
-    if err != nil {
-        return fmt.Errorf("更新映射失败: %w", err)
-    }
-    defer updateRes.Body.Close()
-
-    if updateRes.IsError() {
-        // 如果更新失败（例如字段类型冲突），需考虑重建索引
-        return fmt.Errorf("更新映射错误: %s", updateRes.String())
-    }
-
-    log.Printf("✅ Index [%s] mappings updated", index)
-    return nil
-}
\ No newline at end of file
+	if err := engine.InitIndex(ProductIndex, productMapping()); err != nil {
+		return err
+	}
+
+	return engine.InitIndex(MessageIndex, messageMapping())
+}
+
+// Mapping 返回别名对应的索引定义
+func Mapping(alias string) (map[string]interface{}, error) {
+	switch alias {
+	case ProductIndex:
+		return productMapping(), nil
+	case MessageIndex:
+		return messageMapping(), nil
+	default:
+		return nil, fmt.Errorf("unknown index %s", alias)
+	}
+}
+
+func messageMapping() map[string]interface{} {
+	return map[string]interface{}{
+		"settings": map[string]interface{}{
+			"number_of_shards":   1,
+			"number_of_replicas": 0,
+		},
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type": "keyword",
+				},
+				"conversation_id": map[string]interface{}{
+					"type": "keyword",
+				},
+				"from_user_id": map[string]interface{}{
+					"type": "keyword",
+				},
+				"participants": map[string]interface{}{
+					"type": "keyword",
+				},
+				"deleted_for": map[string]interface{}{
+					"type": "keyword",
+				},
+				"content": map[string]interface{}{
+					"type":            "text",
+					"analyzer":        "ik_max_word",
+					"search_analyzer": "ik_smart",
+				},
+				"timestamp": map[string]interface{}{
+					"type":   "date",
+					"format": "strict_date_optional_time||epoch_millis",
+				},
+			},
+		},
+	}
+}
+
+func productMapping() map[string]interface{} {
+	mapping := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"number_of_shards":   1,
+			"number_of_replicas": 0,
+			"analysis": map[string]interface{}{
+				"analyzer": map[string]interface{}{
+					"ik_max_word_analyzer": map[string]interface{}{
+						"type":      "custom",
+						"tokenizer": "ik_max_word",
+					},
+					// 用于"你是不是要找"的短语纠错
+					"ik_shingle_analyzer": map[string]interface{}{
+						"type":      "custom",
+						"tokenizer": "ik_smart",
+						"filter":    []string{"lowercase", "shingle_filter"},
+					},
+				},
+				"filter": map[string]interface{}{
+					"shingle_filter": map[string]interface{}{
+						"type":             "shingle",
+						"min_shingle_size": 2,
+						"max_shingle_size": 3,
+					},
+				},
+			},
+		},
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"describe": map[string]interface{}{
+					"type":     "text",
+					"analyzer": "ik_max_word",
+					"fields": map[string]interface{}{
+						"keyword": map[string]interface{}{
+							"type": "keyword",
+						},
+						"shingle": map[string]interface{}{
+							"type":     "text",
+							"analyzer": "ik_shingle_analyzer",
+						},
+					},
+				},
+				// 搜索框自动补全，输入为在售商品的标题和分类名
+				"suggest": map[string]interface{}{
+					"type":     "completion",
+					"analyzer": "simple",
+				},
+				"id": map[string]interface{}{
+					"type": "keyword",
+				},
+				"category": map[string]interface{}{
+					"type": "keyword",
+				},
+				"category_ids": map[string]interface{}{
+					"type": "long",
+				},
+				// 商品分类及其所有祖先分类，用于统计分类树各节点的商品数
+				"category_path": map[string]interface{}{
+					"type": "long",
+				},
+				"province": map[string]interface{}{
+					"type": "keyword",
+				},
+				"city": map[string]interface{}{
+					"type": "keyword",
+				},
+				"district": map[string]interface{}{
+					"type": "keyword",
+				},
+				"created_at": map[string]interface{}{
+					"type":   "date",
+					"format": "strict_date_optional_time||epoch_millis",
+				},
+				"price": map[string]interface{}{
+					"type": "float",
+				},
+				"condition": map[string]interface{}{
+					"type": "keyword",
+				},
+				"shipping_method": map[string]interface{}{
+					"type": "keyword",
+				},
+				"seller_id": map[string]interface{}{
+					"type": "keyword",
+				},
+				"seller_reputation": map[string]interface{}{
+					"type": "float",
+				},
+				"seller": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{
+							"type": "keyword",
+						},
+						"username": map[string]interface{}{
+							"type": "keyword",
+						},
+						"avatar": map[string]interface{}{
+							"type":  "keyword",
+							"index": false,
+						},
+						"total_comment": map[string]interface{}{
+							"type": "integer",
+						},
+						"positive_comment": map[string]interface{}{
+							"type": "integer",
+						},
+						"negative_comment": map[string]interface{}{
+							"type": "integer",
+						},
+					},
+				},
+				"original_price": map[string]interface{}{
+					"type": "float",
+				},
+				"pics": map[string]interface{}{
+					"type":  "keyword",
+					"index": false,
+				},
+				"used_time": map[string]interface{}{
+					"type": "keyword",
+				},
+				"shipping_price": map[string]interface{}{
+					"type": "float",
+				},
+				"can_self_pickup": map[string]interface{}{
+					"type": "boolean",
+				},
+				"publish_at": map[string]interface{}{
+					"type":   "date",
+					"format": "strict_date_optional_time||epoch_millis",
+				},
+				"is_published": map[string]interface{}{
+					"type": "boolean",
+				},
+				"is_sold": map[string]interface{}{
+					"type": "boolean",
+				},
+				"is_selling": map[string]interface{}{
+					"type": "boolean",
+				},
+				"like_count": map[string]interface{}{
+					"type": "long",
+				},
+				"view_count": map[string]interface{}{
+					"type": "long",
+				},
+				"location": map[string]interface{}{
+					"type": "keyword",
+				},
+				"address": map[string]interface{}{
+					"type":  "keyword",
+					"index": false,
+				},
+				"geo": map[string]interface{}{
+					"type": "geo_point",
+				},
+				"attributes": map[string]interface{}{
+					"type": "nested",
+					"properties": map[string]interface{}{
+						"attribute_id": map[string]interface{}{
+							"type": "long",
+						},
+						"key": map[string]interface{}{
+							"type": "keyword",
+						},
+						"value": map[string]interface{}{
+							"type": "keyword",
+						},
+						// 按属性的DataType解析出的值，用于范围查询
+						"number": map[string]interface{}{
+							"type": "double",
+						},
+						"date": map[string]interface{}{
+							"type":   "date",
+							"format": "strict_date_optional_time||epoch_millis",
+						},
+						"bool": map[string]interface{}{
+							"type": "boolean",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if pinyinEnabled {
+		addPinyinMapping(mapping)
+	}
+	return mapping
+}
+
+// addPinyinMapping 为标题和补全字段增加全拼和首字母子字段，需要安装analysis-pinyin插件
+func addPinyinMapping(mapping map[string]interface{}) {
+	analysis := mapping["settings"].(map[string]interface{})["analysis"].(map[string]interface{})
+	analysis["analyzer"].(map[string]interface{})["pinyin_analyzer"] = map[string]interface{}{
+		"type":      "custom",
+		"tokenizer": "pinyin_tokenizer",
+	}
+	analysis["tokenizer"] = map[string]interface{}{
+		"pinyin_tokenizer": map[string]interface{}{
+			"type":                      "pinyin",
+			"keep_first_letter":         true,
+			"keep_full_pinyin":          true,
+			"keep_joined_full_pinyin":   true,
+			"keep_original":             true,
+			"limit_first_letter_length": 16,
+			"lowercase":                 true,
+		},
+	}
+
+	properties := mapping["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+	describe := properties["describe"].(map[string]interface{})
+	describe["fields"].(map[string]interface{})["pinyin"] = map[string]interface{}{
+		"type":     "text",
+		"analyzer": "pinyin_analyzer",
+	}
+	properties["suggest"].(map[string]interface{})["fields"] = map[string]interface{}{
+		"pinyin": map[string]interface{}{
+			"type":     "completion",
+			"analyzer": "pinyin_analyzer",
+		},
+	}
+}
+
+// createIndex 按mapping创建索引，aliases为空时不绑定别名
+func createIndex(index string, mapping map[string]interface{}, aliases map[string]interface{}) error {
+	body := make(map[string]interface{}, len(mapping)+1)
+	for key, value := range mapping {
+		body[key] = value
+	}
+	if len(aliases) > 0 {
+		body["aliases"] = aliases
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("序列化映射失败: %v", err)
+	}
+
+	req := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  bytes.NewReader(data),
+	}
+	res, err := req.Do(context.Background(), client)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("创建索引错误: %s", res.String())
+	}
+
+	log.Printf("✅ Index [%s] created", index)
+	return nil
+}
+
+// updateMapping 映射变化时尝试增量更新，不兼容的变更需要通过reindex命令重建索引
+func updateMapping(index string, mapping map[string]interface{}) error {
+	ctx := context.Background()
+
+	getMappingReq := esapi.IndicesGetMappingRequest{Index: []string{index}}
+	getMappingRes, err := getMappingReq.Do(ctx, client)
+	if err != nil {
+		return fmt.Errorf("获取映射失败: %w", err)
+	}
+	defer getMappingRes.Body.Close()
+
+	var currentMapping map[string]struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	if err := json.NewDecoder(getMappingRes.Body).Decode(&currentMapping); err != nil {
+		return fmt.Errorf("解析当前映射失败: %v", err)
+	}
+
+	current, ok := currentMapping[index]
+	if !ok {
+		return fmt.Errorf("无法解析索引 %s 的映射", index)
+	}
+
+	// 重新序列化以忽略字段顺序的差异
+	var currentMappings interface{}
+	if err := json.Unmarshal(current.Mappings, &currentMappings); err != nil {
+		return fmt.Errorf("解析当前映射失败: %v", err)
+	}
+	currentJSON, err := json.Marshal(currentMappings)
+	if err != nil {
+		return fmt.Errorf("序列化当前映射失败: %v", err)
+	}
+	targetJSON, err := json.Marshal(mapping["mappings"])
+	if err != nil {
+		return fmt.Errorf("序列化目标映射失败: %v", err)
+	}
+
+	if string(currentJSON) == string(targetJSON) {
+		log.Printf("🔁 Index [%s] mappings unchanged", index)
+		return nil
+	}
+
+	log.Printf("🔄 Index [%s] mappings changed, updating...", index)
+	updateReq := esapi.IndicesPutMappingRequest{
+		Index: []string{index},
+		Body:  bytes.NewReader(targetJSON),
+	}
+	updateRes, err := updateReq.Do(ctx, client)
+	if err != nil {
+		return fmt.Errorf("更新映射失败: %w", err)
+	}
+	defer updateRes.Body.Close()
+
+	if updateRes.IsError() {
+		return fmt.Errorf("%w: %s", ErrIncompatibleMapping, updateRes.String())
+	}
+
+	log.Printf("✅ Index [%s] mappings updated", index)
+	return nil
+}
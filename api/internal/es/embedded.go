@@ -0,0 +1,128 @@
+package es
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/search"
+	"github.com/mislu/market-api/internal/utils/app"
+)
+
+const defaultFlushInterval = 5 // 秒
+
+// embeddedEngine 进程内的搜索后端，用于本地开发和测试，不依赖外部服务
+type embeddedEngine struct {
+	engine *search.Engine
+}
+
+func newEmbeddedEngine() (SearchEngine, error) {
+	config := app.GetConfig().ES.Embedded
+
+	engine, err := search.Open(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Path) > 0 {
+		interval := config.FlushInterval
+		if interval <= 0 {
+			interval = defaultFlushInterval
+		}
+		go func() {
+			for range time.Tick(time.Duration(interval) * time.Second) {
+				if err := engine.Flush(); err != nil {
+					log.Printf("failed to flush embedded search index: %v", err)
+				}
+			}
+		}()
+	}
+
+	log.Printf("✅ Using embedded search engine, snapshot: %q", config.Path)
+	return embeddedEngine{engine: engine}, nil
+}
+
+func (e embeddedEngine) InitIndex(alias string, mapping map[string]interface{}) error {
+	return e.engine.CreateIndex(alias, mapping)
+}
+
+func (e embeddedEngine) Index(index string, docID string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	return e.engine.Index(index, docID, data)
+}
+
+func (e embeddedEngine) Update(index string, docID string, doc interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return e.engine.Update(index, docID, data)
+}
+
+func (e embeddedEngine) Delete(index string, docID string) error {
+	return e.engine.Delete(index, docID)
+}
+
+func (e embeddedEngine) UpdateByQuery(index string, query map[string]interface{}, doc map[string]interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return e.engine.UpdateByQuery(index, query, data)
+}
+
+func (e embeddedEngine) BulkIndex(index string, docs map[string]interface{}) error {
+	for id, doc := range docs {
+		if err := e.Index(index, id, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e embeddedEngine) MultiGet(index string, docIDs []string) (map[string]json.RawMessage, error) {
+	return e.engine.Get(index, docIDs)
+}
+
+func (e embeddedEngine) Search(index string, query map[string]interface{}) (SearchResult, error) {
+	var result SearchResult
+
+	r, err := e.engine.Search(index, query)
+	if err != nil {
+		return result, err
+	}
+
+	result.Total = r.Total
+	result.Hits = make([]json.RawMessage, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		result.Hits = append(result.Hits, hit.Source)
+	}
+	result.Aggregations = r.Aggregations
+	result.Suggest = r.Suggest
+
+	return result, nil
+}
+
+func (e embeddedEngine) MultiCount(index string, queries []map[string]interface{}) ([]int64, error) {
+	counts := make([]int64, 0, len(queries))
+	for _, query := range queries {
+		q, _ := query["query"].(map[string]interface{})
+		count, err := e.engine.Count(index, q)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
+
+func (e embeddedEngine) Suggest(index string, suggest map[string]interface{}) (map[string]json.RawMessage, error) {
+	return e.engine.Suggest(index, suggest)
+}
@@ -0,0 +1,131 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	EngineElasticsearch = "elasticsearch"
+	EngineEmbedded      = "embedded"
+)
+
+// SearchEngine 搜索后端，查询统一使用es的DSL，内嵌引擎支持其中业务用到的子集
+type SearchEngine interface {
+	// InitIndex 按mapping创建或更新索引
+	InitIndex(alias string, mapping map[string]interface{}) error
+	// Index 写入或覆盖文档
+	Index(index string, docID string, body interface{}) error
+	// Update 局部更新文档，仅覆盖doc中出现的字段
+	Update(index string, docID string, doc interface{}) error
+	// Delete 删除文档，文档不存在视为成功
+	Delete(index string, docID string) error
+	// UpdateByQuery 把doc中的字段写入所有匹配query的文档
+	UpdateByQuery(index string, query map[string]interface{}, doc map[string]interface{}) error
+	// BulkIndex 批量写入文档，docs的key为文档ID
+	BulkIndex(index string, docs map[string]interface{}) error
+	// MultiGet 批量获取文档的_source，不存在的文档不会出现在结果中
+	MultiGet(index string, docIDs []string) (map[string]json.RawMessage, error)
+	// Search 搜索，返回命中总数、文档、聚合和suggest结果
+	Search(index string, query map[string]interface{}) (SearchResult, error)
+	// MultiCount 批量统计各查询的命中数，结果与queries顺序一致
+	MultiCount(index string, queries []map[string]interface{}) ([]int64, error)
+	// Suggest 执行suggest请求，返回各suggester的结果
+	Suggest(index string, suggest map[string]interface{}) (map[string]json.RawMessage, error)
+}
+
+var engine SearchEngine
+
+// SearchResult 搜索结果，包含命中总数和聚合结果，Hits为各文档的_source
+type SearchResult struct {
+	Total        int64
+	Hits         []json.RawMessage
+	Aggregations map[string]json.RawMessage
+	Suggest      map[string]json.RawMessage
+}
+
+// IsElasticsearch 当前是否使用es，索引别名管理和重建只在es下可用
+func IsElasticsearch() bool {
+	_, ok := engine.(elasticEngine)
+	return ok
+}
+
+func IndexDocument(index string, docID string, body interface{}) error {
+	return engine.Index(index, docID, body)
+}
+
+// UpdateDocument 局部更新文档，仅覆盖doc中出现的字段
+func UpdateDocument(index string, docID string, doc interface{}) error {
+	return engine.Update(index, docID, doc)
+}
+
+func DeleteDocument(index string, docID string) error {
+	return engine.Delete(index, docID)
+}
+
+// UpdateByQuery 把doc中的字段写入所有匹配query的文档
+func UpdateByQuery(index string, query map[string]interface{}, doc map[string]interface{}) error {
+	return engine.UpdateByQuery(index, query, doc)
+}
+
+// BulkIndex 批量写入文档，docs的key为文档ID
+func BulkIndex(index string, docs map[string]interface{}) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	return engine.BulkIndex(index, docs)
+}
+
+// MultiGet 批量获取文档的_source，不存在的文档不会出现在结果中
+func MultiGet(index string, docIDs []string) (map[string]json.RawMessage, error) {
+	if len(docIDs) == 0 {
+		return make(map[string]json.RawMessage), nil
+	}
+	return engine.MultiGet(index, docIDs)
+}
+
+func Search(index string, query map[string]interface{}) ([]map[string]interface{}, error) {
+	result, err := SearchWithAggregations(index, query)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]map[string]interface{}, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		var source map[string]interface{}
+		if err := json.Unmarshal(hit, &source); err != nil {
+			return nil, err
+		}
+		hits = append(hits, source)
+	}
+
+	return hits, nil
+}
+
+func SearchWithAggregations(index string, query map[string]interface{}) (SearchResult, error) {
+	return engine.Search(index, query)
+}
+
+// MultiCount 批量统计各查询的命中数，结果与queries顺序一致
+func MultiCount(index string, queries []map[string]interface{}) ([]int64, error) {
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	return engine.MultiCount(index, queries)
+}
+
+// Suggest 执行suggest请求，返回各suggester的结果
+func Suggest(index string, suggest map[string]interface{}) (map[string]json.RawMessage, error) {
+	return engine.Suggest(index, suggest)
+}
+
+func newEngine(typ string) (SearchEngine, error) {
+	switch typ {
+	case "", EngineElasticsearch:
+		return newElasticEngine()
+	case EngineEmbedded:
+		return newEmbeddedEngine()
+	default:
+		return nil, fmt.Errorf("unknown search engine %s", typ)
+	}
+}
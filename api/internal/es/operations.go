@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
@@ -15,7 +16,14 @@ import (
 
 var errESRequestFailed = errors.New("request to es failed")
 
-func IndexDocument(index string, docID string, body interface{}) error {
+// elasticEngine 使用es集群的搜索后端
+type elasticEngine struct{}
+
+func (elasticEngine) InitIndex(alias string, mapping map[string]interface{}) error {
+	return ensureAlias(alias, mapping)
+}
+
+func (elasticEngine) Index(index string, docID string, body interface{}) error {
 	data, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("序列化文档失败: %w", err)
@@ -50,6 +58,35 @@ func IndexDocument(index string, docID string, body interface{}) error {
 	return nil
 }
 
+func (elasticEngine) Update(index string, docID string, doc interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"doc": doc})
+	if err != nil {
+		return fmt.Errorf("序列化文档失败: %w", err)
+	}
+
+	req := esapi.UpdateRequest{
+		Index:      index,
+		DocumentID: docID,
+		Body:       bytes.NewReader(data),
+		Refresh:    "true",
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := req.Do(timeoutCtx, client)
+	if err != nil {
+		return fmt.Errorf("更新 Elasticsearch 文档失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	return nil
+}
+
 func GetDocument(index string, docID string) (map[string]interface{}, error) {
 	req := esapi.GetRequest{
 		Index:      index,
@@ -74,7 +111,7 @@ func GetDocument(index string, docID string) (map[string]interface{}, error) {
 	return result, nil
 }
 
-func DeleteDocument(index string, docID string) error {
+func (elasticEngine) Delete(index string, docID string) error {
 	req := esapi.DeleteRequest{
 		Index:      index,
 		DocumentID: docID,
@@ -87,16 +124,19 @@ func DeleteDocument(index string, docID string) error {
 	}
 	defer res.Body.Close()
 
-	if res.IsError() {
+	// 文档不存在视为删除成功，保证重复投递的删除事件幂等
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
 		return fmt.Errorf("delete error: %s", res.String())
 	}
 	return nil
 }
 
-func Search(index string, query map[string]interface{}) ([]map[string]interface{}, error) {
+func (elasticEngine) Search(index string, query map[string]interface{}) (SearchResult, error) {
+	var result SearchResult
+
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
+		return result, err
 	}
 
 	res, err := client.Search(
@@ -105,27 +145,196 @@ func Search(index string, query map[string]interface{}) ([]map[string]interface{
 		client.Search.WithBody(&buf),
 		client.Search.WithTrackTotalHits(true),
 	)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return result, fmt.Errorf("search error: %s", res.String())
+	}
+
+	var r struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]json.RawMessage `json:"aggregations"`
+		Suggest      map[string]json.RawMessage `json:"suggest"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return result, err
+	}
+
+	result.Total = r.Hits.Total.Value
+	result.Hits = make([]json.RawMessage, 0, len(r.Hits.Hits))
+	for _, hit := range r.Hits.Hits {
+		result.Hits = append(result.Hits, hit.Source)
+	}
+	result.Aggregations = r.Aggregations
+	result.Suggest = r.Suggest
+
+	return result, nil
+}
+
+// UpdateByQuery 通过脚本把doc中的字段写入匹配query的文档
+func (elasticEngine) UpdateByQuery(index string, query map[string]interface{}, doc map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{
+		"query": query,
+		"script": map[string]interface{}{
+			"source": "for (entry in params.entrySet()) { ctx._source[entry.getKey()] = entry.getValue() }",
+			"params": doc,
+		},
+	}); err != nil {
+		return err
+	}
+
+	refresh := true
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{index},
+		Body:      &buf,
+		Refresh:   &refresh,
+		Conflicts: "proceed",
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := req.Do(timeoutCtx, client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	return nil
+}
+
+func (elasticEngine) MultiGet(index string, docIDs []string) (map[string]json.RawMessage, error) {
+	result := make(map[string]json.RawMessage, len(docIDs))
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"ids": docIDs}); err != nil {
+		return nil, err
+	}
+
+	req := esapi.MgetRequest{
+		Index: index,
+		Body:  &buf,
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := req.Do(timeoutCtx, client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%w: %s", errESRequestFailed, res.String())
+	}
+
+	var r struct {
+		Docs []struct {
+			ID     string          `json:"_id"`
+			Found  bool            `json:"found"`
+			Source json.RawMessage `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	for _, doc := range r.Docs {
+		if doc.Found {
+			result[doc.ID] = doc.Source
+		}
+	}
+
+	return result, nil
+}
+
+// MultiCount 使用_msearch批量统计
+func (elasticEngine) MultiCount(index string, queries []map[string]interface{}) ([]int64, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, query := range queries {
+		if err := encoder.Encode(map[string]interface{}{"index": index}); err != nil {
+			return nil, err
+		}
+
+		body := make(map[string]interface{}, len(query)+2)
+		for key, value := range query {
+			body[key] = value
+		}
+		body["size"] = 0
+		body["track_total_hits"] = true
+		delete(body, "from")
+		delete(body, "sort")
+		if err := encoder.Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req := esapi.MsearchRequest{Body: &buf}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := req.Do(timeoutCtx, client)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, fmt.Errorf("search error: %s", res.String())
+		return nil, fmt.Errorf("%w: %s", errESRequestFailed, res.String())
 	}
 
-	var r map[string]interface{}
+	var r struct {
+		Responses []struct {
+			Hits struct {
+				Total struct {
+					Value int64 `json:"value"`
+				} `json:"total"`
+			} `json:"hits"`
+			Error json.RawMessage `json:"error"`
+		} `json:"responses"`
+	}
 	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
 		return nil, err
 	}
 
-	hits := r["hits"].(map[string]interface{})["hits"].([]interface{})
-	results := make([]map[string]interface{}, 0, len(hits))
+	counts := make([]int64, 0, len(r.Responses))
+	for _, response := range r.Responses {
+		if len(response.Error) > 0 {
+			return nil, fmt.Errorf("%w: %s", errESRequestFailed, string(response.Error))
+		}
+		counts = append(counts, response.Hits.Total.Value)
+	}
+
+	return counts, nil
+}
 
-	for _, hit := range hits {
-		source := hit.(map[string]interface{})["_source"]
-		results = append(results, source.(map[string]interface{}))
+func (e elasticEngine) Suggest(index string, suggest map[string]interface{}) (map[string]json.RawMessage, error) {
+	result, err := e.Search(index, map[string]interface{}{
+		"size":    0,
+		"_source": false,
+		"suggest": suggest,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return results, nil
+	return result.Suggest, nil
 }
@@ -158,3 +158,58 @@ func UpdateAttribute() func(c *gin.Context) {
 		Success(c, ResponseTypeJSON, "ok")
 	}
 }
+
+func GetMessageReports() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.GetMessageReportsReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.PageReq.Fill()
+
+		resp, err := service.GetMessageReports(req)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+func ReviewMessageReport() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.ReviewMessageReportReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		if err := service.ReviewMessageReport(req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+func GetExperimentMetrics() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.GetExperimentMetricsReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		resp, err := service.GetExperimentMetrics(req)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
@@ -18,7 +18,8 @@ func GetAssert() func(c *gin.Context) {
 
 		switch app.GetConfig().OSS.Type {
 		case "local_storage":
-			path, err := service.GetAssert(req)
+			userID, _ := GetContextUserID(c)
+			path, err := service.GetAssert(req, userID)
 			if err != nil {
 				AbortWithError(c, err)
 				return
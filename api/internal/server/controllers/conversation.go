@@ -6,7 +6,7 @@ import (
 	"github.com/mislu/market-api/internal/types/request"
 )
 
-// post /api/conversation/userID/
+// post /api/conversation/{conversationID}/media
 func UploadMediaFile() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		req := &request.UploadMediaFileReq{}
@@ -15,7 +15,8 @@ func UploadMediaFile() func(c *gin.Context) {
 			return
 		}
 
-		resp, err := service.UploadMediaFile(req)
+		userID, _ := GetContextUserID(c)
+		resp, err := service.UploadMediaFile(req, userID)
 		if err != nil {
 			AbortWithError(c, err)
 			return
@@ -83,3 +84,64 @@ func GetMessages() func(c *gin.Context) {
 		Success(c, ResponseTypeJSON, resp)
 	}
 }
+
+// delete /api/conversation/messages/{messageID}
+func DeleteMessage() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.DeleteMessageReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		userID, _ := GetContextUserID(c)
+		err := service.DeleteMessage(req, userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// get /api/conversation/messages/search
+func SearchMessages() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.SearchMessagesReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.Fill()
+
+		userID, _ := GetContextUserID(c)
+		resp, err := service.SearchMessages(req, userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// POST /api/conversation/messages/{messageID}/report
+func ReportMessage() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.ReportMessageReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		userID, _ := GetContextUserID(c)
+		if err := service.ReportMessage(req, userID); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mislu/market-api/internal/service"
+	"github.com/mislu/market-api/internal/types/request"
+)
+
+// POST /api/feedback
+func RecordFeedback() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.RecordFeedbackReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		if err := service.RecordFeedback(req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
@@ -1,13 +1,11 @@
 package controllers
 
 import (
-	"context"
 	"errors"
 	"net/http"
-	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/mislu/market-api/internal/core/recommend"
 	"github.com/mislu/market-api/internal/types/exceptions"
 	"github.com/mislu/market-api/internal/utils/lib"
 )
@@ -18,15 +16,13 @@ const (
 
 func JWTMiddleware(needAuth bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenHeader := c.Request.Header.Get("Authorization")
-		if len(tokenHeader) == 0 && needAuth {
-			AbortWithError(c, exceptions.NewGenericError(http.StatusUnauthorized, "No token provided", errors.New("no token provided")))
-			c.Abort()
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
+		token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
 		if len(token) == 0 {
+			if !needAuth {
+				c.Next()
+				return
+			}
+
 			AbortWithError(c, exceptions.NewGenericError(http.StatusUnauthorized, "No token provided", errors.New("no token provided")))
 			c.Abort()
 			return
@@ -44,54 +40,12 @@ func JWTMiddleware(needAuth bool) gin.HandlerFunc {
 	}
 }
 
-var productRegex = regexp.MustCompile(`^/api/product/([^/]+)/([^/]+)/?$`)
-var orderRegex = regexp.MustCompile(`^/api/order/([^/]+)/([^/]+)/?$`)
-
-func ScrapMiddleware() gin.HandlerFunc {
+// QueryTokenMiddleware 浏览器直接加载的资源(如<img>)无法携带请求头，允许通过query传递token，只用于资源下载
+func QueryTokenMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		uri := c.Request.RequestURI
-		userID, exists := GetContextUserID(c)
-		if !exists {
-			c.Next()
-			return
-		}
-
-		if len(userID) == 0 {
-			c.Next()
-			return
-		}
-
-		switch c.Request.Method {
-		case http.MethodGet:
-			if productRegex.MatchString(uri) {
-				feedBacks := []recommend.Feedback{{
-					UserId:       userID,
-					ItemId:       productRegex.FindStringSubmatch(uri)[2],
-					FeedbackType: "view",
-				}}
-
-				recommend.GlobalWorker.InsertFeedback(context.Background(), feedBacks)
-			}
-		case http.MethodPost:
-			if productRegex.MatchString(uri) {
-				feedBacks := []recommend.Feedback{{
-					UserId:       userID,
-					ItemId:       productRegex.FindStringSubmatch(uri)[2],
-					FeedbackType: "like",
-				}}
-
-				recommend.GlobalWorker.InsertFeedback(context.Background(), feedBacks)
-			} else if orderRegex.MatchString(uri) {
-				feedBacks := []recommend.Feedback{{
-					UserId:       userID,
-					ItemId:       orderRegex.FindStringSubmatch(uri)[2],
-					FeedbackType: "purchase",
-				}}
-
-				recommend.GlobalWorker.InsertFeedback(context.Background(), feedBacks)
-			}
+		if token := c.Query("token"); len(token) > 0 && len(c.Request.Header.Get("Authorization")) == 0 {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
 		}
-
 		c.Next()
 	}
 }
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mislu/market-api/internal/service"
+	"github.com/mislu/market-api/internal/types/request"
+)
+
+// GET /api/notification
+func GetNotifications() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.GetNotificationsReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.PageReq.Fill()
+
+		userID, _ := GetContextUserID(c)
+		resp, err := service.GetNotifications(req, userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// GET /api/notification/unread
+func GetUnreadNotificationCount() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, _ := GetContextUserID(c)
+		resp, err := service.GetUnreadNotificationCount(userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// PUT /api/notification/read
+func MarkNotificationsRead() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.MarkNotificationsReadReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		userID, _ := GetContextUserID(c)
+		if err := service.MarkNotificationsRead(req, userID); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// GET /api/notification/preferences
+func GetNotificationPreferences() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, _ := GetContextUserID(c)
+		resp, err := service.GetNotificationPreferences(userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// PUT /api/notification/preference
+func UpdateNotificationPreference() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.UpdateNotificationPreferenceReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		userID, _ := GetContextUserID(c)
+		if err := service.UpdateNotificationPreference(req, userID); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
@@ -170,7 +170,7 @@ func GetProductList() func(c *gin.Context) {
 			return
 		}
 
-		req.PageReq.Fill()
+		req.Fill()
 
 		userID, _ := GetContextUserID(c)
 		resp, err := service.GetProductList(req, userID)
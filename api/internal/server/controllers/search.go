@@ -45,3 +45,167 @@ func GetSearchHistory() func(c *gin.Context) {
 		Success(c, ResponseTypeJSON, resp)
 	}
 }
+
+// GET /api/search/suggest
+func SuggestSearch() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.SuggestReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		resp, err := service.SuggestSearch(req)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// DELETE /api/search/history/item?keyword=
+func DeleteSearchHistory() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.DeleteSearchHistoryReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		if err := service.DeleteSearchHistory(req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// DELETE /api/search/history
+func ClearSearchHistory() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, _ := GetContextUserID(c)
+		if err := service.ClearSearchHistory(userID); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// PUT /api/search/history/setting
+func UpdateSearchHistorySetting() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.UpdateSearchHistorySettingReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		if err := service.UpdateSearchHistorySetting(req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// POST /api/search/saved
+func CreateSavedSearch() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.CreateSavedSearchReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		resp, err := service.CreateSavedSearch(req)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// GET /api/search/saved
+func GetSavedSearches() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, _ := GetContextUserID(c)
+		resp, err := service.GetSavedSearches(userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// DELETE /api/search/saved/{savedSearchID}
+func DeleteSavedSearch() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.SavedSearchIDReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		if err := service.DeleteSavedSearch(req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// GET /api/search/saved/alerts
+func GetSearchAlerts() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.GetSearchAlertsReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.PageReq.Fill()
+		req.UserID, _ = GetContextUserID(c)
+		resp, err := service.GetSearchAlerts(req)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
+
+// PUT /api/search/saved/alerts/read
+func MarkSearchAlertsRead() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.MarkSearchAlertsReadReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		req.UserID, _ = GetContextUserID(c)
+		if err := service.MarkSearchAlertsRead(req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
@@ -140,3 +140,55 @@ func SelectInterestTags() func(c *gin.Context) {
 		Success(c, ResponseTypeJSON, "ok")
 	}
 }
+
+// POST /api/user/block/{targetUserID}
+func BlockUser() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.BlockUserReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		userID, _ := GetContextUserID(c)
+		if err := service.BlockUser(req, userID); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// DELETE /api/user/block/{targetUserID}
+func UnblockUser() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		req := &request.BlockUserReq{}
+		if err := BindRequest(c, req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		userID, _ := GetContextUserID(c)
+		if err := service.UnblockUser(req, userID); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, "ok")
+	}
+}
+
+// GET /api/user/blocks
+func GetBlockedUsers() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, _ := GetContextUserID(c)
+		resp, err := service.GetBlockedUsers(userID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		Success(c, ResponseTypeJSON, resp)
+	}
+}
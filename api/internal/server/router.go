@@ -23,6 +23,8 @@ func (s *Server) serve() {
 	conversationRouter := s.engine.Group("/api/conversation")
 	addressRouter := s.engine.Group("/api/address")
 	adminRouter := s.engine.Group("/api/admin")
+	notificationRouter := s.engine.Group("/api/notification")
+	feedbackRouter := s.engine.Group("/api/feedback")
 
 	// setup routers
 	s.registerUserGroup(userRouter)
@@ -34,6 +36,8 @@ func (s *Server) serve() {
 	s.registerConversationGroup(conversationRouter)
 	s.registerAddressGroup(addressRouter)
 	s.registerAdminGroup(adminRouter)
+	s.registerNotificationGroup(notificationRouter)
+	s.registerFeedbackGroup(feedbackRouter)
 
 	// run
 	srv := &http.Server{
@@ -70,6 +74,9 @@ func (s *Server) registerUserGroup(group *gin.RouterGroup) {
 	group.PUT("/:userID/basic", controllers.UpdateBasic())
 	group.PUT("/:userID/password", controllers.UpdatePassword())
 	group.POST("/:userID/select", controllers.SelectInterestTags())
+	group.POST("/block/:targetUserID", controllers.JWTMiddleware(true), controllers.BlockUser())
+	group.DELETE("/block/:targetUserID", controllers.JWTMiddleware(true), controllers.UnblockUser())
+	group.GET("/blocks", controllers.JWTMiddleware(true), controllers.GetBlockedUsers())
 }
 
 func (s *Server) registerMockGroup(group *gin.RouterGroup) {
@@ -82,7 +89,7 @@ func (s *Server) registerMockGroup(group *gin.RouterGroup) {
 func (s *Server) registerProductGroup(group *gin.RouterGroup) {
 	// TODO add auth
 	group.POST("/:userID", controllers.CreateProduct())
-	group.GET("/:userID/:productID", controllers.JWTMiddleware(false), controllers.ScrapMiddleware(), controllers.GetProduct()) // collect
+	group.GET("/:userID/:productID", controllers.JWTMiddleware(false), controllers.GetProduct())
 	group.PUT("/:userID/:productID", controllers.UpdateProduct())
 	group.PUT("/:userID/:productID/off-shelves", controllers.OffShelves())
 	group.PUT("/:userID/:productID/on-shelves", controllers.OnShelves())
@@ -92,7 +99,7 @@ func (s *Server) registerProductGroup(group *gin.RouterGroup) {
 	group.GET("/products", controllers.JWTMiddleware(false), controllers.GetProductList())
 	group.GET("/category", controllers.GetAllCategory())
 	group.PUT("/:userID/:productID/price", controllers.UpdateProductPrice())
-	group.POST("/:userID/:productID/like", controllers.JWTMiddleware(true), controllers.ScrapMiddleware(), controllers.LikeProduct()) // collect
+	group.POST("/:userID/:productID/like", controllers.JWTMiddleware(true), controllers.LikeProduct())
 	group.PUT("/:userID/:productID/dislike", controllers.DislikeProduct())
 	group.GET("/:userID/favorites", controllers.GetUserLikes())
 	group.GET("/tags", controllers.GetInterestTags())
@@ -100,11 +107,11 @@ func (s *Server) registerProductGroup(group *gin.RouterGroup) {
 
 func (s *Server) registerAssertGroup(group *gin.RouterGroup) {
 	// TODO add auth
-	group.GET("/:type/:owner/:key", controllers.GetAssert())
+	group.GET("/:type/:owner/:key", controllers.QueryTokenMiddleware(), controllers.JWTMiddleware(false), controllers.GetAssert())
 }
 
 func (s *Server) registerOrderGroup(group *gin.RouterGroup) {
-	group.POST("/:userID/:productID", controllers.JWTMiddleware(true), controllers.ScrapMiddleware(), controllers.PurchaseProduct())
+	group.POST("/:userID/:productID", controllers.JWTMiddleware(true), controllers.PurchaseProduct())
 	group.GET("/:userID/list", controllers.GetOrderList())
 	group.GET("/:userID/:orderID", controllers.GetOrder())
 	group.PUT("/shipped/:userID/:orderID", controllers.ConfirmOrderShipped())
@@ -124,12 +131,25 @@ func (s *Server) registerOrderGroup(group *gin.RouterGroup) {
 func (s *Server) registerSearchGroup(group *gin.RouterGroup) {
 	group.POST("/products", controllers.JWTMiddleware(false), controllers.SearchProduct())
 	group.GET("/:userID/history", controllers.GetSearchHistory())
+	group.GET("/suggest", controllers.JWTMiddleware(false), controllers.SuggestSearch())
+	group.DELETE("/history", controllers.JWTMiddleware(true), controllers.ClearSearchHistory())
+	group.DELETE("/history/item", controllers.JWTMiddleware(true), controllers.DeleteSearchHistory())
+	group.PUT("/history/setting", controllers.JWTMiddleware(true), controllers.UpdateSearchHistorySetting())
+	group.POST("/saved", controllers.JWTMiddleware(true), controllers.CreateSavedSearch())
+	group.GET("/saved", controllers.JWTMiddleware(true), controllers.GetSavedSearches())
+	group.DELETE("/saved/:savedSearchID", controllers.JWTMiddleware(true), controllers.DeleteSavedSearch())
+	group.GET("/saved/alerts", controllers.JWTMiddleware(true), controllers.GetSearchAlerts())
+	group.PUT("/saved/alerts/read", controllers.JWTMiddleware(true), controllers.MarkSearchAlertsRead())
 }
 
 func (s *Server) registerConversationGroup(group *gin.RouterGroup) {
 	group.POST("/create", controllers.CreateConversation())
+	group.POST("/:conversationID/media", controllers.JWTMiddleware(true), controllers.UploadMediaFile())
 	group.GET("/:userID", controllers.GetConversationList())
 	group.GET("/messages", controllers.GetMessages())
+	group.GET("/messages/search", controllers.JWTMiddleware(true), controllers.SearchMessages())
+	group.DELETE("/messages/:messageID", controllers.JWTMiddleware(true), controllers.DeleteMessage())
+	group.POST("/messages/:messageID/report", controllers.JWTMiddleware(true), controllers.ReportMessage())
 	group.GET("/:userID/list", controllers.GetConversationList())
 }
 
@@ -151,4 +171,20 @@ func (s *Server) registerAdminGroup(group *gin.RouterGroup) {
 	group.POST("/attribute", controllers.CreateAttribute())
 	group.PUT("/attribute", controllers.UpdateAttribute())
 	group.DELETE("/attribute", controllers.DeleteAttribute())
+	group.GET("/reports", controllers.GetMessageReports())
+	group.PUT("/report", controllers.ReviewMessageReport())
+	group.GET("/experiments/metrics", controllers.GetExperimentMetrics())
+}
+
+func (s *Server) registerNotificationGroup(group *gin.RouterGroup) {
+	group.Use(controllers.JWTMiddleware(true))
+	group.GET("", controllers.GetNotifications())
+	group.GET("/unread", controllers.GetUnreadNotificationCount())
+	group.PUT("/read", controllers.MarkNotificationsRead())
+	group.GET("/preferences", controllers.GetNotificationPreferences())
+	group.PUT("/preference", controllers.UpdateNotificationPreference())
+}
+
+func (s *Server) registerFeedbackGroup(group *gin.RouterGroup) {
+	group.POST("", controllers.JWTMiddleware(true), controllers.RecordFeedback())
 }
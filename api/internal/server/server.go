@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"runtime/debug"
@@ -8,6 +9,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mislu/market-api/internal/core/im"
+	"github.com/mislu/market-api/internal/core/indexer"
+	"github.com/mislu/market-api/internal/core/moderation"
 	"github.com/mislu/market-api/internal/core/mq/rabbit"
 	"github.com/mislu/market-api/internal/core/payment"
 	"github.com/mislu/market-api/internal/core/recommend"
@@ -15,6 +18,7 @@ import (
 	"github.com/mislu/market-api/internal/db"
 	"github.com/mislu/market-api/internal/es"
 	"github.com/mislu/market-api/internal/server/controllers"
+	"github.com/mislu/market-api/internal/service"
 	"github.com/mislu/market-api/internal/types/exceptions"
 	"github.com/mislu/market-api/internal/utils/log"
 	"go.uber.org/multierr"
@@ -132,6 +136,11 @@ func Run() {
 	es.Init()
 
 	im.Init()
+
+	if err := moderation.Init(); err != nil {
+		panic(err)
+	}
+
 	// init resource manager
 	resourcemanager.InitGlobalResourceManager()
 
@@ -140,7 +149,12 @@ func Run() {
 		panic(err)
 	}
 
-	recommend.InitGlobalWorker()
+	recommend.InitGlobalWorker(service.NewLocalRecommender())
+	indexer.InitGlobalWorker(service.HandleIndexEvent)
+	service.StartIndexSync(context.Background())
+	service.StartSearchHistoryWorker(context.Background())
+	service.StartSearchDigestWorker(context.Background())
+	service.StartViewCounter(context.Background())
 	payment.InitPaymentService()
 	// init gin
 	server := newServer(logger)
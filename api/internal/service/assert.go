@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"net/http"
 	"path/filepath"
 
 	resourcemanager "github.com/mislu/market-api/internal/core/resource_manager"
@@ -10,7 +11,12 @@ import (
 	"github.com/mislu/market-api/internal/utils/app"
 )
 
-func GetAssert(req *request.GetAssertReq) (string, exceptions.APIError) {
+func GetAssert(req *request.GetAssertReq, userID string) (string, exceptions.APIError) {
+	// 会话中的媒体文件仅会话双方可以访问
+	if resourcemanager.BucketType(req.Type) == resourcemanager.ConversationBucket && !isConversationParticipant(req.Owner, userID) {
+		return "", exceptions.NewGenericError(http.StatusForbidden, exceptions.NotConversationParticipantError, errNotConversationParticipant)
+	}
+
 	path := resourcemanager.GetObjectPath(resourcemanager.BucketType(req.Type), req.Owner, req.Key)
 	exists, err := resourcemanager.FileExists(path)
 	if err != nil {
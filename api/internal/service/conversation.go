@@ -1,14 +1,21 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"mime"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/mislu/market-api/internal/core/media"
+	"github.com/mislu/market-api/internal/core/recommend"
 	resourcemanager "github.com/mislu/market-api/internal/core/resource_manager"
 	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
 	"github.com/mislu/market-api/internal/types/exceptions"
 	"github.com/mislu/market-api/internal/types/models"
 	"github.com/mislu/market-api/internal/types/request"
@@ -22,7 +29,32 @@ const (
 	text  = "text"
 	image = "image"
 	video = "video"
-	link  = "link"
+	link  = "link" // 旧版商品链接，按商品卡片处理
+
+	productCard = "product"
+	orderCard   = "order"
+)
+
+const (
+	messageRecallLimit = 2 * time.Minute
+	messageEditLimit   = 15 * time.Minute
+
+	recalledMessagePreview = "[消息已撤回]"
+	maxPreviewLength       = 200
+
+	maxMessagePageSize = 100
+)
+
+var (
+	errMessageNotFound      = errors.New("message not found")
+	errNotMessageSender     = errors.New("user is not the sender of the message")
+	errMessageRecallExpired = errors.New("message recall time limit exceeded")
+	errMessageEditExpired   = errors.New("message edit time limit exceeded")
+	errMessageNotEditable   = errors.New("message is not editable")
+	errUnsupportedMediaType = errors.New("unsupported message media type")
+	errOrderNotRelated      = errors.New("order is not related with the conversation")
+
+	errNotConversationParticipant = errors.New("user is not a participant of the conversation")
 )
 
 func SaveMessage(raw *request.Message) error {
@@ -31,6 +63,15 @@ func SaveMessage(raw *request.Message) error {
 		return err
 	}
 
+	blocked, err := isBlocked(raw.From, raw.To)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return errUserBlocked
+	}
+
 	raw.ID = id
 	conversationID := getConversationID(raw.From, raw.To)
 
@@ -45,19 +86,38 @@ func SaveMessage(raw *request.Message) error {
 
 	switch raw.MediaType {
 	case text:
-		message.Content = raw.Content
+		content, err := moderateContent(raw.Content)
+		if err != nil {
+			return err
+		}
+		message.Content = content
 	case image, video:
 		// 媒体文件通过http上传，这里默认为url
 		message.Content = raw.Content
-	case link:
-		// 商品链接
-		// TODO implement
-		message.Content = raw.Content
+	case link, productCard:
+		// 商品卡片，content为商品id
+		content, err := buildProductCard(raw.Content)
+		if err != nil {
+			return err
+		}
+		message.MediaType = productCard
+		message.Content = content
+	case orderCard:
+		// 订单卡片，content为订单id
+		content, err := buildOrderCard(raw.Content, raw.From, raw.To)
+		if err != nil {
+			return err
+		}
+		message.Content = content
 	default:
-		return nil
+		return errUnsupportedMediaType
 	}
 
-	return db.WithTransaction(func(tx *gorm.DB) error {
+	// 将解析后的卡片回写，保证接收方拿到与落盘一致的内容
+	raw.MediaType = message.MediaType
+	raw.Content = message.Content
+
+	err = db.WithTransaction(func(tx *gorm.DB) error {
 		// 消息落盘
 		err := db.Create(message, tx)
 		if err != nil {
@@ -75,7 +135,7 @@ func SaveMessage(raw *request.Message) error {
 
 		conversation.MarkDeleted = false
 		conversation.LastMessageTime = message.Timestamp
-		conversation.LastMessageContent = message.Content
+		conversation.LastMessageContent = messagePreview(message)
 
 		toUsersConversation, err := db.GetOne[models.Conversation](
 			db.Equal("to_user_id", message.FromUserID),
@@ -83,12 +143,298 @@ func SaveMessage(raw *request.Message) error {
 		)
 		toUsersConversation.MarkDeleted = false
 		toUsersConversation.LastMessageTime = message.Timestamp
-		toUsersConversation.LastMessageContent = message.Content
+		toUsersConversation.LastMessageContent = messagePreview(message)
 		if err := db.Update(&toUsersConversation, tx); err != nil {
 			return err
 		}
 		return db.Update(&conversation, tx)
 	})
+	if err != nil {
+		return err
+	}
+
+	go indexMessage(*message)
+	return nil
+}
+
+// RecallMessage 撤回消息，仅发送者可在时限内撤回，撤回状态会持久化
+func RecallMessage(userID, messageID string) (models.Message, exceptions.APIError) {
+	message, apiErr := getSenderMessage(userID, messageID)
+	if apiErr != nil {
+		return message, apiErr
+	}
+
+	if message.IsRecalled {
+		return message, nil
+	}
+
+	if time.Since(message.Timestamp) > messageRecallLimit {
+		return message, exceptions.BadRequestError(errMessageRecallExpired, exceptions.MessageRecallExpiredError)
+	}
+
+	message.IsRecalled = true
+	message.Content = ""
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Update(&message, tx); err != nil {
+			return err
+		}
+
+		if err := refreshConversationPreview(message.FromUserID, message.ToUserID, tx); err != nil {
+			return err
+		}
+
+		return refreshConversationPreview(message.ToUserID, message.FromUserID, tx)
+	})
+	if err != nil {
+		return message, exceptions.InternalServerError(err)
+	}
+
+	go unindexMessage(message.ID)
+	return message, nil
+}
+
+// EditMessage 编辑文本消息，仅发送者可在时限内编辑
+func EditMessage(userID, messageID, content string) (models.Message, exceptions.APIError) {
+	message, apiErr := getSenderMessage(userID, messageID)
+	if apiErr != nil {
+		return message, apiErr
+	}
+
+	if message.IsRecalled || message.MediaType != text || len(content) == 0 {
+		return message, exceptions.BadRequestError(errMessageNotEditable, exceptions.MessageNotEditableError)
+	}
+
+	if time.Since(message.Timestamp) > messageEditLimit {
+		return message, exceptions.BadRequestError(errMessageEditExpired, exceptions.MessageEditExpiredError)
+	}
+
+	content, err := moderateContent(content)
+	if err != nil {
+		return message, exceptions.BadRequestError(err, exceptions.ContentRejectedError)
+	}
+
+	message.Content = content
+	message.IsEdited = true
+
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Update(&message, tx); err != nil {
+			return err
+		}
+
+		if err := refreshConversationPreview(message.FromUserID, message.ToUserID, tx); err != nil {
+			return err
+		}
+
+		return refreshConversationPreview(message.ToUserID, message.FromUserID, tx)
+	})
+	if err != nil {
+		return message, exceptions.InternalServerError(err)
+	}
+
+	go indexMessage(message)
+	return message, nil
+}
+
+// DeleteMessage 仅对当前用户隐藏消息，对方不受影响
+func DeleteMessage(req *request.DeleteMessageReq, userID string) exceptions.APIError {
+	message, err := db.GetOne[models.Message](
+		db.Equal("id", req.MessageID),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if !message.Exists() || (message.FromUserID != userID && message.ToUserID != userID) {
+		return exceptions.BadRequestError(errMessageNotFound, exceptions.MessageNotFoundError)
+	}
+
+	peerID := message.ToUserID
+	if message.ToUserID == userID {
+		peerID = message.FromUserID
+	}
+
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		deletion := &models.MessageDeletion{
+			MessageID: message.ID,
+			UserID:    userID,
+		}
+		if err := db.FirstOrCreate(deletion, tx); err != nil {
+			return err
+		}
+
+		return refreshConversationPreview(userID, peerID, tx)
+	})
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if message.MediaType == text {
+		go hideIndexedMessage(message.ID)
+	}
+	return nil
+}
+
+func getSenderMessage(userID, messageID string) (models.Message, exceptions.APIError) {
+	message, err := db.GetOne[models.Message](
+		db.Equal("id", messageID),
+	)
+	if err != nil {
+		return message, exceptions.InternalServerError(err)
+	}
+
+	if !message.Exists() {
+		return message, exceptions.BadRequestError(errMessageNotFound, exceptions.MessageNotFoundError)
+	}
+
+	if message.FromUserID != userID {
+		return message, exceptions.BadRequestError(errNotMessageSender, exceptions.NotMessageSenderError)
+	}
+
+	return message, nil
+}
+
+// refreshConversationPreview 根据ownerID可见的最后一条消息重新计算会话预览
+func refreshConversationPreview(ownerID, peerID string, tx *gorm.DB) error {
+	conversation, err := db.GetOne[models.Conversation](
+		db.WithTransactionContext(tx),
+		db.Equal("from_user_id", ownerID),
+		db.Equal("to_user_id", peerID),
+	)
+	if err != nil {
+		return err
+	}
+
+	if conversation.ID == 0 {
+		return nil
+	}
+
+	lastMessage, err := db.GetOne[models.Message](
+		db.WithTransactionContext(tx),
+		db.Equal("conversation_id", getConversationID(ownerID, peerID)),
+		db.WhereSQL("id NOT IN (SELECT message_id FROM message_deletion WHERE user_id = ?)", ownerID),
+		db.OrderBy("id", true),
+	)
+	if err != nil {
+		return err
+	}
+
+	conversation.LastMessageContent = messagePreview(&lastMessage)
+	return db.Update(&conversation, tx)
+}
+
+func messagePreview(message *models.Message) string {
+	if message.IsRecalled {
+		return recalledMessagePreview
+	}
+
+	content := message.Content
+	switch card := decodeCard(message).(type) {
+	case *models.ProductCard:
+		content = "[商品] " + card.Describe
+	case *models.OrderCard:
+		content = "[订单] " + card.Describe
+	}
+
+	// 按字符截断，避免超出last_message_content的长度或截断半个字符
+	preview := []rune(content)
+	if len(preview) > maxPreviewLength {
+		preview = preview[:maxPreviewLength]
+	}
+	return string(preview)
+}
+
+// buildProductCard 生成发送时刻的商品快照
+func buildProductCard(productID string) (string, error) {
+	product, err := db.GetOne[models.Product](
+		db.Equal("id", productID),
+		db.Equal("is_published", true),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if !product.Exists() {
+		return "", errProductNotFound
+	}
+
+	card := models.ProductCard{
+		ProductID: product.ID,
+		Describe:  product.Describe,
+		Price:     product.Price,
+		Pic:       firstPic(product.Pics),
+		IsSold:    product.IsSold,
+		IsSelling: product.IsSelling,
+	}
+
+	data, err := json.Marshal(card)
+	return string(data), err
+}
+
+// buildOrderCard 生成订单快照，仅订单的买卖双方可在彼此的会话中发送
+func buildOrderCard(orderID, from, to string) (string, error) {
+	order, err := db.GetOne[models.Order](
+		db.Equal("id", orderID),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if !order.Exists() {
+		return "", errOrderNotFound
+	}
+
+	related := (order.IsOwner(from) && order.IsSeller(to)) || (order.IsSeller(from) && order.IsOwner(to))
+	if !related {
+		return "", errOrderNotRelated
+	}
+
+	product, err := db.GetOne[models.Product](
+		db.Fields("id", "describe", "pics"),
+		db.Equal("id", order.ProductID),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	card := models.OrderCard{
+		OrderID:     order.ID,
+		ProductID:   order.ProductID,
+		Describe:    product.Describe,
+		Pic:         firstPic(product.Pics),
+		Status:      order.Status,
+		TotalAmount: order.TotalAmount,
+	}
+
+	data, err := json.Marshal(card)
+	return string(data), err
+}
+
+// decodeCard 解析卡片消息的内容，非卡片消息返回nil
+func decodeCard(message *models.Message) any {
+	if message.IsRecalled {
+		return nil
+	}
+
+	var card any
+	switch message.MediaType {
+	case productCard:
+		card = &models.ProductCard{}
+	case orderCard:
+		card = &models.OrderCard{}
+	default:
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(message.Content), card); err != nil {
+		return nil
+	}
+	return card
+}
+
+func firstPic(pics string) string {
+	pic, _, _ := strings.Cut(pics, ",")
+	return pic
 }
 
 func getConversationID(from, to string) string {
@@ -98,93 +444,149 @@ func getConversationID(from, to string) string {
 	return fmt.Sprintf("%s:%s", from, to)
 }
 
-func UploadMediaFile(req *request.UploadMediaFileReq) (response.UploadMediaFileResp, exceptions.APIError) {
+func UploadMediaFile(req *request.UploadMediaFileReq, userID string) (response.UploadMediaFileResp, exceptions.APIError) {
 	var resp response.UploadMediaFileResp
 
-	mediaType := GetCategoryByExt(req.File.Filename)
-	switch mediaType {
-	case image:
-		if req.File.Size > picMaxSize {
+	if !isConversationParticipant(req.ConversationID, userID) {
+		return resp, exceptions.BadRequestError(errNotConversationParticipant, exceptions.NotConversationParticipantError)
+	}
+
+	if req.File == nil {
+		return resp, exceptions.BadRequestError(errors.New("file is required"), exceptions.ParameterBindingError)
+	}
+
+	if req.File.Size > max(picMaxSize, videoMaxSize) {
+		return resp, exceptions.BadRequestError(errors.New("file size exceeds limit"), exceptions.VideoFileSizeExceedError)
+	}
+
+	file, err := req.File.Open()
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	// 以文件内容判断类型，不信任扩展名
+	detected, err := media.Sniff(data)
+	if err != nil {
+		return resp, exceptions.BadRequestError(err, exceptions.UnsupportedFileTypeError)
+	}
+
+	filename := strings.TrimSuffix(req.File.Filename, filepath.Ext(req.File.Filename)) + detected.Ext
+	key := resourcemanager.GenerateObjectKey(filename)
+	resp.MediaType = detected.Category
+
+	switch detected.Category {
+	case media.CategoryImage:
+		if len(data) > picMaxSize {
 			return resp, exceptions.BadRequestError(errors.New("file size exceeds limit"), exceptions.ImageFileSizeExceedError)
 		}
-	case video:
 
-	default:
-		return resp, exceptions.BadRequestError(errors.New("unsupported file type"), exceptions.UnsupportedFileTypeError)
+		data = media.StripMetadata(data, detected.MIME)
+		variants, ok, err := media.ProcessImage(data)
+		if errors.Is(err, media.ErrImageTooLarge) {
+			return resp, exceptions.BadRequestError(err, exceptions.ImageDimensionExceedError)
+		}
+		if err != nil {
+			return resp, exceptions.BadRequestError(err, exceptions.InvalidMediaFileError)
+		}
+
+		if ok {
+			resp.Width = variants.Width
+			resp.Height = variants.Height
+
+			thumbnailKey := resourcemanager.GenerateVariantKey(key, "thumb", ".jpg")
+			resp.ThumbnailUrl, err = saveConversationFile(req.ConversationID, thumbnailKey, variants.Thumbnail)
+			if err != nil {
+				return resp, exceptions.InternalServerError(err)
+			}
+
+			compressedKey := resourcemanager.GenerateVariantKey(key, "compressed", ".jpg")
+			resp.CompressedUrl, err = saveConversationFile(req.ConversationID, compressedKey, variants.Compressed)
+			if err != nil {
+				return resp, exceptions.InternalServerError(err)
+			}
+		}
+	case media.CategoryVideo:
+		if len(data) > videoMaxSize {
+			return resp, exceptions.BadRequestError(errors.New("file size exceeds limit"), exceptions.VideoFileSizeExceedError)
+		}
+
+		// 无法读取时长的视频(如webm)无法限制时长，不接受
+		info, err := media.ProbeVideo(data, detected.MIME)
+		if err != nil {
+			return resp, exceptions.BadRequestError(err, exceptions.VideoDurationUnknownError)
+		}
+		if info.Duration > videoMaxDuration {
+			return resp, exceptions.BadRequestError(errors.New("video duration exceeds limit"), exceptions.VideoDurationExceedError)
+		}
+		resp.Duration = info.Duration.Seconds()
+
+		// 封面抽取失败不影响视频上传
+		poster, err := media.ExtractPoster(data, detected.MIME)
+		if err == nil && len(poster) > 0 {
+			posterKey := resourcemanager.GenerateVariantKey(key, "poster", ".jpg")
+			resp.PosterUrl, _ = saveConversationFile(req.ConversationID, posterKey, poster)
+		}
+	}
+
+	resp.Url, err = saveConversationFile(req.ConversationID, key, data)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
 	}
-	key := resourcemanager.GenerateObjectKey(req.File.Filename)
-	resp.Url = lib.GetResourceURL(int(resourcemanager.ConversationBucket), req.ConversationID, key)
+
 	return resp, nil
 }
 
-var extToMIME = map[string]string{
-	// 图片类型
-	"jpg":  "image/jpeg",
-	"jpeg": "image/jpeg",
-	"png":  "image/png",
-	"gif":  "image/gif",
-	"webp": "image/webp",
-	"bmp":  "image/bmp",
-	"tiff": "image/tiff",
-
-	// 视频类型
-	"mp4":  "video/mp4",
-	"mov":  "video/quicktime",
-	"avi":  "video/x-msvideo",
-	"mkv":  "video/x-matroska",
-	"webm": "video/webm",
-	"flv":  "video/x-flv",
-	"wmv":  "video/x-ms-wmv",
+func saveConversationFile(conversationID, key string, data []byte) (string, error) {
+	path := resourcemanager.GetObjectPath(resourcemanager.ConversationBucket, conversationID, key)
+	if err := resourcemanager.UploadFile(resourcemanager.ConversationBucket, path, data); err != nil {
+		return "", err
+	}
+
+	return lib.GetResourceURL(int(resourcemanager.ConversationBucket), conversationID, key), nil
 }
 
-// MIME类型 -> 分类映射表
-var mimeToCategory = map[string]string{
-	// 图片
-	"image/jpeg":    "image",
-	"image/png":     "image",
-	"image/gif":     "image",
-	"image/webp":    "image",
-	"image/bmp":     "image",
-	"image/tiff":    "image",
-	"image/svg+xml": "image",
-
-	// 视频
-	"video/mp4":             "video",
-	"video/quicktime":       "video",
-	"video/x-msvideo":       "video",
-	"video/x-matroska":      "video",
-	"video/webm":            "video",
-	"video/x-flv":           "video",
-	"video/x-ms-wmv":        "video",
-	"application/x-mpegURL": "video", // HLS流
+// isConversationParticipant 会话id由双方userID排序拼接而成
+func isConversationParticipant(conversationID, userID string) bool {
+	from, to, ok := strings.Cut(conversationID, ":")
+	if !ok || getConversationID(from, to) != conversationID {
+		return false
+	}
+
+	return len(userID) > 0 && (from == userID || to == userID)
 }
 
-// 通过扩展名获取文件分类
-func GetCategoryByExt(ext string) string {
-	// 统一处理扩展名格式
-	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
-
-	// 获取MIME类型
-	mimeType, ok := extToMIME[ext]
-	if !ok {
-		// 尝试通过系统MIME类型检测
-		if detected := mime.TypeByExtension("." + ext); detected != "" {
-			mimeType = detected
-		} else {
-			return ""
+func CreateConversation(req *request.CreateConversationReq) exceptions.APIError {
+	var productOwner string
+	if len(req.ProductID) > 0 {
+		product, err := db.GetOne[models.Product](
+			db.Fields("id", "user_id"),
+			db.Equal("id", req.ProductID),
+		)
+		if err != nil {
+			return exceptions.InternalServerError(err)
+		}
+
+		if !product.Exists() || (!product.IsOwner(req.FromUserID) && !product.IsOwner(req.ToUserID)) {
+			return exceptions.BadRequestError(errProductNotFound, exceptions.ProductNotFoundError)
 		}
+		productOwner = product.UserID
 	}
 
-	// 返回分类
-	return mimeToCategory[mimeType]
-}
+	blocked, err := isBlocked(req.FromUserID, req.ToUserID)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
 
-func GetCategoryByMIME(mimeType string) string {
-	baseMIME, _, _ := mime.ParseMediaType(mimeType)
-	return mimeToCategory[baseMIME]
-}
+	if blocked {
+		return exceptions.BadRequestError(errUserBlocked, exceptions.UserBlockedError)
+	}
 
-func CreateConversation(req *request.CreateConversationReq) exceptions.APIError {
 	fromConversation, err := db.GetOne[models.Conversation](
 		db.Equal("from_user_id", req.FromUserID),
 		db.Equal("to_user_id", req.ToUserID),
@@ -206,6 +608,9 @@ func CreateConversation(req *request.CreateConversationReq) exceptions.APIError
 		if fromConversation.FromUserID == req.FromUserID {
 			fromConversation.MarkDeleted = false
 			fromConversation.LastMessageTime = time.Now()
+			if len(req.ProductID) > 0 {
+				fromConversation.ProductID = req.ProductID
+			}
 			err := db.Update(&fromConversation, tx)
 			if err != nil {
 				return err
@@ -213,6 +618,9 @@ func CreateConversation(req *request.CreateConversationReq) exceptions.APIError
 
 			toConversation.MarkDeleted = false
 			toConversation.LastMessageTime = time.Now()
+			if len(req.ProductID) > 0 {
+				toConversation.ProductID = req.ProductID
+			}
 			err = db.Update(&toConversation, tx)
 			if err != nil {
 				return err
@@ -226,11 +634,13 @@ func CreateConversation(req *request.CreateConversationReq) exceptions.APIError
 				FromUserID:      req.FromUserID,
 				ToUserID:        req.ToUserID,
 				LastMessageTime: time.Now(),
+				ProductID:       req.ProductID,
 			},
 			&models.Conversation{
 				FromUserID:      req.ToUserID,
 				ToUserID:        req.FromUserID,
 				LastMessageTime: time.Now(),
+				ProductID:       req.ProductID,
 			},
 		}
 
@@ -246,33 +656,59 @@ func CreateConversation(req *request.CreateConversationReq) exceptions.APIError
 		return exceptions.InternalServerError(err)
 	}
 
+	// 买家从商品页联系卖家
+	if len(req.ProductID) > 0 {
+		recordFeedback(models.Feedback{
+			UserID:       req.FromUserID,
+			ItemID:       req.ProductID,
+			FeedbackType: recommend.FeedbackChat,
+		}, productOwner)
+	}
+
 	return nil
 }
 
-func RecordLastReadMessage(fromUserID, toUserID string, lastMessageID string) error {
-	// TODO 重新思考一下何时更新 可以先每次收到之后旧更新保证效果
-	conversation, err := db.GetOne[models.Conversation](
-		// 获取接收方的conversation
-		db.Equal("from_user_id", toUserID),
-		db.Equal("to_user_id", fromUserID),
+// MarkMessagesRead 将userID在与peerID会话中的已读游标推进到messageID，游标只前进不后退。
+// 返回游标是否发生变化
+func MarkMessagesRead(userID, peerID, messageID string) (bool, exceptions.APIError) {
+	message, err := db.GetOne[models.Message](
+		db.Equal("id", messageID),
+		db.Equal("conversation_id", getConversationID(userID, peerID)),
 	)
-
 	if err != nil {
-		return err
+		return false, exceptions.InternalServerError(err)
 	}
 
-	message, err := db.GetOne[models.Message](
-		db.Fields("id"),
-		db.LessThan("id", lastMessageID),
-		db.Page(1, 1),
-	)
+	if !message.Exists() {
+		return false, exceptions.BadRequestError(errMessageNotFound, exceptions.MessageNotFoundError)
+	}
 
+	conversation, err := db.GetOne[models.Conversation](
+		db.Equal("from_user_id", userID),
+		db.Equal("to_user_id", peerID),
+	)
 	if err != nil {
-		return err
+		return false, exceptions.InternalServerError(err)
+	}
+
+	if conversation.ID == 0 || compareMessageID(message.ID, conversation.LastReadMessageID) <= 0 {
+		return false, nil
 	}
 
 	conversation.LastReadMessageID = message.ID
-	return db.Update(&conversation)
+	if err := db.Update(&conversation); err != nil {
+		return false, exceptions.InternalServerError(err)
+	}
+
+	return true, nil
+}
+
+// compareMessageID 比较两个snowflake id的先后
+func compareMessageID(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
 }
 
 func GetConversationList(req *request.GetConversationListReq) (response.GetConversationListResp, exceptions.APIError) {
@@ -300,26 +736,13 @@ func GetConversationList(req *request.GetConversationListReq) (response.GetConve
 		unreadCount, err := db.GetCount[models.Message](
 			db.GreaterThan("id", conversation.LastReadMessageID),
 			db.Equal("conversation_id", getConversationID(conversation.FromUserID, conversation.ToUserID)),
+			db.Equal("to_userd_id", conversation.FromUserID),
+			db.Equal("is_recalled", false),
 		)
 		if err != nil {
 			return nil, exceptions.InternalServerError(err)
 		}
 
-		lastMessage, err := db.GetOne[models.Message](
-			db.Equal("conversation_id", getConversationID(conversation.FromUserID, conversation.ToUserID)),
-			db.OrderBy("id", true),
-		)
-		if err != nil {
-			return nil, exceptions.InternalServerError(err)
-		}
-
-		conversation.LastReadMessageID = lastMessage.ID
-
-		err = db.Update(conversation)
-		if err != nil {
-			return nil, exceptions.InternalServerError(err)
-		}
-
 		resp = append(resp, response.ConversationWithUnReadCount{
 			User:         user,
 			UnreadCount:  int(unreadCount),
@@ -332,36 +755,235 @@ func GetConversationList(req *request.GetConversationListReq) (response.GetConve
 
 func GetMessages(req *request.GetMessagesReq) (response.GetMessagesResp, exceptions.APIError) {
 	var resp response.GetMessagesResp
-	resp.Page = req.Page
-	resp.Size = req.Size
+	size := min(max(req.Size, 1), maxMessagePageSize)
+	resp.Size = size
 
-	messages, err := db.GetAll[models.Message](
+	visible := []db.GenericQuery{
 		db.Equal("conversation_id", getConversationID(req.FromUserID, req.ToUserID)),
-		db.OrderBy("id", true),
+		db.WhereSQL("id NOT IN (SELECT message_id FROM message_deletion WHERE user_id = ?)", req.FromUserID),
+	}
+
+	var (
+		messages []models.Message
+		err      error
 	)
 
+	if len(req.Around) > 0 {
+		// 跳转到指定消息，返回其前后各size/2条消息作为上下文
+		target, err := db.GetOne[models.Message](append(visible, db.Equal("id", req.Around))...)
+		if err != nil {
+			return resp, exceptions.InternalServerError(err)
+		}
+
+		if !target.Exists() {
+			return resp, exceptions.BadRequestError(errMessageNotFound, exceptions.MessageNotFoundError)
+		}
+
+		newer, hasNewer, err := loadMessagePage(visible, "", target.ID, size/2)
+		if err != nil {
+			return resp, exceptions.InternalServerError(err)
+		}
+
+		older, hasOlder, err := loadMessagePage(visible, target.ID, "", size/2)
+		if err != nil {
+			return resp, exceptions.InternalServerError(err)
+		}
+
+		messages = append(append(newer, target), older...)
+		resp.HasNewer, resp.HasOlder = hasNewer, hasOlder
+	} else {
+		var hasMore bool
+		messages, hasMore, err = loadMessagePage(visible, req.Before, req.After, size)
+		if err != nil {
+			return resp, exceptions.InternalServerError(err)
+		}
+
+		if len(req.After) > 0 {
+			resp.HasNewer, resp.HasOlder = hasMore, true
+		} else {
+			resp.HasOlder, resp.HasNewer = hasMore, len(req.Before) > 0
+		}
+	}
+
+	resp.HasMore = resp.HasOlder
+	resp.Messages = make([]response.ChatMessage, 0, len(messages))
+	for i := range messages {
+		resp.Messages = append(resp.Messages, response.ChatMessage{
+			Message: messages[i],
+			Card:    decodeCard(&messages[i]),
+		})
+	}
+	return resp, nil
+}
+
+// loadMessagePage 以消息id为游标加载一页消息，before/after为开区间，结果按id降序排列
+func loadMessagePage(base []db.GenericQuery, before, after string, size int) ([]models.Message, bool, error) {
+	queries := append([]db.GenericQuery{}, base...)
+	if len(before) > 0 {
+		queries = append(queries, db.LessThan("id", before))
+	}
+
+	// 向后翻页时从游标处升序读取，保证取到的是紧邻游标的消息
+	desc := len(after) == 0
+	if !desc {
+		queries = append(queries, db.GreaterThan("id", after))
+	}
+
+	queries = append(queries, db.OrderBy("id", desc), db.Page(1, size+1))
+	messages, err := db.GetAll[models.Message](queries...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > size
+	if hasMore {
+		messages = messages[:size]
+	}
+
+	if !desc {
+		slices.Reverse(messages)
+	}
+	return messages, hasMore, nil
+}
+
+// SearchMessages 在用户自己的会话中全文检索文本消息
+func SearchMessages(req *request.SearchMessagesReq, userID string) (response.SearchMessagesResp, exceptions.APIError) {
+	var resp response.SearchMessagesResp
+	page := max(req.Page, 1)
+	size := min(max(req.Size, 1), maxMessagePageSize)
+	resp.Page = page
+	resp.Size = size
+
+	filters := []map[string]interface{}{
+		{"term": map[string]interface{}{"participants": userID}},
+	}
+	if len(req.PeerID) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{"conversation_id": getConversationID(userID, req.PeerID)},
+		})
+	}
+
+	query := map[string]interface{}{
+		"from": (page - 1) * size,
+		"size": size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": []map[string]interface{}{
+					{"match": map[string]interface{}{"content": req.Keyword}},
+				},
+				"filter": filters,
+				"must_not": []map[string]interface{}{
+					{"term": map[string]interface{}{"deleted_for": userID}},
+				},
+			},
+		},
+		"sort": []interface{}{
+			"_score",
+			map[string]interface{}{"timestamp": map[string]interface{}{"order": "desc"}},
+		},
+	}
+
+	hits, err := es.Search(es.MessageIndex, query)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	if len(messages) == 0 {
+	messageIDs := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		if id, ok := hit["id"].(string); ok {
+			messageIDs = append(messageIDs, id)
+		}
+	}
+
+	resp.Messages = make([]response.ChatMessage, 0, len(messageIDs))
+	if len(messageIDs) == 0 {
 		return resp, nil
 	}
 
-	conversation, err := db.GetOne[models.Conversation](
-		db.Equal("from_user_id", req.FromUserID),
-		db.Equal("to_user_id", req.ToUserID),
+	// 以数据库为准再次过滤，避免索引更新延迟导致返回已撤回或已删除的消息
+	messages, err := db.GetAll[models.Message](
+		db.InArray("id", messageIDs),
+		db.Equal("is_recalled", false),
+		db.WhereSQL("id NOT IN (SELECT message_id FROM message_deletion WHERE user_id = ?)", userID),
 	)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	conversation.LastReadMessageID = messages[0].ID
-	err = db.Update(conversation)
-	if err != nil {
-		return resp, exceptions.InternalServerError(err)
+	messageMap := make(map[string]models.Message, len(messages))
+	for _, message := range messages {
+		messageMap[message.ID] = message
+	}
+
+	for _, id := range messageIDs {
+		if message, ok := messageMap[id]; ok {
+			resp.Messages = append(resp.Messages, response.ChatMessage{Message: message})
+		}
 	}
 
-	resp.Messages = messages
 	return resp, nil
 }
+
+// indexMessage 将文本消息写入消息索引，失败只记录日志
+func indexMessage(message models.Message) {
+	if message.MediaType != text || message.IsRecalled {
+		return
+	}
+
+	document, err := buildMessageDocument(message)
+	if err != nil {
+		log.Printf("failed to load deletions of message %s: %v", message.ID, err)
+	}
+
+	if err := es.IndexDocument(es.MessageIndex, message.ID, document); err != nil {
+		log.Printf("failed to index message %s: %v", message.ID, err)
+	}
+}
+
+// buildMessageDocument 组装消息在es中的文档，加载失败时仍返回不含删除列表的文档
+func buildMessageDocument(message models.Message) (*request.MessageDocument, error) {
+	document := &request.MessageDocument{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		FromUserID:     message.FromUserID,
+		Participants:   []string{message.FromUserID, message.ToUserID},
+		Content:        message.Content,
+		Timestamp:      message.Timestamp,
+	}
+
+	deletions, err := db.GetAll[models.MessageDeletion](
+		db.Equal("message_id", message.ID),
+	)
+	for _, deletion := range deletions {
+		document.DeletedFor = append(document.DeletedFor, deletion.UserID)
+	}
+
+	return document, err
+}
+
+func unindexMessage(messageID string) {
+	if err := es.DeleteDocument(es.MessageIndex, messageID); err != nil {
+		log.Printf("failed to delete message %s from index: %v", messageID, err)
+	}
+}
+
+// hideIndexedMessage 同步"仅对自己删除"的用户列表到消息索引
+func hideIndexedMessage(messageID string) {
+	deletions, err := db.GetAll[models.MessageDeletion](
+		db.Equal("message_id", messageID),
+	)
+	if err != nil {
+		log.Printf("failed to load deletions of message %s: %v", messageID, err)
+		return
+	}
+
+	deletedFor := make([]string, 0, len(deletions))
+	for _, deletion := range deletions {
+		deletedFor = append(deletedFor, deletion.UserID)
+	}
+
+	err = es.UpdateDocument(es.MessageIndex, messageID, map[string]interface{}{"deleted_for": deletedFor})
+	if err != nil {
+		log.Printf("failed to update deletions of message %s in index: %v", messageID, err)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/experiment"
+	"github.com/mislu/market-api/internal/core/recommend"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+	"github.com/mislu/market-api/internal/utils/app"
+)
+
+// 首页推荐策略
+const (
+	StrategyRecommend = "recommend" // 推荐服务，不可用时使用兜底推荐
+	StrategyLocal     = "local"     // 只使用兜底推荐
+	StrategyLatest    = "latest"    // 不推荐，只展示最新商品
+
+	defaultExperiment    = "default"
+	anonymousBucket      = "anonymous" // 未登录用户，不参与实验
+	defaultMetricsDays   = 7
+	feedClickSource      = "feed"
+	maxExperimentBuckets = 20
+)
+
+// feedExperiment 配置中的首页推荐实验，未配置分组时所有用户使用推荐服务
+func feedExperiment() experiment.Experiment {
+	config := app.GetConfig().Experiment.Feed
+
+	e := experiment.Experiment{Name: config.Name}
+	if len(e.Name) == 0 {
+		e.Name = defaultExperiment
+	}
+	for _, bucket := range config.Buckets {
+		e.Buckets = append(e.Buckets, experiment.Bucket{
+			Name:     bucket.Name,
+			Weight:   bucket.Weight,
+			Strategy: bucket.Strategy,
+		})
+	}
+	if len(e.Buckets) == 0 {
+		e.Buckets = []experiment.Bucket{{Name: defaultExperiment, Weight: 1, Strategy: StrategyRecommend}}
+	}
+
+	return e
+}
+
+// feedBucket 登录用户按ID分组；未登录用户没有稳定的ID，统一使用推荐服务
+func feedBucket(e experiment.Experiment, userID string) experiment.Bucket {
+	if len(userID) == 0 {
+		return experiment.Bucket{Name: anonymousBucket, Strategy: StrategyRecommend}
+	}
+	return e.Assign(userID)
+}
+
+// feedRecommendations 按用户所在分组的策略生成推荐
+func feedRecommendations(userID string, bucket experiment.Bucket) ([]string, error) {
+	switch bucket.Strategy {
+	case StrategyLatest:
+		return nil, nil
+	case StrategyLocal:
+		return NewLocalRecommender().Recommend(context.Background(), userID, feedRecommendSize)
+	default:
+		return recommendProducts(userID, feedRecommendSize)
+	}
+}
+
+// logFeedImpressions 记录登录用户在推荐流中看到的商品，offset为本页第一个商品在会话中的位置
+func logFeedImpressions(userID string, experimentName string, bucket string, productIDs []string, offset int) {
+	if len(userID) == 0 || len(productIDs) == 0 {
+		return
+	}
+
+	impressions := make([]models.FeedImpression, 0, len(productIDs))
+	for i, productID := range productIDs {
+		impressions = append(impressions, models.FeedImpression{
+			UserID:     userID,
+			ProductID:  productID,
+			Experiment: experimentName,
+			Bucket:     bucket,
+			Position:   offset + i,
+		})
+	}
+
+	go func() {
+		if err := db.Create(&impressions); err != nil {
+			log.Printf("failed to log feed impressions: %v", err)
+		}
+	}()
+}
+
+// GetExperimentMetrics 按分组统计首页推荐的点击率、收藏率和购买转化率
+func GetExperimentMetrics(req *request.GetExperimentMetricsReq) (response.GetExperimentMetricsResp, exceptions.APIError) {
+	var resp response.GetExperimentMetricsResp
+
+	current := feedExperiment()
+	resp.Experiment = req.Experiment
+	if len(resp.Experiment) == 0 {
+		resp.Experiment = current.Name
+	}
+	days := req.Days
+	if days == 0 {
+		days = defaultMetricsDays
+	}
+	resp.Since = time.Now().AddDate(0, 0, -days)
+
+	impressions, err := db.GetAny[[]struct {
+		Bucket      string
+		Users       int64
+		Impressions int64
+	}](
+		"SELECT bucket, COUNT(DISTINCT user_id) AS users, COUNT(*) AS impressions FROM feed_impression "+
+			"WHERE experiment = ? AND created_at > ? GROUP BY bucket LIMIT ?",
+		resp.Experiment, resp.Since, maxExperimentBuckets,
+	)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	feedbacks, err := db.GetAny[[]struct {
+		Bucket       string
+		FeedbackType string
+		Count        int64
+		Users        int64
+	}](
+		"SELECT bucket, feedback_type, COUNT(*) AS count, COUNT(DISTINCT user_id) AS users FROM feedback "+
+			"WHERE experiment = ? AND timestamp > ? AND ((feedback_type = ? AND source = ?) OR feedback_type IN ?) "+
+			"GROUP BY bucket, feedback_type",
+		resp.Experiment, resp.Since, recommend.FeedbackClick, feedClickSource,
+		[]string{recommend.FeedbackLike, recommend.FeedbackPurchase},
+	)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	// 当前实验按配置的顺序列出所有分组，历史实验只列出有数据的分组
+	metrics := map[string]*response.ExperimentBucketMetrics{}
+	order := []string{}
+	bucketOf := func(name string) *response.ExperimentBucketMetrics {
+		if m, ok := metrics[name]; ok {
+			return m
+		}
+		m := &response.ExperimentBucketMetrics{Bucket: name}
+		metrics[name] = m
+		order = append(order, name)
+		return m
+	}
+	if resp.Experiment == current.Name {
+		for _, bucket := range current.Buckets {
+			bucketOf(bucket.Name).Strategy = bucket.Strategy
+		}
+	}
+
+	for _, row := range impressions {
+		m := bucketOf(row.Bucket)
+		m.Users = row.Users
+		m.Impressions = row.Impressions
+	}
+	for _, row := range feedbacks {
+		m := bucketOf(row.Bucket)
+		switch row.FeedbackType {
+		case recommend.FeedbackClick:
+			m.Clicks = row.Count
+		case recommend.FeedbackLike:
+			m.Likes = row.Count
+		case recommend.FeedbackPurchase:
+			m.Purchasers = row.Users
+		}
+	}
+
+	resp.Buckets = make([]response.ExperimentBucketMetrics, 0, len(order))
+	for _, name := range order {
+		m := metrics[name]
+		m.CTR = experiment.Rate(m.Clicks, m.Impressions)
+		m.LikeRate = experiment.Rate(m.Likes, m.Impressions)
+		m.Conversion = experiment.Rate(m.Purchasers, m.Users)
+		resp.Buckets = append(resp.Buckets, *m)
+	}
+
+	return resp, nil
+}
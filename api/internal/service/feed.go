@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mislu/market-api/internal/core/feed"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+const (
+	feedRecommendSize = 100
+	feedExploreEvery  = 4              // 每4个位置插入一个新发布的商品
+	feedFreshWindow   = 72 * time.Hour // 发布多久以内算新商品
+	feedMaxSeen       = 1000           // 每个会话最多记录的已展示商品
+	feedSessionTTL    = 30 * time.Minute
+)
+
+var feedSessions = feed.NewStore(feedSessionTTL)
+
+// GetProductList 首页推荐流，第一页生成新的浏览会话，之后按游标翻页并跳过已展示的商品；推荐策略由用户所在的实验分组决定
+func GetProductList(req *request.GetProductListReq, userID string) (response.GetProductListResp, exceptions.APIError) {
+	var resp response.GetProductListResp
+
+	experiment := feedExperiment()
+
+	cursor := req.Cursor
+	session, ok := feedSessions.Get(cursor)
+	if req.Page <= 1 || !ok {
+		cursor = uuid.New().String()
+		bucket := feedBucket(experiment, userID)
+		session = feed.Session{Bucket: bucket.Name}
+
+		recommendations, err := feedRecommendations(userID, bucket)
+		if err != nil {
+			// 推荐不可用时只展示最新商品；展示仍记在原分组，与无法区分会话的收藏和购买保持同一口径，降级只记录日志
+			log.Printf("failed to get %s recommendations for %q, falling back to latest: %v", bucket.Strategy, userID, err)
+		}
+		session.Recommended = recommendations
+	}
+
+	seen := make(map[string]bool, len(session.Seen))
+	for _, id := range session.Seen {
+		seen[id] = true
+	}
+
+	documents := make(map[string]json.RawMessage)
+	recommended, err := availableRecommendations(session.Recommended, seen, userID, documents)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	fresh, latest, err := latestListings(session.Seen, userID, 2*req.Size, documents)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	ids := feed.Blend(recommended, fresh, latest, seen, req.Size, feedExploreEvery)
+	hits := make([]json.RawMessage, 0, len(ids))
+	for _, id := range ids {
+		hits = append(hits, documents[id])
+	}
+
+	resp.Products, err = decodeProductDocuments(hits)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	logFeedImpressions(userID, experiment.Name, session.Bucket, ids, len(session.Seen))
+
+	session.Seen = append(session.Seen, ids...)
+	if len(session.Seen) > feedMaxSeen {
+		session.Seen = session.Seen[len(session.Seen)-feedMaxSeen:]
+	}
+	feedSessions.Put(cursor, session)
+
+	resp.Cursor = cursor
+	resp.HasMore = len(ids) == req.Size
+	resp.Page = req.Page
+	resp.Size = req.Size
+
+	return resp, nil
+}
+
+// availableRecommendations 按推荐顺序返回未展示、在售且不是自己发布的商品
+func availableRecommendations(recommendations []string, seen map[string]bool, userID string, documents map[string]json.RawMessage) ([]string, error) {
+	unseen := make([]string, 0, len(recommendations))
+	for _, id := range recommendations {
+		if !seen[id] {
+			unseen = append(unseen, id)
+		}
+	}
+
+	found, err := es.MultiGet(es.ProductIndex, unseen)
+	if err != nil {
+		return nil, err
+	}
+
+	available := make([]string, 0, len(found))
+	for _, id := range unseen {
+		raw, ok := found[id]
+		if !ok {
+			continue
+		}
+
+		var document request.ProductDocument
+		if err := json.Unmarshal(raw, &document); err != nil {
+			return nil, err
+		}
+		if !document.IsPublished || !document.IsSelling || document.IsSold || document.SellerID == userID {
+			continue
+		}
+
+		documents[id] = raw
+		available = append(available, id)
+	}
+
+	return available, nil
+}
+
+// latestListings 查询未展示的最新在售商品，返回其中新发布的商品和全部商品
+func latestListings(seen []string, userID string, size int, documents map[string]json.RawMessage) ([]string, []string, error) {
+	mustNot := []map[string]interface{}{}
+	if len(seen) > 0 {
+		mustNot = append(mustNot, map[string]interface{}{"ids": map[string]interface{}{"values": seen}})
+	}
+	if len(userID) > 0 {
+		mustNot = append(mustNot, map[string]interface{}{"term": map[string]interface{}{"seller_id": userID}})
+	}
+
+	query := map[string]interface{}{
+		"size": size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{"is_published": true}},
+					{"term": map[string]interface{}{"is_selling": true}},
+					{"term": map[string]interface{}{"is_sold": false}},
+				},
+				"must_not": mustNot,
+			},
+		},
+		"sort": []map[string]interface{}{
+			{"publish_at": map[string]interface{}{"order": "desc"}},
+		},
+	}
+
+	result, err := es.SearchWithAggregations(es.ProductIndex, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	freshSince := time.Now().Add(-feedFreshWindow)
+	fresh := make([]string, 0, len(result.Hits))
+	latest := make([]string, 0, len(result.Hits))
+	for _, raw := range result.Hits {
+		var document request.ProductDocument
+		if err := json.Unmarshal(raw, &document); err != nil {
+			return nil, nil, err
+		}
+
+		if _, ok := documents[document.ID]; !ok {
+			documents[document.ID] = raw
+		}
+		latest = append(latest, document.ID)
+		if document.PublishAt.After(freshSince) {
+			fresh = append(fresh, document.ID)
+		}
+	}
+
+	return fresh, latest, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mislu/market-api/internal/core/recommend"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+)
+
+// RecordFeedback 记录客户端上报的浏览、点击和分享，浏览只由客户端在离开详情页时上报一次，收藏、会话和购买由对应接口记录
+func RecordFeedback(req *request.RecordFeedbackReq) exceptions.APIError {
+	product, err := db.GetOne[models.Product](
+		db.Fields("id", "user_id"),
+		db.Equal("id", req.ProductID),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if !product.Exists() {
+		return exceptions.BadRequestError(errProductNotFound, exceptions.ProductNotFoundError)
+	}
+
+	recordFeedback(models.Feedback{
+		UserID:       req.UserID,
+		ItemID:       product.ID,
+		FeedbackType: req.Type,
+		Source:       req.Source,
+		Position:     req.Position,
+		Dwell:        req.Dwell,
+	}, product.UserID)
+
+	return nil
+}
+
+// recordFeedback 保存用户对他人商品的行为，并通过队列转发给推荐服务；失败只记录日志，不影响业务
+func recordFeedback(feedback models.Feedback, ownerID string) {
+	if len(feedback.UserID) == 0 || feedback.UserID == ownerID {
+		return
+	}
+
+	feedback.ID = uuid.New().String()
+	feedback.Timestamp = time.Now()
+	// 记录用户所在的实验分组，用于按分组统计指标
+	experiment := feedExperiment()
+	feedback.Experiment = experiment.Name
+	feedback.Bucket = feedBucket(experiment, feedback.UserID).Name
+
+	go func() {
+		if err := db.Create(&feedback); err != nil {
+			log.Printf("failed to record %s feedback: %v", feedback.FeedbackType, err)
+		}
+
+		err := recommend.GlobalWorker.InsertFeedback(context.Background(), []recommend.Feedback{{
+			UserId:       feedback.UserID,
+			ItemId:       feedback.ItemID,
+			FeedbackType: feedback.FeedbackType,
+			Timestamp:    feedback.Timestamp.Unix(),
+		}})
+		if err != nil {
+			log.Printf("failed to forward %s feedback: %v", feedback.FeedbackType, err)
+		}
+	}()
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/indexer"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/utils/app"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRelayInterval      = 30 * time.Second
+	defaultDriftCheckInterval = time.Hour
+	outboxRetryDelay          = 30 * time.Second // 首次重投前留给内存队列处理的时间
+	maxOutboxRetryDelay       = time.Hour
+	outboxBatchSize           = 100
+	driftBatchSize            = 200
+	backfillBatchSize         = 500
+	maxOutboxErrorLength      = 500
+	defaultOutboxRetention    = 72 * time.Hour
+)
+
+// indexEvents 业务事务中写入的outbox记录，事务提交后再投递到内存队列
+type indexEvents []models.IndexOutbox
+
+// add 在业务事务中写入outbox，事件与数据变更一起提交，提交后进程退出或处理失败时由RelayIndexOutbox重投
+func (e *indexEvents) add(tx *gorm.DB, eventType indexer.EventType, id string) error {
+	outbox := models.IndexOutbox{
+		EventType: string(eventType),
+		TargetID:  id,
+		NextRunAt: time.Now().Add(outboxRetryDelay),
+	}
+	if err := db.Create(&outbox, tx); err != nil {
+		return err
+	}
+
+	*e = append(*e, outbox)
+	return nil
+}
+
+// publish 事务提交后投递到内存队列
+func (e indexEvents) publish() {
+	for _, outbox := range e {
+		indexer.Publish(indexer.Event{
+			Type:     indexer.EventType(outbox.EventType),
+			ID:       outbox.TargetID,
+			OutboxID: outbox.ID,
+		})
+	}
+}
+
+// publishIndexEvent 不伴随数据变更的事件，如一致性检查发现的差异，写入outbox失败时不投递，等待下次检查
+func publishIndexEvent(eventType indexer.EventType, id string) error {
+	var events indexEvents
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		return events.add(tx, eventType, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	events.publish()
+	return nil
+}
+
+// completeOutbox 处理成功标记outbox记录为已处理，失败记录错误等待重投
+func completeOutbox(outboxID uint, handleErr error) {
+	if outboxID == 0 {
+		return
+	}
+
+	var err error
+	if handleErr == nil {
+		err = db.Run(
+			db.Model(&models.IndexOutbox{}),
+			db.Equal("id", outboxID),
+			db.Set(map[string]any{"processed_at": time.Now()}),
+		)
+	} else {
+		message := handleErr.Error()
+		if len(message) > maxOutboxErrorLength {
+			message = message[:maxOutboxErrorLength]
+		}
+		err = db.Run(
+			db.Model(&models.IndexOutbox{}),
+			db.Equal("id", outboxID),
+			db.Set(map[string]any{"last_error": message}),
+		)
+	}
+	if err != nil {
+		log.Printf("failed to update index outbox %d: %v", outboxID, err)
+	}
+}
+
+// RelayIndexOutbox 重投到期的outbox记录，重试间隔按次数指数增长
+func RelayIndexOutbox() error {
+	now := time.Now()
+	pending, err := db.GetAll[models.IndexOutbox](
+		db.WhereSQL("processed_at IS NULL AND next_run_at <= ?", now),
+		db.OrderBy("id", false),
+		db.Page(1, outboxBatchSize),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, outbox := range pending {
+		delay := outboxRetryDelay << min(outbox.Attempts, 7)
+		if delay > maxOutboxRetryDelay {
+			delay = maxOutboxRetryDelay
+		}
+
+		if err := db.Run(
+			db.Model(&models.IndexOutbox{}),
+			db.Equal("id", outbox.ID),
+			db.Set(map[string]any{
+				"attempts":    outbox.Attempts + 1,
+				"next_run_at": now.Add(delay),
+			}),
+		); err != nil {
+			return err
+		}
+
+		indexer.Publish(indexer.Event{
+			Type:     indexer.EventType(outbox.EventType),
+			ID:       outbox.TargetID,
+			OutboxID: outbox.ID,
+		})
+	}
+
+	return nil
+}
+
+// purgeIndexOutbox 删除超过保留时长的已处理事件
+func purgeIndexOutbox(retention time.Duration) error {
+	return db.DeleteByQuery[models.IndexOutbox](
+		db.WhereSQL("processed_at < ?", time.Now().Add(-retention)),
+	)
+}
+
+// LatestIndexOutboxID 当前最新的outbox记录id，重建索引开始时记录，用于之后重放
+func LatestIndexOutboxID() (uint, error) {
+	latest, err := db.GetOne[models.IndexOutbox](
+		db.Fields("id"),
+		db.OrderBy("id", true),
+	)
+	return latest.ID, err
+}
+
+// CheckIndexDrift 比对MySQL与es中的商品，返回发现差异并已提交修复的数量
+func CheckIndexDrift() (int, error) {
+	missing, err := checkStaleDocuments()
+	if err != nil {
+		return missing, err
+	}
+
+	orphans, err := checkOrphanDocuments()
+	return missing + orphans, err
+}
+
+// checkStaleDocuments 找出es中缺失或关键字段与MySQL不一致的商品
+func checkStaleDocuments() (int, error) {
+	repaired := 0
+	lastID := ""
+	for {
+		products, err := db.GetAll[models.Product](
+			db.GreaterThan("id", lastID),
+			db.OrderBy("id", false),
+			db.Page(1, driftBatchSize),
+		)
+		if err != nil {
+			return repaired, err
+		}
+		if len(products) == 0 {
+			return repaired, nil
+		}
+		lastID = products[len(products)-1].ID
+
+		ids := make([]string, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+
+		documents, err := es.MultiGet(es.ProductIndex, ids)
+		if err != nil {
+			return repaired, err
+		}
+
+		for _, product := range products {
+			source, ok := documents[product.ID]
+			if ok && !documentDrifted(product, source) {
+				continue
+			}
+
+			if err := publishIndexEvent(indexer.ProductUpserted, product.ID); err != nil {
+				return repaired, err
+			}
+			repaired++
+		}
+	}
+}
+
+func documentDrifted(product models.Product, source json.RawMessage) bool {
+	var document request.ProductDocument
+	if err := json.Unmarshal(source, &document); err != nil {
+		return true
+	}
+
+	return document.Describe != product.Describe ||
+		document.Price != product.Price ||
+		document.OriginalPrice != product.OriginalPrice ||
+		document.Pics != product.Pics ||
+		document.Condition != product.Condition ||
+		document.SellerID != product.UserID ||
+		document.IsPublished != product.IsPublished ||
+		document.IsSold != product.IsSold ||
+		document.IsSelling != product.IsSelling
+}
+
+// checkOrphanDocuments 找出MySQL中已不存在的es文档
+func checkOrphanDocuments() (int, error) {
+	removed := 0
+	var searchAfter []interface{}
+	for {
+		query := map[string]interface{}{
+			"size":    driftBatchSize,
+			"_source": []string{"id"},
+			"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+			"sort":    []map[string]interface{}{{"id": "asc"}},
+		}
+		if searchAfter != nil {
+			query["search_after"] = searchAfter
+		}
+
+		result, err := es.SearchWithAggregations(es.ProductIndex, query)
+		if err != nil {
+			return removed, err
+		}
+		if len(result.Hits) == 0 {
+			return removed, nil
+		}
+
+		ids := make([]string, 0, len(result.Hits))
+		for _, hit := range result.Hits {
+			var document struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(hit, &document); err != nil {
+				return removed, err
+			}
+			ids = append(ids, document.ID)
+		}
+		searchAfter = []interface{}{ids[len(ids)-1]}
+
+		products, err := db.GetAll[models.Product](
+			db.Fields("id"),
+			db.InArray("id", ids),
+		)
+		if err != nil {
+			return removed, err
+		}
+
+		exists := make(map[string]bool, len(products))
+		for _, product := range products {
+			exists[product.ID] = true
+		}
+		for _, id := range ids {
+			if !exists[id] {
+				if err := publishIndexEvent(indexer.ProductDeleted, id); err != nil {
+					return removed, err
+				}
+				removed++
+			}
+		}
+	}
+}
+
+// backfillEmptyIndices 索引为空而MySQL中有数据时全量写入，内嵌引擎不保存快照时每次启动都是空索引
+func backfillEmptyIndices() {
+	for _, target := range []struct {
+		index   string
+		reindex func(index string, afterID string, batchSize int, checkpoint func(string) error) (int, error)
+	}{
+		{es.ProductIndex, ReindexProducts},
+		{es.MessageIndex, ReindexMessages},
+	} {
+		result, err := es.SearchWithAggregations(target.index, map[string]interface{}{"size": 0})
+		if err != nil {
+			log.Printf("failed to check index %s: %v", target.index, err)
+			continue
+		}
+		if result.Total > 0 {
+			continue
+		}
+
+		total, err := target.reindex(target.index, "", backfillBatchSize, func(string) error { return nil })
+		if err != nil {
+			log.Printf("failed to backfill index %s: %v", target.index, err)
+			continue
+		}
+		if total > 0 {
+			log.Printf("backfilled %d documents into empty index %s", total, target.index)
+		}
+	}
+}
+
+// StartIndexSync 定时重投outbox并检查MySQL与es的一致性，启动时补齐空索引
+func StartIndexSync(ctx context.Context) {
+	config := app.GetConfig().Indexer
+
+	relayInterval := defaultRelayInterval
+	if config.RelayInterval > 0 {
+		relayInterval = time.Duration(config.RelayInterval) * time.Second
+	}
+	driftInterval := defaultDriftCheckInterval
+	if config.DriftCheckInterval > 0 {
+		driftInterval = time.Duration(config.DriftCheckInterval) * time.Second
+	}
+	retention := defaultOutboxRetention
+	if config.OutboxRetention > 0 {
+		retention = time.Duration(config.OutboxRetention) * time.Hour
+	}
+
+	go backfillEmptyIndices()
+
+	go runPeriodically(ctx, relayInterval, func() {
+		if err := RelayIndexOutbox(); err != nil {
+			log.Printf("failed to relay index outbox: %v", err)
+		}
+	})
+
+	go runPeriodically(ctx, driftInterval, func() {
+		repaired, err := CheckIndexDrift()
+		if err != nil {
+			log.Printf("index drift check failed: %v", err)
+		}
+		if repaired > 0 {
+			log.Printf("index drift check repaired %d products", repaired)
+		}
+
+		if err := purgeIndexOutbox(retention); err != nil {
+			log.Printf("failed to purge index outbox: %v", err)
+		}
+	})
+}
+
+func runPeriodically(ctx context.Context, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/mislu/market-api/internal/core/moderation"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+var (
+	errUserBlocked      = errors.New("user is blocked")
+	errCannotBlockSelf  = errors.New("can not block yourself")
+	errContentRejected  = errors.New("content rejected by moderation")
+	errReportNotFound   = errors.New("report not found")
+	errNotMessageTarget = errors.New("user is not the receiver of the message")
+)
+
+func BlockUser(req *request.BlockUserReq, userID string) exceptions.APIError {
+	if req.TargetUserID == userID {
+		return exceptions.BadRequestError(errCannotBlockSelf, exceptions.CannotBlockSelfError)
+	}
+
+	target, err := db.GetOne[models.User](
+		db.Fields("id"),
+		db.Equal("id", req.TargetUserID),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if !target.Exists() {
+		return exceptions.BadRequestError(errUserNotFound, exceptions.UserNotExistsError)
+	}
+
+	block := &models.UserBlock{
+		UserID:        userID,
+		BlockedUserID: req.TargetUserID,
+	}
+	if err := db.FirstOrCreate(block); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func UnblockUser(req *request.BlockUserReq, userID string) exceptions.APIError {
+	err := db.DeleteByCondition(models.UserBlock{
+		UserID:        userID,
+		BlockedUserID: req.TargetUserID,
+	})
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func GetBlockedUsers(userID string) (response.GetBlockedUsersResp, exceptions.APIError) {
+	blocks, err := db.GetAll[models.UserBlock](
+		db.Equal("user_id", userID),
+		db.OrderBy("created_at", true),
+	)
+	if err != nil {
+		return nil, exceptions.InternalServerError(err)
+	}
+
+	userIDs := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		userIDs = append(userIDs, block.BlockedUserID)
+	}
+
+	if len(userIDs) == 0 {
+		return response.GetBlockedUsersResp{}, nil
+	}
+
+	users, err := db.GetAll[models.User](
+		db.Fields("id", "username", "avatar"),
+		db.InArray("id", userIDs),
+	)
+	if err != nil {
+		return nil, exceptions.InternalServerError(err)
+	}
+
+	return response.GetBlockedUsersResp(users), nil
+}
+
+// isBlocked 任意一方拉黑另一方即视为无法通信
+func isBlocked(userID, peerID string) (bool, error) {
+	count, err := db.GetCount[models.UserBlock](
+		db.WhereSQL("(user_id = ? AND blocked_user_id = ?) OR (user_id = ? AND blocked_user_id = ?)",
+			userID, peerID, peerID, userID),
+	)
+	return count > 0, err
+}
+
+// moderateContent 对文本内容执行审核，返回可以落盘的内容
+func moderateContent(content string) (string, error) {
+	result := moderation.Check(content)
+	if result.Action == moderation.Reject {
+		return "", errContentRejected
+	}
+
+	return result.Content, nil
+}
+
+// ReportMessage 举报收到的消息，保存消息快照供管理员审核
+func ReportMessage(req *request.ReportMessageReq, userID string) exceptions.APIError {
+	message, err := db.GetOne[models.Message](
+		db.Equal("id", req.MessageID),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if !message.Exists() {
+		return exceptions.BadRequestError(errMessageNotFound, exceptions.MessageNotFoundError)
+	}
+
+	if message.ToUserID != userID {
+		return exceptions.BadRequestError(errNotMessageTarget, exceptions.NotConversationParticipantError)
+	}
+
+	report := &models.MessageReport{
+		MessageID:      message.ID,
+		ReporterID:     userID,
+		ReportedUserID: message.FromUserID,
+		Reason:         req.Reason,
+		Content:        message.Content,
+		Status:         models.ReportStatusPending,
+	}
+	if err := db.Create(report); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func GetMessageReports(req *request.GetMessageReportsReq) (response.GetMessageReportsResp, exceptions.APIError) {
+	var resp response.GetMessageReportsResp
+
+	var queries []db.GenericQuery
+	if len(req.Status) > 0 {
+		queries = append(queries, db.Equal("status", req.Status))
+	}
+
+	total, err := db.GetCount[models.MessageReport](queries...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	reports, err := db.GetAll[models.MessageReport](append(queries,
+		db.OrderBy("id", true),
+		db.Page(req.Page, req.Size),
+	)...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	resp.Reports = reports
+	resp.Total = total
+	resp.HasMore = int64(req.Page*req.Size) < total
+	resp.Page = req.Page
+	resp.Size = req.Size
+	return resp, nil
+}
+
+func ReviewMessageReport(req *request.ReviewMessageReportReq) exceptions.APIError {
+	report, err := db.GetOne[models.MessageReport](
+		db.Equal("id", req.ReportID),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if report.ID == 0 {
+		return exceptions.BadRequestError(errReportNotFound, exceptions.ReportNotFoundError)
+	}
+
+	report.Status = req.Status
+	report.Remark = req.Remark
+	if err := db.Update(&report); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/notify"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+const notificationTimeout = 10 * time.Second
+
+// defaultPreference 用户未设置时默认只接收站内通知
+func defaultPreference(userID, typ string) models.NotificationPreference {
+	return models.NotificationPreference{
+		UserID: userID,
+		Type:   typ,
+		InApp:  true,
+	}
+}
+
+// notifyUser 异步发送通知，失败只记录日志不影响业务流程
+func notifyUser(userID, typ, relatedID string, data map[string]any) {
+	go func() {
+		if err := sendNotification(userID, typ, relatedID, data); err != nil {
+			log.Printf("用户 %s 的 %s 通知发送失败: %v", userID, typ, err)
+		}
+	}()
+}
+
+// notifyOrderEvent 订单状态变化时通知对方
+func notifyOrderEvent(order models.Order, receiverID, typ string) {
+	go func() {
+		product, err := db.GetOne[models.Product](
+			db.Fields("id", "describe"),
+			db.Equal("id", order.ProductID),
+		)
+		if err != nil {
+			log.Printf("订单 %s 的 %s 通知发送失败: %v", order.ID, typ, err)
+			return
+		}
+
+		data := map[string]any{"product": product.Describe}
+		if err := sendNotification(receiverID, typ, order.ID, data); err != nil {
+			log.Printf("订单 %s 的 %s 通知发送失败: %v", order.ID, typ, err)
+		}
+	}()
+}
+
+// notifyProductEvent 商品被评价或收藏时通知卖家，data中补充操作人和商品信息
+func notifyProductEvent(productID, operatorID, receiverID, typ string, data map[string]any) {
+	go func() {
+		product, err := db.GetOne[models.Product](
+			db.Fields("id", "describe"),
+			db.Equal("id", productID),
+		)
+		if err != nil {
+			log.Printf("商品 %s 的 %s 通知发送失败: %v", productID, typ, err)
+			return
+		}
+
+		operator, err := db.GetOne[models.User](
+			db.Fields("id", "username"),
+			db.Equal("id", operatorID),
+		)
+		if err != nil {
+			log.Printf("商品 %s 的 %s 通知发送失败: %v", productID, typ, err)
+			return
+		}
+
+		if data == nil {
+			data = make(map[string]any)
+		}
+		data["product"] = product.Describe
+		data["username"] = operator.Username
+
+		if err := sendNotification(receiverID, typ, productID, data); err != nil {
+			log.Printf("商品 %s 的 %s 通知发送失败: %v", productID, typ, err)
+		}
+	}()
+}
+
+// notifyPriceDrop 商品降价时通知收藏了该商品的用户
+func notifyPriceDrop(product models.Product) {
+	go func() {
+		likes, err := db.GetAll[models.Like](
+			db.Equal("product_id", product.ID),
+		)
+		if err != nil {
+			log.Printf("商品 %s 的降价通知发送失败: %v", product.ID, err)
+			return
+		}
+
+		data := map[string]any{"product": product.Describe, "price": product.Price}
+		for _, like := range likes {
+			if err := sendNotification(like.UserID, notify.TypeOffer, product.ID, data); err != nil {
+				log.Printf("用户 %s 的降价通知发送失败: %v", like.UserID, err)
+			}
+		}
+	}()
+}
+
+func sendNotification(userID, typ, relatedID string, data map[string]any) error {
+	preference, err := db.GetOne[models.NotificationPreference](
+		db.Equal("user_id", userID),
+		db.Equal("type", typ),
+	)
+	if err != nil {
+		return err
+	}
+
+	if !preference.Exists() {
+		preference = defaultPreference(userID, typ)
+	}
+
+	if !preference.InApp && !preference.Push {
+		return nil
+	}
+
+	title, content, err := notify.Render(typ, data)
+	if err != nil {
+		return err
+	}
+
+	if preference.InApp {
+		notification := models.Notification{
+			UserID:    userID,
+			Type:      typ,
+			Title:     title,
+			Content:   content,
+			RelatedID: relatedID,
+			CreatedAt: time.Now(),
+		}
+		if err := db.Create(&notification); err != nil {
+			return err
+		}
+
+		notify.PushRealtime(userID, notification)
+	}
+
+	if preference.Push {
+		user, err := db.GetOne[models.User](
+			db.Fields("id", "phone"),
+			db.Equal("id", userID),
+		)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+		notify.Dispatch(ctx, notify.Outbound{
+			UserID:  userID,
+			Phone:   user.Phone,
+			Type:    typ,
+			Title:   title,
+			Content: content,
+		})
+	}
+
+	return nil
+}
+
+func GetNotifications(req *request.GetNotificationsReq, userID string) (response.GetNotificationsResp, exceptions.APIError) {
+	var resp response.GetNotificationsResp
+
+	queries := []db.GenericQuery{
+		db.Equal("user_id", userID),
+	}
+	if req.UnreadOnly {
+		queries = append(queries, db.Equal("is_read", false))
+	}
+	if len(req.Type) > 0 {
+		queries = append(queries, db.Equal("type", req.Type))
+	}
+
+	total, err := db.GetCount[models.Notification](queries...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	notifications, err := db.GetAll[models.Notification](append(queries,
+		db.OrderBy("id", true),
+		db.Page(req.Page, req.Size),
+	)...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	resp.Notifications = notifications
+	resp.Total = total
+	resp.Page = req.Page
+	resp.Size = req.Size
+	resp.HasMore = int64(req.Page*req.Size) < total
+	return resp, nil
+}
+
+func GetUnreadNotificationCount(userID string) (response.GetUnreadNotificationCountResp, exceptions.APIError) {
+	resp := response.GetUnreadNotificationCountResp{
+		Types: make(map[string]int64),
+	}
+
+	counts, err := db.GetAny[[]struct {
+		Type  string
+		Count int64
+	}]("SELECT type, COUNT(*) AS count FROM notification WHERE user_id = ? AND is_read = false GROUP BY type", userID)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	for _, count := range counts {
+		resp.Types[count.Type] = count.Count
+		resp.Total += count.Count
+	}
+
+	return resp, nil
+}
+
+// MarkNotificationsRead 未指定id时将所有通知标记为已读
+func MarkNotificationsRead(req *request.MarkNotificationsReadReq, userID string) exceptions.APIError {
+	queries := []db.GenericQuery{
+		db.Model(&models.Notification{}),
+		db.Equal("user_id", userID),
+		db.Equal("is_read", false),
+	}
+	if len(req.IDs) > 0 {
+		queries = append(queries, db.InArray("id", req.IDs))
+	}
+
+	if err := db.Run(append(queries, db.Set(map[string]any{"is_read": true}))...); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func GetNotificationPreferences(userID string) (response.GetNotificationPreferencesResp, exceptions.APIError) {
+	preferences, err := db.GetAll[models.NotificationPreference](
+		db.Equal("user_id", userID),
+	)
+	if err != nil {
+		return nil, exceptions.InternalServerError(err)
+	}
+
+	saved := make(map[string]models.NotificationPreference, len(preferences))
+	for _, preference := range preferences {
+		saved[preference.Type] = preference
+	}
+
+	resp := make(response.GetNotificationPreferencesResp, 0, len(notify.Types))
+	for _, typ := range notify.Types {
+		preference, ok := saved[typ]
+		if !ok {
+			preference = defaultPreference(userID, typ)
+		}
+		resp = append(resp, preference)
+	}
+
+	return resp, nil
+}
+
+func UpdateNotificationPreference(req *request.UpdateNotificationPreferenceReq, userID string) exceptions.APIError {
+	// 按notify.Types校验，新增通知类型后无需同步修改请求的校验规则
+	if !slices.Contains(notify.Types, req.Type) {
+		return exceptions.BadRequestError(notify.ErrUnknownType, exceptions.UnknownNotificationTypeError)
+	}
+
+	preference := models.NotificationPreference{
+		UserID: userID,
+		Type:   req.Type,
+		InApp:  *req.InApp,
+		Push:   *req.Push,
+	}
+
+	if err := db.Update(&preference); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
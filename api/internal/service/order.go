@@ -8,14 +8,18 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/mislu/market-api/internal/core/indexer"
 	"github.com/mislu/market-api/internal/core/mq/rabbit"
+	"github.com/mislu/market-api/internal/core/notify"
 	"github.com/mislu/market-api/internal/core/payment"
 	"github.com/mislu/market-api/internal/core/payment/types"
+	"github.com/mislu/market-api/internal/core/recommend"
 	"github.com/mislu/market-api/internal/db"
 	"github.com/mislu/market-api/internal/types/exceptions"
 	"github.com/mislu/market-api/internal/types/models"
 	"github.com/mislu/market-api/internal/types/request"
 	"github.com/mislu/market-api/internal/types/response"
+	"gorm.io/gorm"
 )
 
 const (
@@ -94,9 +98,11 @@ func PurchaseProduct(req *request.PurchaseProductReq) (response.PurchaseProductR
 		return resp, exceptions.InternalServerError(err)
 	}
 	product.IsSold = true
-	if err := db.Update(product); err != nil {
+	if err := updateProduct(product); err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
+
+	notifyUser(order.SellerID, notify.TypeOrderCreated, order.ID, map[string]any{"product": product.Describe})
 	resp.OrderID = order.ID
 	return resp, nil
 }
@@ -242,7 +248,7 @@ func ConfirmOrderSigned(req *request.ConfirmOrderReq) exceptions.APIError {
 			return exceptions.InternalServerError(err)
 		}
 
-		err = db.Update(product)
+		err = updateProduct(&product)
 		if err != nil {
 			return exceptions.InternalServerError(err)
 		}
@@ -252,6 +258,10 @@ func ConfirmOrderSigned(req *request.ConfirmOrderReq) exceptions.APIError {
 		return exceptions.InternalServerError(err)
 	}
 
+	if order.Status == orderStatusDone {
+		notifyOrderEvent(*order, order.SellerID, notify.TypeOrderDone)
+	}
+
 	return nil
 }
 
@@ -296,6 +306,10 @@ func ConfirmOrderShipped(req *request.ConfirmOrderReq) exceptions.APIError {
 		return exceptions.InternalServerError(err)
 	}
 
+	if order.Status == orderStatusShipped {
+		notifyOrderEvent(order, order.UserID, notify.TypeOrderShipped)
+	}
+
 	return nil
 }
 
@@ -457,11 +471,6 @@ func CancelOrder(req *request.CancelOrderReq) exceptions.APIError {
 	order.Status = orderStatusCancelled
 	order.FinishTime = time.Now()
 
-	// TODO 事务封装
-	if err := db.Update(order); err != nil {
-		return exceptions.InternalServerError(err)
-	}
-
 	product, err := db.GetOne[models.Product](
 		db.Equal("id", order.ProductID),
 	)
@@ -471,10 +480,21 @@ func CancelOrder(req *request.CancelOrderReq) exceptions.APIError {
 	}
 
 	product.IsSold = false
-	if err := db.Update(product); err != nil {
+	var events indexEvents
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Update(order, tx); err != nil {
+			return err
+		}
+		if err := db.Update(&product, tx); err != nil {
+			return err
+		}
+		return events.add(tx, indexer.ProductUpserted, product.ID)
+	})
+	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
+	events.publish()
 	return nil
 }
 
@@ -484,13 +504,13 @@ func AlipayNotify(values url.Values) exceptions.APIError {
 		return exceptions.InternalServerError(err)
 	}
 
-	notify, err := paymentService.VerifyNotify(values)
+	notifyResult, err := paymentService.VerifyNotify(values)
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
 	order, err := db.GetOne[models.Order](
-		db.Equal("id", notify.OutTradeNo),
+		db.Equal("id", notifyResult.OutTradeNo),
 	)
 
 	if err != nil {
@@ -501,10 +521,8 @@ func AlipayNotify(values url.Values) exceptions.APIError {
 		return exceptions.BadRequestError(errors.New("order not found"), exceptions.OrderNotFoundError)
 	}
 
-	if notify.TradeStatus == "TRADE_SUCCESS" {
-		order.Status = orderStatusPaid
-		order.PayTime = time.Now()
-		if err := db.Update(order); err != nil {
+	if notifyResult.TradeStatus == "TRADE_SUCCESS" {
+		if err := markOrderPaid(&order); err != nil {
 			return exceptions.InternalServerError(err)
 		}
 	}
@@ -512,6 +530,34 @@ func AlipayNotify(values url.Values) exceptions.APIError {
 	return nil
 }
 
+// markOrderPaid 只把待支付的订单改为已支付，支付宝回调和查询订单状态可能同时发生，只有完成变更的一方通知卖家，
+// 购买反馈也在此时记录，未支付的订单不算购买
+func markOrderPaid(order *models.Order) error {
+	payTime := time.Now()
+	affected, err := db.RunAffected(
+		db.Model(&models.Order{}),
+		db.Equal("id", order.ID),
+		db.Equal("status", orderStatusPending),
+		db.Set(map[string]any{"status": orderStatusPaid, "pay_time": payTime}),
+	)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	order.Status = orderStatusPaid
+	order.PayTime = payTime
+	recordFeedback(models.Feedback{
+		UserID:       order.UserID,
+		ItemID:       order.ProductID,
+		FeedbackType: recommend.FeedbackPurchase,
+	}, order.SellerID)
+	notifyOrderEvent(*order, order.SellerID, notify.TypeOrderPaid)
+	return nil
+}
+
 func GetUnCommentOrder(req *request.GetUncommentOrder) (response.GetUnCommentOrderResp, exceptions.APIError) {
 	var resp response.GetUnCommentOrderResp
 
@@ -603,12 +649,11 @@ func GetOrderStatus(req *request.GetOrderStatusReq) (response.GetOrderStatusResp
 			retryCount++
 			continue
 		case types.TradeStatusSuccess:
-			order.Status = orderStatusPaid
-			order.PayTime = time.Now()
-			if err := db.Update(&order); err != nil {
+			if err := markOrderPaid(&order); err != nil {
 				return response.GetOrderStatusResp{Status: orderStatusPaid}, exceptions.InternalServerError(err)
 			}
-			return response.GetOrderStatusResp{Status: orderStatusPaid}, exceptions.InternalServerError(err)
+
+			return response.GetOrderStatusResp{Status: orderStatusPaid}, nil
 		default:
 			log.Printf("Order %s: Unexpected trade status: %s", order.ID, tradeResp.TradeStatus)
 		}
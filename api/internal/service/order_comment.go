@@ -4,6 +4,8 @@ import (
 	"errors"
 	"time"
 
+	"github.com/mislu/market-api/internal/core/indexer"
+	"github.com/mislu/market-api/internal/core/notify"
 	"github.com/mislu/market-api/internal/db"
 	"github.com/mislu/market-api/internal/types/exceptions"
 	"github.com/mislu/market-api/internal/types/models"
@@ -32,6 +34,7 @@ func CreateOrderComment(req *request.CreateOrderCommentReq, userID string) excep
 	if order.FinishTime.Before(time.Now().AddDate(0, -1, 0)) {
 		return exceptions.BadRequestError(errors.New("order older than 30 days"), exceptions.OrderOlderThan30DaysError)
 	}
+	var events indexEvents
 	err = db.WithTransaction(func(tx *gorm.DB) error {
 		// 创建评论
 		comment := models.OrderComment{
@@ -45,17 +48,18 @@ func CreateOrderComment(req *request.CreateOrderCommentReq, userID string) excep
 			IsTop:     true,
 		}
 
-		if err := db.Create(&comment); err != nil {
+		if err := db.Create(&comment, tx); err != nil {
 			return err
 		}
 
 		order.IsEvaluated = true
-		err = db.Update(&order)
+		err = db.Update(&order, tx)
 		if err != nil {
 			return err
 		}
 
 		credit, err := db.GetOne[models.Credit](
+			db.WithTransactionContext(tx),
 			db.Equal("user_id", order.SellerID),
 		)
 		if err != nil {
@@ -71,7 +75,7 @@ func CreateOrderComment(req *request.CreateOrderCommentReq, userID string) excep
 			}
 
 			models.CalculateReputation(&credit)
-			if err = db.Update(&credit); err != nil {
+			if err = db.Update(&credit, tx); err != nil {
 				return err
 			}
 		} else {
@@ -84,16 +88,18 @@ func CreateOrderComment(req *request.CreateOrderCommentReq, userID string) excep
 			}
 
 			models.CalculateReputation(&credit)
-			if err = db.Create(&credit); err != nil {
+			if err = db.Create(&credit, tx); err != nil {
 				return err
 			}
 		}
-		return nil
+		return events.add(tx, indexer.SellerUpdated, order.SellerID)
 	})
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
+	events.publish()
+	notifyProductEvent(order.ProductID, userID, order.SellerID, notify.TypeComment, map[string]any{"comment": req.Comment})
 	return nil
 }
 
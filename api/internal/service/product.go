@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mislu/market-api/internal/core/indexer"
+	"github.com/mislu/market-api/internal/core/notify"
 	"github.com/mislu/market-api/internal/core/recommend"
 	resourcemanager "github.com/mislu/market-api/internal/core/resource_manager"
 	"github.com/mislu/market-api/internal/db"
@@ -20,6 +22,16 @@ import (
 	"gorm.io/gorm"
 )
 
+const conditionUsed = "used"
+
+// productConditions 新旧程度代码 -> 商品中保存的描述
+var productConditions = map[string]string{
+	"new":         "全新",
+	"good":        "八成新",
+	"excellent":   "九成新",
+	conditionUsed: "使用过",
+}
+
 var (
 	errProductNotFound = errors.New("product not found")
 	errProductSold     = errors.New("product sold")
@@ -64,15 +76,10 @@ func CreateProduct(req *request.CreateProductReq) (response.CreateProductResp, e
 		PublishAt:      time.Now(),
 	}
 
-	switch req.Condition {
-	case "new":
-		product.Condition = "全新"
-	case "good":
-		product.Condition = "八成新"
-	case "excellent":
-		product.Condition = "九成新"
-	default:
-		product.Condition = "使用过"
+	if condition, ok := productConditions[req.Condition]; ok && req.Condition != conditionUsed {
+		product.Condition = condition
+	} else {
+		product.Condition = productConditions[conditionUsed]
 		product.UsedTime = req.UsedTime
 	}
 
@@ -91,8 +98,8 @@ func CreateProduct(req *request.CreateProductReq) (response.CreateProductResp, e
 	}
 
 	product.Pics = strings.Join(pics, ",")
-	attributesList := make([]string, 0, len(attributes))
-	// 数据库创建商品，分类
+	// 数据库创建商品，分类，es索引、保存的搜索匹配和推荐同步由事件完成
+	var events indexEvents
 	err = db.WithTransaction(func(tx *gorm.DB) error {
 		if err := db.Create(product, tx); err != nil {
 			return err
@@ -105,7 +112,6 @@ func CreateProduct(req *request.CreateProductReq) (response.CreateProductResp, e
 				AttributeID: id,
 				Value:       value,
 			})
-			attributesList = append(attributesList, value)
 		}
 
 		productCategories := make([]models.ProductCategory, 0, len(req.Categories))
@@ -120,66 +126,20 @@ func CreateProduct(req *request.CreateProductReq) (response.CreateProductResp, e
 			return err
 		}
 
-		return db.Create(productAttributes, tx)
-	})
-
-	if err != nil {
-		deletePics(pics)
-
-		return resp, exceptions.InternalServerError(err)
-	}
-
-	productDocument := &request.ProductDocument{
-		ID:        product.ID,
-		Describe:  product.Describe,
-		CreatedAt: product.CreatedAt,
-		Price:     product.Price,
-	}
-
-	productCategories, err := db.GetAll[models.Category](
-		db.InArray("id", req.Categories),
-	)
-
-	categoriesString := make([]string, 0, len(productCategories))
-	for _, category := range productCategories {
-		categoriesString = append(categoriesString, category.TypeName)
-	}
-
-	if err != nil {
-		deletePics(pics)
-
-		return resp, exceptions.InternalServerError(err)
-	}
-
-	productDocument.Category = categoriesString
-	for id, value := range attributes {
-		attribute, err := db.GetOne[models.AttributeTemplate](
-			db.Equal("id", id),
-		)
-
-		if err != nil {
-			deletePics(pics)
-
-			return resp, exceptions.InternalServerError(err)
+		if err := db.Create(productAttributes, tx); err != nil {
+			return err
 		}
 
-		productDocument.Attributes = append(productDocument.Attributes, request.AttributeES{
-			Key:   attribute.Name,
-			Value: value,
-		})
-	}
-	// 写入es
-	err = es.IndexDocument("m-market", productDocument.ID, productDocument)
+		return events.add(tx, indexer.ProductListed, product.ID)
+	})
+
 	if err != nil {
 		deletePics(pics)
 
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	err = recommend.CreateItem(*product, categoriesString, attributesList, true)
-	if err != nil {
-		return resp, exceptions.InternalServerError(err)
-	}
+	events.publish()
 	return resp, nil
 }
 
@@ -213,6 +173,7 @@ func GetProduct(req *request.GetProductReq) (response.GetProductResp, exceptions
 
 	resp.User = user
 	resp.Product = product
+	recordProductView(product.ID)
 
 	address, err := getProductAddress(product.Location)
 	if err != nil {
@@ -238,11 +199,17 @@ func GetProduct(req *request.GetProductReq) (response.GetProductResp, exceptions
 	}
 
 	attributes := make(map[uint]string)
+	attributeValues := make([]string, 0, len(productAttributes))
 	for _, productAttribute := range productAttributes {
 		attributes[productAttribute.AttributeID] = productAttribute.Value
+		attributeValues = append(attributeValues, productAttribute.Value)
 	}
 	resp.Attributes = attributes
 
+	if req.Related {
+		fillRelatedProducts(&resp, product.ID, product.UserID, attributeValues)
+	}
+
 	credit, err := getUserCredit(product.UserID)
 	if err != nil {
 		return response.GetProductResp{}, exceptions.InternalServerError(err)
@@ -327,6 +294,7 @@ func UpdateProduct(req *request.UpdateProductReq) (response.CreateProductResp, e
 	product.OriginalPrice = req.OriginalPrice
 	product.Location = req.AddressID
 
+	var events indexEvents
 	err = db.WithTransaction(func(tx *gorm.DB) error {
 		if err := db.Update(product, tx); err != nil {
 			return err
@@ -355,12 +323,17 @@ func UpdateProduct(req *request.UpdateProductReq) (response.CreateProductResp, e
 			}
 		}
 
-		return db.FirstOrCreate(&productAttributes, tx)
+		if err := db.FirstOrCreate(&productAttributes, tx); err != nil {
+			return err
+		}
+
+		return events.add(tx, indexer.ProductUpserted, product.ID)
 	})
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
+	events.publish()
 	return resp, nil
 }
 
@@ -430,7 +403,7 @@ func UpdateProductSellingStatus(userID, productID string, status bool) exception
 
 	product.IsSelling = status
 
-	err = db.Update(product)
+	err = updateProduct(product)
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
@@ -460,7 +433,7 @@ func UpdateProductSoldStatus(userID, productID string, status bool) exceptions.A
 	// 上架已下架的商品
 	product.IsSelling = true
 
-	err = db.Update(product)
+	err = updateProduct(product)
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
@@ -471,118 +444,35 @@ func UpdateProductSoldStatus(userID, productID string, status bool) exceptions.A
 func GetUserProducts(req *request.GetUserProductsReq) (response.GetUserProductsResp, exceptions.APIError) {
 	var resp response.GetUserProductsResp
 
-	user, err := db.GetOne[models.User](
-		db.Fields("id", "username", "avatar"),
-		db.Equal("id", req.UserID),
-	)
-
-	if err != nil {
-		return resp, exceptions.InternalServerError(err)
+	query := map[string]interface{}{
+		"from": (req.Page - 1) * req.Size,
+		"size": req.Size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{"seller_id": req.UserID}},
+					{"term": map[string]interface{}{"is_published": true}},
+				},
+			},
+		},
+		"sort": []map[string]interface{}{
+			{"publish_at": map[string]interface{}{"order": "desc"}},
+		},
 	}
 
-	if !user.Exists() {
-		return resp, exceptions.BadRequestError(errUserNotFound, exceptions.UserNotExistsError)
-	}
-
-	credit, err := db.GetOne[models.Credit](
-		db.Equal("user_id", req.UserID),
-	)
+	result, err := es.SearchWithAggregations(es.ProductIndex, query)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	products, err := db.GetAll[models.Product](
-		db.OrderBy("publish_at", true),
-		db.Equal("user_id", req.UserID),
-		db.Equal("is_published", true),
-		db.Page(req.Page, req.Size),
-	)
-
+	products, err := decodeProductDocuments(result.Hits)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	userProducts := make([]response.UserProduct, 0, len(products))
-	for _, product := range products {
-		address, err := getProductAddress(product.Location)
-		if err != nil {
-			continue
-		}
-
-		userProducts = append(userProducts, response.UserProduct{
-			User:    user,
-			Product: product,
-			Credit:  credit,
-			Address: address.Address,
-		})
-	}
-
-	resp.Products = userProducts
-	resp.Page = req.Page
-	resp.Size = req.Size
-
-	return resp, nil
-}
-
-func GetProductList(req *request.GetProductListReq, userID string) (response.GetProductListResp, exceptions.APIError) {
-	var resp response.GetProductListResp
-	recommendations, err := recommend.GetRecommendations(userID, 100)
-	if err != nil {
-		return resp, exceptions.InternalServerError(err)
-	}
-
-	var products []models.Product
-	if len(recommendations) == 0 {
-		products, err = db.GetAll[models.Product](
-			db.Equal("is_published", true),
-			db.Page(req.Page, req.Size),
-			db.OrderBy("created_at", true),
-			db.Equal("is_selling", true),
-			db.Equal("is_sold", false),
-		)
-	} else {
-		products, err = db.GetAll[models.Product](
-			db.InArray("id", recommendations),
-		)
-	}
-
-	if err != nil {
-		return resp, exceptions.InternalServerError(err)
-	}
-
-	for _, product := range products {
-		user, err := db.GetOne[models.User](
-			db.Equal("id", product.UserID),
-		)
-
-		if err != nil {
-			return resp, exceptions.InternalServerError(err)
-		}
-
-		if !user.Exists() {
-			continue
-		}
-
-		credit, err := db.GetOne[models.Credit](
-			db.Equal("user_id", product.UserID),
-		)
-		if err != nil {
-			continue
-		}
-
-		address, err := getProductAddress(product.Location)
-		if err != nil {
-			continue
-		}
-
-		resp.Products = append(resp.Products, response.UserProduct{
-			User:    user,
-			Product: product,
-			Credit:  credit,
-			Address: address.Address,
-		})
-	}
-
+	resp.Products = products
+	resp.Total = result.Total
+	resp.HasMore = int64(req.Page*req.Size) < result.Total
 	resp.Page = req.Page
 	resp.Size = req.Size
 
@@ -662,12 +552,30 @@ func UpdateProductPrice(req *request.UpdateProductPriceReq) exceptions.APIError
 		return exceptions.BadRequestError(errProductNotFound, exceptions.ProductNotFoundError)
 	}
 
+	priceDropped := req.Price < product.Price
 	product.Price = req.Price
-	err = db.Update(&product)
+
+	eventType := indexer.ProductUpserted
+	if priceDropped {
+		eventType = indexer.ProductPriceDropped
+	}
+
+	var events indexEvents
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Update(&product, tx); err != nil {
+			return err
+		}
+		return events.add(tx, eventType, product.ID)
+	})
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
+	events.publish()
+	if priceDropped {
+		notifyPriceDrop(product)
+	}
+
 	return nil
 }
 
@@ -701,12 +609,28 @@ func LikeProduct(req *request.LikeProductReq) exceptions.APIError {
 		ProductID: product.ID,
 	}
 
-	err = db.Create(&like)
+	var events indexEvents
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Create(&like, tx); err != nil {
+			return err
+		}
+		return events.add(tx, indexer.ProductUpserted, product.ID)
+	})
 
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
+	events.publish()
+	recordFeedback(models.Feedback{
+		UserID:       user.ID,
+		ItemID:       product.ID,
+		FeedbackType: recommend.FeedbackLike,
+	}, product.UserID)
+	if !product.IsOwner(user.ID) {
+		notifyProductEvent(product.ID, user.ID, product.UserID, notify.TypeLike, nil)
+	}
+
 	return nil
 }
 
@@ -720,12 +644,24 @@ func DislikeProduct(req *request.DislikeProductReq) exceptions.APIError {
 		return exceptions.InternalServerError(err)
 	}
 
-	err = db.Delete(&like)
+	var events indexEvents
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Delete(&like, tx); err != nil {
+			return err
+		}
+		return events.add(tx, indexer.ProductUpserted, req.ProductID)
+	})
 
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
+	events.publish()
+	recordFeedback(models.Feedback{
+		UserID:       req.UserID,
+		ItemID:       req.ProductID,
+		FeedbackType: recommend.FeedbackUnlike,
+	}, "")
 	return nil
 }
 
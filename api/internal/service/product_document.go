@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/indexer"
+	"github.com/mislu/market-api/internal/core/recommend"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+	"gorm.io/gorm"
+)
+
+// buildProductDocument 组装商品在es中的文档，包含分类、属性和卖家信誉等过滤字段
+func buildProductDocument(product models.Product) (request.ProductDocument, error) {
+	document := request.ProductDocument{
+		ID:             product.ID,
+		Describe:       product.Describe,
+		CreatedAt:      product.CreatedAt,
+		Price:          product.Price,
+		OriginalPrice:  product.OriginalPrice,
+		Pics:           product.Pics,
+		Condition:      product.Condition,
+		UsedTime:       product.UsedTime,
+		ShippingMethod: product.ShippingMethod,
+		ShippingPrice:  product.ShippingPrise,
+		CanSelfPickup:  product.CanSelfPickup,
+		PublishAt:      product.PublishAt,
+		IsPublished:    product.IsPublished,
+		IsSold:         product.IsSold,
+		IsSelling:      product.IsSelling,
+		SellerID:       product.UserID,
+		Location:       product.Location,
+		ViewCount:      product.ViewCount,
+	}
+
+	productCategories, err := db.GetAll[models.ProductCategory](
+		db.Equal("product_id", product.ID),
+	)
+	if err != nil {
+		return document, err
+	}
+
+	categoryIDs := make([]uint, 0, len(productCategories))
+	for _, productCategory := range productCategories {
+		categoryIDs = append(categoryIDs, productCategory.CategoryID)
+	}
+
+	if len(categoryIDs) > 0 {
+		categories, err := loadCategories()
+		if err != nil {
+			return document, err
+		}
+
+		inPath := make(map[uint]bool)
+		for _, id := range categoryIDs {
+			category, ok := categories[id]
+			if !ok {
+				continue
+			}
+
+			document.Category = append(document.Category, category.TypeName)
+			document.CategoryIDs = append(document.CategoryIDs, category.ID)
+			for _, ancestorID := range categoryAncestors(categories, id) {
+				if !inPath[ancestorID] {
+					inPath[ancestorID] = true
+					document.CategoryPath = append(document.CategoryPath, ancestorID)
+				}
+			}
+		}
+	}
+
+	address, err := getProductAddress(product.Location)
+	if err != nil {
+		return document, err
+	}
+
+	document.Address = address.Address
+	document.Province = address.Province
+	document.City = address.City
+	document.District = address.District
+	if address.Latitude != 0 || address.Longitude != 0 {
+		document.Geo = &request.GeoPoint{
+			Lat: address.Latitude,
+			Lon: address.Longitude,
+		}
+	}
+
+	document.LikeCount, err = db.GetCount[models.Like](
+		db.Equal("product_id", product.ID),
+	)
+	if err != nil {
+		return document, err
+	}
+
+	productAttributes, err := db.GetAll[models.ProductAttribute](
+		db.Equal("product_id", product.ID),
+	)
+	if err != nil {
+		return document, err
+	}
+
+	attributeIDs := make([]uint, 0, len(productAttributes))
+	for _, productAttribute := range productAttributes {
+		attributeIDs = append(attributeIDs, productAttribute.AttributeID)
+	}
+
+	if len(attributeIDs) > 0 {
+		templates, err := db.GetAll[models.AttributeTemplate](
+			db.InArray("id", attributeIDs),
+		)
+		if err != nil {
+			return document, err
+		}
+
+		templateMap := make(map[uint]models.AttributeTemplate, len(templates))
+		for _, template := range templates {
+			templateMap[template.ID] = template
+		}
+
+		for _, productAttribute := range productAttributes {
+			template, ok := templateMap[productAttribute.AttributeID]
+			if !ok {
+				continue
+			}
+
+			document.Attributes = append(document.Attributes, newAttributeES(template, productAttribute.Value))
+		}
+	}
+
+	seller, reputation, err := loadSellerSummary(product.UserID)
+	if err != nil {
+		return document, err
+	}
+
+	document.Seller = seller
+	document.SellerReputation = reputation
+
+	if product.IsPublished && product.IsSelling && !product.IsSold {
+		document.Suggest = append([]string{product.Describe}, document.Category...)
+	}
+	return document, nil
+}
+
+func loadSellerSummary(userID string) (request.SellerES, float64, error) {
+	user, err := db.GetOne[models.User](
+		db.Fields("id", "username", "avatar"),
+		db.Equal("id", userID),
+	)
+	if err != nil {
+		return request.SellerES{}, 0, err
+	}
+
+	credit, err := db.GetOne[models.Credit](
+		db.Equal("user_id", userID),
+	)
+	if err != nil {
+		return request.SellerES{}, 0, err
+	}
+
+	seller := request.SellerES{
+		ID:              userID,
+		Username:        user.Username,
+		Avatar:          user.Avatar,
+		TotalComment:    credit.TotalComment,
+		PositiveComment: credit.PositiveComment,
+		NegativeComment: credit.NegativeComment,
+	}
+	return seller, credit.Reputation, nil
+}
+
+// newAttributeES 按属性类型解析出可用于范围查询的值，解析失败时只保留原始值
+func newAttributeES(template models.AttributeTemplate, value string) request.AttributeES {
+	attribute := request.AttributeES{
+		AttributeID: template.ID,
+		Key:         template.Name,
+		Value:       value,
+	}
+
+	switch template.DataType {
+	case models.DataTypeNumber:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			attribute.Number = &number
+		}
+	case models.DataTypeDate:
+		if date, err := parseAttributeDate(value); err == nil {
+			attribute.Date = &date
+		}
+	case models.DataTypeBoolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			attribute.Bool = &b
+		}
+	}
+
+	return attribute
+}
+
+func parseAttributeDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// HandleIndexEvent 处理商品变更事件，从MySQL读取最新数据写入es
+func HandleIndexEvent(_ context.Context, event indexer.Event) error {
+	var err error
+	switch event.Type {
+	case indexer.ProductUpserted:
+		err = reindexProduct(event.ID)
+	case indexer.ProductListed:
+		if err = reindexProduct(event.ID); err == nil {
+			err = matchSavedSearches(event.ID, models.SearchAlertNew)
+		}
+	case indexer.ProductPriceDropped:
+		if err = reindexProduct(event.ID); err == nil {
+			err = matchSavedSearches(event.ID, models.SearchAlertPriceDrop)
+		}
+	case indexer.ProductDeleted:
+		if err = es.DeleteDocument(es.ProductIndex, event.ID); err == nil {
+			removeRecommendItem(event.ID)
+		}
+	case indexer.SellerUpdated:
+		err = refreshSellerDocuments(es.ProductIndex, event.ID)
+	default:
+		err = fmt.Errorf("unknown index event type %s", event.Type)
+	}
+
+	completeOutbox(event.OutboxID, err)
+	return err
+}
+
+func reindexProduct(productID string) error {
+	product, err := db.GetOne[models.Product](
+		db.Equal("id", productID),
+	)
+	if err != nil {
+		return err
+	}
+
+	if !product.Exists() {
+		if err := es.DeleteDocument(es.ProductIndex, productID); err != nil {
+			return err
+		}
+		removeRecommendItem(productID)
+		return nil
+	}
+
+	document, err := buildProductDocument(product)
+	if err != nil {
+		return err
+	}
+
+	if err := es.IndexDocument(es.ProductIndex, document.ID, document); err != nil {
+		return err
+	}
+	syncRecommendItem(product, document)
+
+	return nil
+}
+
+// syncRecommendItem 通过推荐队列异步同步商品，已售出、下架或未发布的商品隐藏；
+// 推荐服务不影响搜索，失败时由推荐队列退避重试，仍失败的由 cmd/recommendsync 回填修正
+func syncRecommendItem(product models.Product, document request.ProductDocument) {
+	recommend.GlobalWorker.SyncItem(product, document.Category, recommendItemLabels(document), !isRecommendable(product))
+}
+
+func removeRecommendItem(productID string) {
+	recommend.GlobalWorker.RemoveItem(productID)
+}
+
+func isRecommendable(product models.Product) bool {
+	return product.IsPublished && product.IsSelling && !product.IsSold
+}
+
+// recommendItemLabels 商品的属性值作为推荐服务的物品标签
+func recommendItemLabels(document request.ProductDocument) []string {
+	labels := make([]string, 0, len(document.Attributes))
+	for _, attribute := range document.Attributes {
+		if len(attribute.Value) > 0 {
+			labels = append(labels, attribute.Value)
+		}
+	}
+	return labels
+}
+
+// refreshSellerDocuments 卖家信息变化后同步到其所有商品文档
+func refreshSellerDocuments(index string, sellerID string) error {
+	seller, reputation, err := loadSellerSummary(sellerID)
+	if err != nil {
+		return err
+	}
+
+	return es.UpdateByQuery(index,
+		map[string]interface{}{
+			"term": map[string]interface{}{
+				"seller_id": sellerID,
+			},
+		},
+		map[string]interface{}{
+			"seller":            seller,
+			"seller_reputation": reputation,
+		},
+	)
+}
+
+// updateProduct 保存商品并在同一事务中写入重建索引的事件
+func updateProduct(product *models.Product) error {
+	var events indexEvents
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Update(product, tx); err != nil {
+			return err
+		}
+		return events.add(tx, indexer.ProductUpserted, product.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	events.publish()
+	return nil
+}
+
+// updateSeller 保存用户资料并在同一事务中写入刷新其商品卖家信息的事件
+func updateSeller(user *models.User) error {
+	var events indexEvents
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.Update(user, tx); err != nil {
+			return err
+		}
+		return events.add(tx, indexer.SellerUpdated, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	events.publish()
+	return nil
+}
+
+// decodeProductDocuments 将es返回的文档转换为列表展示的商品
+func decodeProductDocuments(hits []json.RawMessage) ([]response.UserProduct, error) {
+	products := make([]response.UserProduct, 0, len(hits))
+	for _, hit := range hits {
+		var document request.ProductDocument
+		if err := json.Unmarshal(hit, &document); err != nil {
+			return nil, err
+		}
+
+		products = append(products, documentToUserProduct(document))
+	}
+
+	return products, nil
+}
+
+func documentToUserProduct(document request.ProductDocument) response.UserProduct {
+	product := response.UserProduct{
+		User: models.User{
+			Model:    models.Model{ID: document.Seller.ID},
+			Username: document.Seller.Username,
+			Avatar:   document.Seller.Avatar,
+		},
+		Credit: models.Credit{
+			UserID:          document.SellerID,
+			TotalComment:    document.Seller.TotalComment,
+			PositiveComment: document.Seller.PositiveComment,
+			NegativeComment: document.Seller.NegativeComment,
+			Reputation:      document.SellerReputation,
+		},
+		Product: models.Product{
+			Model: models.Model{
+				ID:        document.ID,
+				CreatedAt: document.CreatedAt,
+			},
+			UserID:         document.SellerID,
+			OriginalPrice:  document.OriginalPrice,
+			Price:          document.Price,
+			Describe:       document.Describe,
+			Pics:           document.Pics,
+			Condition:      document.Condition,
+			UsedTime:       document.UsedTime,
+			ShippingMethod: document.ShippingMethod,
+			ShippingPrise:  document.ShippingPrice,
+			CanSelfPickup:  document.CanSelfPickup,
+			Location:       document.Location,
+			PublishAt:      document.PublishAt,
+			IsPublished:    document.IsPublished,
+			IsSold:         document.IsSold,
+			IsSelling:      document.IsSelling,
+			ViewCount:      document.ViewCount,
+		},
+		Categories: document.CategoryIDs,
+		Attributes: make(map[uint]string, len(document.Attributes)),
+		LikeCount:  document.LikeCount,
+		Address:    document.Address,
+	}
+
+	for _, attribute := range document.Attributes {
+		product.Attributes[attribute.AttributeID] = attribute.Value
+	}
+
+	return product
+}
+
+// loadCategories 加载全部分类，分类表很小，直接全量读取
+func loadCategories() (map[uint]models.Category, error) {
+	categories, err := db.GetAll[models.Category]()
+	if err != nil {
+		return nil, err
+	}
+
+	categoryMap := make(map[uint]models.Category, len(categories))
+	for _, category := range categories {
+		categoryMap[category.ID] = category
+	}
+
+	return categoryMap, nil
+}
+
+// categoryAncestors 返回分类自身及其所有祖先分类的id
+func categoryAncestors(categories map[uint]models.Category, id uint) []uint {
+	ancestors := []uint{}
+	for id != 0 {
+		category, ok := categories[id]
+		if !ok || slices.Contains(ancestors, id) {
+			break
+		}
+
+		ancestors = append(ancestors, id)
+		id = category.ParentID
+	}
+
+	return ancestors
+}
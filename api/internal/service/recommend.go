@@ -1 +1,362 @@
 package service
+
+import (
+	"context"
+
+	"github.com/mislu/market-api/internal/core/recommend"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/models"
+)
+
+const (
+	// 兴趣分类的权重，收藏比兴趣标签更能代表偏好，浏览最弱
+	interestTagAffinity = 1.0
+	likeAffinity        = 2.0
+	viewAffinity        = 0.5
+
+	affinityLikeLimit = 50
+	affinityViewLimit = 100
+)
+
+// LocalRecommender 推荐服务不可用时的兜底推荐，按用户的分类偏好和商品热度排序
+type LocalRecommender struct{}
+
+func NewLocalRecommender() LocalRecommender {
+	return LocalRecommender{}
+}
+
+func (LocalRecommender) Recommend(_ context.Context, userID string, n int) ([]string, error) {
+	profile := userProfile{affinity: map[uint]float64{}}
+	if len(userID) > 0 {
+		var err error
+		profile, err = loadUserProfile(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return recommendByProfile(userID, profile, n)
+}
+
+// recommendProducts 只选了兴趣标签、还没有收藏和浏览的新用户从标签分类的商品中推荐，其余使用推荐服务
+func recommendProducts(userID string, n int) ([]string, error) {
+	if len(userID) > 0 {
+		profile, err := loadUserProfile(userID)
+		if err != nil {
+			return nil, err
+		}
+		if profile.coldStart() {
+			return recommendByProfile(userID, profile, n)
+		}
+	}
+
+	return recommend.GetRecommendations(userID, n)
+}
+
+func recommendByProfile(userID string, profile userProfile, n int) ([]string, error) {
+	query := map[string]interface{}{
+		"size":    n,
+		"_source": []string{"id"},
+		"query":   affinityQuery(userID, profile),
+	}
+
+	hits, err := es.Search(es.ProductIndex, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		if id, ok := hit["id"].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// userProfile 用户对各分类的偏好，由兴趣标签、收藏和最近浏览汇总得到
+type userProfile struct {
+	affinity      map[uint]float64
+	tagCategories []uint
+	behaviors     int // 收藏和浏览的数量
+}
+
+// coldStart 选了兴趣标签但还没有任何行为
+func (p userProfile) coldStart() bool {
+	return p.behaviors == 0 && len(p.tagCategories) > 0
+}
+
+func loadUserProfile(userID string) (userProfile, error) {
+	profile := userProfile{affinity: map[uint]float64{}}
+
+	interests, err := db.GetAll[models.UserInterests](
+		db.Equal("user_id", userID),
+	)
+	if err != nil {
+		return profile, err
+	}
+	if len(interests) > 0 {
+		tagIDs := make([]int, 0, len(interests))
+		for _, interest := range interests {
+			tagIDs = append(tagIDs, interest.InterestTagID)
+		}
+		tags, err := db.GetAll[models.InterestTag](
+			db.InArray("id", tagIDs),
+		)
+		if err != nil {
+			return profile, err
+		}
+		for _, tag := range tags {
+			profile.affinity[uint(tag.CategoryID)] += interestTagAffinity
+			profile.tagCategories = append(profile.tagCategories, uint(tag.CategoryID))
+		}
+	}
+
+	likes, err := db.GetAll[models.Like](
+		db.Equal("user_id", userID),
+		db.Page(1, affinityLikeLimit),
+	)
+	if err != nil {
+		return profile, err
+	}
+	likedIDs := make([]string, 0, len(likes))
+	for _, like := range likes {
+		likedIDs = append(likedIDs, like.ProductID)
+	}
+	if err := addProductCategoryAffinity(profile.affinity, likedIDs, likeAffinity); err != nil {
+		return profile, err
+	}
+
+	views, err := db.GetAll[models.Feedback](
+		db.Equal("user_id", userID),
+		db.Equal("feedback_type", recommend.FeedbackView),
+		db.OrderBy("timestamp", true),
+		db.Page(1, affinityViewLimit),
+	)
+	if err != nil {
+		return profile, err
+	}
+	viewedIDs := make([]string, 0, len(views))
+	for _, view := range views {
+		viewedIDs = append(viewedIDs, view.ItemID)
+	}
+	if err := addProductCategoryAffinity(profile.affinity, viewedIDs, viewAffinity); err != nil {
+		return profile, err
+	}
+
+	profile.behaviors = len(likes) + len(views)
+	return profile, nil
+}
+
+func addProductCategoryAffinity(affinity map[uint]float64, productIDs []string, weight float64) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	productCategories, err := db.GetAll[models.ProductCategory](
+		db.InArray("product_id", productIDs),
+	)
+	if err != nil {
+		return err
+	}
+	for _, productCategory := range productCategories {
+		affinity[productCategory.CategoryID] += weight
+	}
+
+	return nil
+}
+
+// affinityQuery 在售商品按分类偏好加分，再叠加收藏数、浏览数和发布时间；冷启动时只推荐兴趣标签分类下的商品
+func affinityQuery(userID string, profile userProfile) map[string]interface{} {
+	affinity := profile.affinity
+	filter := []map[string]interface{}{
+		{"term": map[string]interface{}{"is_published": true}},
+		{"term": map[string]interface{}{"is_selling": true}},
+		{"term": map[string]interface{}{"is_sold": false}},
+	}
+	if profile.coldStart() {
+		filter = append(filter, map[string]interface{}{
+			"terms": map[string]interface{}{"category_path": profile.tagCategories},
+		})
+	}
+	boolQuery := map[string]interface{}{"filter": filter}
+	if len(userID) > 0 {
+		boolQuery["must_not"] = []map[string]interface{}{
+			{"term": map[string]interface{}{"seller_id": userID}},
+		}
+	}
+
+	var maxAffinity float64
+	for _, v := range affinity {
+		if v > maxAffinity {
+			maxAffinity = v
+		}
+	}
+
+	functions := []map[string]interface{}{
+		{
+			"field_value_factor": map[string]interface{}{
+				"field":    "like_count",
+				"modifier": "log1p",
+				"missing":  0,
+			},
+			"weight": 0.5,
+		},
+		{
+			"field_value_factor": map[string]interface{}{
+				"field":    "view_count",
+				"modifier": "log1p",
+				"missing":  0,
+			},
+			"weight": 0.2,
+		},
+		{
+			"gauss": map[string]interface{}{
+				"publish_at": map[string]interface{}{
+					"origin": "now",
+					"scale":  "7d",
+					"decay":  0.5,
+				},
+			},
+			"weight": 1,
+		},
+	}
+	for categoryID, v := range affinity {
+		functions = append(functions, map[string]interface{}{
+			"filter": map[string]interface{}{"term": map[string]interface{}{"category_path": categoryID}},
+			"weight": 2 * v / maxAffinity,
+		})
+	}
+
+	return map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query":      map[string]interface{}{"bool": boolQuery},
+			"functions":  functions,
+			"score_mode": "sum",
+			"boost_mode": "replace",
+		},
+	}
+}
+
+// CleanAllFakeRecommendItems 清理所有选择过兴趣标签的用户的虚拟商品，返回删除的数量
+func CleanAllFakeRecommendItems(batchSize int) (int, error) {
+	total := 0
+	lastID := ""
+	for {
+		users, err := db.GetAll[models.User](
+			db.Fields("id"),
+			db.Equal("selected_tags", true),
+			db.GreaterThan("id", lastID),
+			db.OrderBy("id", false),
+			db.Page(1, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+
+		for _, user := range users {
+			deleted, err := CleanFakeRecommendItems(user.ID)
+			if err != nil {
+				return total, err
+			}
+			total += deleted
+		}
+
+		if len(users) < batchSize {
+			return total, nil
+		}
+		lastID = users[len(users)-1].ID
+	}
+}
+
+// BackfillRecommendItems 按id顺序把所有商品写入推荐服务，返回写入的数量
+func BackfillRecommendItems(batchSize int) (int, error) {
+	total := 0
+	lastID := ""
+	for {
+		products, err := db.GetAll[models.Product](
+			db.GreaterThan("id", lastID),
+			db.OrderBy("id", false),
+			db.Page(1, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+
+		for _, product := range products {
+			document, err := buildProductDocument(product)
+			if err != nil {
+				return total, err
+			}
+			if err := recommend.CreateItem(product, document.Category, recommendItemLabels(document), !isRecommendable(product)); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if len(products) < batchSize {
+			return total, nil
+		}
+		lastID = products[len(products)-1].ID
+	}
+}
+
+// BackfillRecommendUsers 按id顺序把所有用户及其兴趣标签写入推荐服务，返回写入的数量
+func BackfillRecommendUsers(batchSize int) (int, error) {
+	tags, err := db.GetAll[models.InterestTag]()
+	if err != nil {
+		return 0, err
+	}
+	tagNames := make(map[int]string, len(tags))
+	for _, tag := range tags {
+		tagNames[tag.ID] = tag.TagName
+	}
+
+	total := 0
+	lastID := ""
+	for {
+		users, err := db.GetAll[models.User](
+			db.Fields("id"),
+			db.GreaterThan("id", lastID),
+			db.OrderBy("id", false),
+			db.Page(1, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+		if len(users) == 0 {
+			return total, nil
+		}
+
+		userIDs := make([]string, 0, len(users))
+		for _, user := range users {
+			userIDs = append(userIDs, user.ID)
+		}
+		interests, err := db.GetAll[models.UserInterests](
+			db.InArray("user_id", userIDs),
+		)
+		if err != nil {
+			return total, err
+		}
+		labels := make(map[string][]string, len(users))
+		for _, interest := range interests {
+			if name, ok := tagNames[interest.InterestTagID]; ok {
+				labels[interest.UserID] = append(labels[interest.UserID], name)
+			}
+		}
+
+		for _, user := range users {
+			if err := recommend.UpsertUser(user.ID, labels[user.ID]); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if len(users) < batchSize {
+			return total, nil
+		}
+		lastID = users[len(users)-1].ID
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/indexer"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/models"
+)
+
+// ReindexProducts 按id顺序从afterID之后分批把商品写入index，每批完成后回调checkpoint记录进度
+func ReindexProducts(index string, afterID string, batchSize int, checkpoint func(lastID string) error) (int, error) {
+	total := 0
+	for {
+		products, err := db.GetAll[models.Product](
+			db.GreaterThan("id", afterID),
+			db.OrderBy("id", false),
+			db.Page(1, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+		if len(products) == 0 {
+			return total, nil
+		}
+
+		if err := indexProductBatch(index, products); err != nil {
+			return total, err
+		}
+
+		total += len(products)
+		afterID = products[len(products)-1].ID
+		if err := checkpoint(afterID); err != nil {
+			return total, err
+		}
+	}
+}
+
+// ReindexProductsUpdatedSince 补齐重建期间发生变化的商品
+func ReindexProductsUpdatedSince(index string, since time.Time, batchSize int) (int, error) {
+	total := 0
+	for page := 1; ; page++ {
+		products, err := db.GetAll[models.Product](
+			db.WhereSQL("updated_at >= ?", since),
+			db.OrderBy("id", false),
+			db.Page(page, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+		if len(products) == 0 {
+			return total, nil
+		}
+
+		if err := indexProductBatch(index, products); err != nil {
+			return total, err
+		}
+		total += len(products)
+	}
+}
+
+// ReplayIndexEvents 把afterID之后的商品索引事件应用到index，覆盖不改变updated_at的变更(如收藏数、卖家信息)和删除；
+// 只重建文档，不重复匹配保存的搜索和同步推荐服务。返回最后处理的事件id
+func ReplayIndexEvents(index string, afterID uint, batchSize int) (uint, int, error) {
+	total := 0
+	for {
+		events, err := db.GetAll[models.IndexOutbox](
+			db.GreaterThan("id", afterID),
+			db.OrderBy("id", false),
+			db.Page(1, batchSize),
+		)
+		if err != nil {
+			return afterID, total, err
+		}
+		if len(events) == 0 {
+			return afterID, total, nil
+		}
+
+		productIDs := []string{}
+		sellerIDs := []string{}
+		for _, event := range events {
+			if indexer.EventType(event.EventType) == indexer.SellerUpdated {
+				sellerIDs = append(sellerIDs, event.TargetID)
+			} else {
+				productIDs = append(productIDs, event.TargetID)
+			}
+		}
+		slices.Sort(productIDs)
+		slices.Sort(sellerIDs)
+
+		if err := replayProducts(index, slices.Compact(productIDs)); err != nil {
+			return afterID, total, err
+		}
+		for _, sellerID := range slices.Compact(sellerIDs) {
+			if err := refreshSellerDocuments(index, sellerID); err != nil {
+				return afterID, total, err
+			}
+		}
+
+		total += len(events)
+		afterID = events[len(events)-1].ID
+	}
+}
+
+// replayProducts 按MySQL中的最新数据重建商品文档，已删除的商品从index中删除
+func replayProducts(index string, productIDs []string) error {
+	products, err := db.GetAll[models.Product](
+		db.InArray("id", productIDs),
+	)
+	if err != nil {
+		return err
+	}
+
+	exists := make(map[string]bool, len(products))
+	for _, product := range products {
+		exists[product.ID] = true
+	}
+	for _, productID := range productIDs {
+		if exists[productID] {
+			continue
+		}
+		if err := es.DeleteDocument(index, productID); err != nil {
+			return err
+		}
+	}
+
+	if len(products) == 0 {
+		return nil
+	}
+	return indexProductBatch(index, products)
+}
+
+func indexProductBatch(index string, products []models.Product) error {
+	docs := make(map[string]interface{}, len(products))
+	for _, product := range products {
+		document, err := buildProductDocument(product)
+		if err != nil {
+			return err
+		}
+		docs[document.ID] = document
+	}
+
+	return es.BulkIndex(index, docs)
+}
+
+// ReindexMessages 按id顺序从afterID之后分批把文本消息写入index
+func ReindexMessages(index string, afterID string, batchSize int, checkpoint func(lastID string) error) (int, error) {
+	total := 0
+	for {
+		messages, err := db.GetAll[models.Message](
+			db.GreaterThan("id", afterID),
+			db.OrderBy("id", false),
+			db.Page(1, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+		if len(messages) == 0 {
+			return total, nil
+		}
+
+		if err := indexMessageBatch(index, messages); err != nil {
+			return total, err
+		}
+
+		total += len(messages)
+		afterID = messages[len(messages)-1].ID
+		if err := checkpoint(afterID); err != nil {
+			return total, err
+		}
+	}
+}
+
+// ReindexMessagesUpdatedSince 补齐重建期间新增或变化的消息
+func ReindexMessagesUpdatedSince(index string, since time.Time, batchSize int) (int, error) {
+	total := 0
+	for page := 1; ; page++ {
+		messages, err := db.GetAll[models.Message](
+			db.WhereSQL("updated_at >= ?", since),
+			db.OrderBy("id", false),
+			db.Page(page, batchSize),
+		)
+		if err != nil {
+			return total, err
+		}
+		if len(messages) == 0 {
+			return total, nil
+		}
+
+		if err := indexMessageBatch(index, messages); err != nil {
+			return total, err
+		}
+		total += len(messages)
+	}
+}
+
+func indexMessageBatch(index string, messages []models.Message) error {
+	docs := make(map[string]interface{}, len(messages))
+	for _, message := range messages {
+		if message.MediaType != text || message.IsRecalled {
+			continue
+		}
+
+		document, err := buildMessageDocument(message)
+		if err != nil {
+			return err
+		}
+		docs[message.ID] = document
+	}
+
+	return es.BulkIndex(index, docs)
+}
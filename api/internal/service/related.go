@@ -0,0 +1,234 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/recommend"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+const (
+	relatedSize       = 6
+	relatedCacheTTL   = 2 * time.Minute
+	alsoViewedWindow  = 30 * 24 * time.Hour
+	relatedCandidates = 2 * relatedSize // 多取一些，过滤已售出的商品后仍能填满
+)
+
+// relatedIDs 商品详情页的相关商品id，短暂缓存，返回前重新过滤已售出的商品
+type relatedIDs struct {
+	similar    []string
+	seller     []string
+	alsoViewed []string
+	expiresAt  time.Time
+}
+
+var relatedCache = struct {
+	sync.Mutex
+	entries map[string]relatedIDs
+}{entries: make(map[string]relatedIDs)}
+
+// fillRelatedProducts 填充相似商品、卖家的其他商品和看过的人还看过，失败只记录日志，不影响商品详情
+func fillRelatedProducts(resp *response.GetProductResp, productID string, sellerID string, attributeValues []string) {
+	ids, err := loadRelatedIDs(productID, sellerID, attributeValues)
+	if err != nil {
+		log.Printf("failed to load related products of %s: %v", productID, err)
+		return
+	}
+
+	all := make([]string, 0, len(ids.similar)+len(ids.seller)+len(ids.alsoViewed))
+	all = append(all, ids.similar...)
+	all = append(all, ids.seller...)
+	all = append(all, ids.alsoViewed...)
+
+	documents, err := es.MultiGet(es.ProductIndex, all)
+	if err != nil {
+		log.Printf("failed to load related products of %s: %v", productID, err)
+		return
+	}
+
+	resp.Similar = availableProducts(ids.similar, productID, documents)
+	resp.SellerProducts = availableProducts(ids.seller, productID, documents)
+	resp.AlsoViewed = availableProducts(ids.alsoViewed, productID, documents)
+}
+
+// availableProducts 按顺序取最多relatedSize个在售商品
+func availableProducts(ids []string, excludeID string, documents map[string]json.RawMessage) []response.UserProduct {
+	products := make([]response.UserProduct, 0, relatedSize)
+	for _, id := range ids {
+		raw, ok := documents[id]
+		if !ok || id == excludeID {
+			continue
+		}
+
+		var document request.ProductDocument
+		if err := json.Unmarshal(raw, &document); err != nil {
+			continue
+		}
+		if !document.IsPublished || !document.IsSelling || document.IsSold {
+			continue
+		}
+
+		products = append(products, documentToUserProduct(document))
+		if len(products) == relatedSize {
+			break
+		}
+	}
+
+	return products
+}
+
+func loadRelatedIDs(productID string, sellerID string, attributeValues []string) (relatedIDs, error) {
+	relatedCache.Lock()
+	ids, ok := relatedCache.entries[productID]
+	relatedCache.Unlock()
+	if ok && time.Now().Before(ids.expiresAt) {
+		return ids, nil
+	}
+
+	var err error
+	if ids.similar, err = similarProductIDs(productID, attributeValues); err != nil {
+		return ids, err
+	}
+	if ids.seller, err = sellerProductIDs(productID, sellerID); err != nil {
+		return ids, err
+	}
+	if ids.alsoViewed, err = alsoViewedProductIDs(productID); err != nil {
+		return ids, err
+	}
+
+	now := time.Now()
+	ids.expiresAt = now.Add(relatedCacheTTL)
+
+	relatedCache.Lock()
+	for key, entry := range relatedCache.entries {
+		if now.After(entry.expiresAt) {
+			delete(relatedCache.entries, key)
+		}
+	}
+	relatedCache.entries[productID] = ids
+	relatedCache.Unlock()
+
+	return ids, nil
+}
+
+// similarProductIDs 优先使用推荐服务计算的相似物品，不可用或没有结果时按标题、分类和属性查找相似商品
+func similarProductIDs(productID string, attributeValues []string) ([]string, error) {
+	ids, err := recommend.GetNeighbors(productID, relatedCandidates)
+	if err == nil && len(ids) > 0 {
+		return ids, nil
+	}
+
+	should := []map[string]interface{}{
+		{"more_like_this": map[string]interface{}{
+			"fields":        []string{"describe", "category"},
+			"like":          []map[string]interface{}{{"_id": productID}},
+			"min_term_freq": 1,
+			"min_doc_freq":  1,
+		}},
+	}
+	// 属性是nested字段，more_like_this取不到，直接按属性值匹配
+	if len(attributeValues) > 0 {
+		should = append(should, map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path":  "attributes",
+				"query": map[string]interface{}{"terms": map[string]interface{}{"attributes.value": attributeValues}},
+			},
+		})
+	}
+
+	query := map[string]interface{}{
+		"size":    relatedCandidates,
+		"_source": []string{"id"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               should,
+				"minimum_should_match": 1,
+				"filter":               availableFilter(),
+				"must_not": []map[string]interface{}{
+					{"ids": map[string]interface{}{"values": []string{productID}}},
+				},
+			},
+		},
+	}
+
+	return searchProductIDs(query)
+}
+
+// sellerProductIDs 卖家最新发布的其他在售商品
+func sellerProductIDs(productID string, sellerID string) ([]string, error) {
+	filter := append(availableFilter(), map[string]interface{}{
+		"term": map[string]interface{}{"seller_id": sellerID},
+	})
+
+	query := map[string]interface{}{
+		"size":    relatedCandidates,
+		"_source": []string{"id"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filter,
+				"must_not": []map[string]interface{}{
+					{"ids": map[string]interface{}{"values": []string{productID}}},
+				},
+			},
+		},
+		"sort": []map[string]interface{}{
+			{"publish_at": map[string]interface{}{"order": "desc"}},
+		},
+	}
+
+	return searchProductIDs(query)
+}
+
+// alsoViewedProductIDs 最近浏览过该商品的用户还浏览过的商品，按浏览人数排序
+func alsoViewedProductIDs(productID string) ([]string, error) {
+	since := time.Now().Add(-alsoViewedWindow)
+	rows, err := db.GetAny[[]struct {
+		ItemID  string
+		Viewers int64
+	}](
+		"SELECT other.item_id AS item_id, COUNT(DISTINCT other.user_id) AS viewers FROM feedback AS viewed "+
+			"JOIN feedback AS other ON other.user_id = viewed.user_id "+
+			"WHERE viewed.item_id = ? AND viewed.feedback_type = ? AND viewed.timestamp > ? "+
+			"AND other.item_id <> ? AND other.feedback_type = ? AND other.timestamp > ? "+
+			"GROUP BY other.item_id ORDER BY viewers DESC LIMIT ?",
+		productID, recommend.FeedbackView, since, productID, recommend.FeedbackView, since, relatedCandidates,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ItemID)
+	}
+	return ids, nil
+}
+
+func availableFilter() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"term": map[string]interface{}{"is_published": true}},
+		{"term": map[string]interface{}{"is_selling": true}},
+		{"term": map[string]interface{}{"is_sold": false}},
+	}
+}
+
+func searchProductIDs(query map[string]interface{}) ([]string, error) {
+	hits, err := es.Search(es.ProductIndex, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		if id, ok := hit["id"].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/notify"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+const (
+	maxSavedSearches     = 20
+	savedSearchBatchSize = 100
+	digestInterval       = 24 * time.Hour
+	digestCheckInterval  = time.Hour
+)
+
+var (
+	errSavedSearchLimit    = errors.New("saved search limit reached")
+	errSavedSearchNotFound = errors.New("saved search not found")
+)
+
+func CreateSavedSearch(req *request.CreateSavedSearchReq) (response.SavedSearchResp, exceptions.APIError) {
+	var resp response.SavedSearchResp
+
+	count, err := db.GetCount[models.SavedSearch](
+		db.Equal("user_id", req.UserID),
+	)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+	if count >= maxSavedSearches {
+		return resp, exceptions.BadRequestError(errSavedSearchLimit, exceptions.SavedSearchLimitError)
+	}
+
+	query := req.Query
+	query.UserID = ""
+	query.PageReq = request.PageReq{}
+	query.Sort = request.SortOption{}
+	query.PriceInterval = 0
+	// 附近搜索在保存时确定位置，之后修改默认地址不影响已保存的搜索
+	if query.Nearby != nil {
+		if apiErr := resolveNearbyOrigin(req.UserID, query.Nearby); apiErr != nil {
+			return resp, apiErr
+		}
+	}
+
+	query.PageReq.Fill()
+	if _, apiErr := buildSearchReq(&query); apiErr != nil {
+		return resp, apiErr
+	}
+	query.PageReq = request.PageReq{}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	frequency := req.Frequency
+	if len(frequency) == 0 {
+		frequency = models.SavedSearchInstant
+	}
+
+	savedSearch := models.SavedSearch{
+		UserID:       req.UserID,
+		Name:         req.Name,
+		Query:        string(data),
+		Frequency:    frequency,
+		LastDigestAt: time.Now(),
+	}
+	if err := db.Create(&savedSearch); err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	return toSavedSearchResp(savedSearch, 0), nil
+}
+
+func GetSavedSearches(userID string) (response.GetSavedSearchesResp, exceptions.APIError) {
+	resp := response.GetSavedSearchesResp{
+		SavedSearches: []response.SavedSearchResp{},
+	}
+
+	savedSearches, err := db.GetAll[models.SavedSearch](
+		db.Equal("user_id", userID),
+		db.OrderBy("id", true),
+	)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	unread, err := db.GetAny[[]struct {
+		SavedSearchID uint
+		Count         int64
+	}]("SELECT saved_search_id, COUNT(*) AS count FROM search_alert WHERE user_id = ? AND is_read = false GROUP BY saved_search_id", userID)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	unreadMap := make(map[uint]int64, len(unread))
+	for _, item := range unread {
+		unreadMap[item.SavedSearchID] = item.Count
+	}
+
+	for _, savedSearch := range savedSearches {
+		resp.SavedSearches = append(resp.SavedSearches, toSavedSearchResp(savedSearch, unreadMap[savedSearch.ID]))
+	}
+
+	return resp, nil
+}
+
+func DeleteSavedSearch(req *request.SavedSearchIDReq) exceptions.APIError {
+	savedSearch, err := db.GetOne[models.SavedSearch](
+		db.Equal("id", req.SavedSearchID),
+		db.Equal("user_id", req.UserID),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+	if !savedSearch.Exists() {
+		return exceptions.BadRequestError(errSavedSearchNotFound, exceptions.SavedSearchNotFoundError)
+	}
+
+	if err := db.Delete(&savedSearch); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	if err := db.DeleteByCondition(models.SearchAlert{SavedSearchID: savedSearch.ID}); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func GetSearchAlerts(req *request.GetSearchAlertsReq) (response.GetSearchAlertsResp, exceptions.APIError) {
+	resp := response.GetSearchAlertsResp{
+		Alerts: []models.SearchAlert{},
+	}
+
+	query := []db.GenericQuery{
+		db.Equal("user_id", req.UserID),
+	}
+	if req.SavedSearchID > 0 {
+		query = append(query, db.Equal("saved_search_id", req.SavedSearchID))
+	}
+	if req.UnreadOnly {
+		query = append(query, db.Equal("is_read", false))
+	}
+
+	total, err := db.GetCount[models.SearchAlert](query...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	alerts, err := db.GetAll[models.SearchAlert](append(query,
+		db.OrderBy("id", true),
+		db.Page(req.Page, req.Size),
+	)...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	resp.Alerts = alerts
+	resp.Total = total
+	resp.Page = req.Page
+	resp.Size = req.Size
+	resp.HasMore = int64(req.Page*req.Size) < total
+	return resp, nil
+}
+
+func MarkSearchAlertsRead(req *request.MarkSearchAlertsReadReq) exceptions.APIError {
+	query := []db.GenericQuery{
+		db.Model(&models.SearchAlert{}),
+		db.Equal("user_id", req.UserID),
+		db.Equal("is_read", false),
+	}
+	if req.SavedSearchID > 0 {
+		query = append(query, db.Equal("saved_search_id", req.SavedSearchID))
+	}
+
+	if err := db.Run(append(query, db.Set(map[string]any{"is_read": true}))...); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func toSavedSearchResp(savedSearch models.SavedSearch, unread int64) response.SavedSearchResp {
+	resp := response.SavedSearchResp{
+		ID:           savedSearch.ID,
+		Name:         savedSearch.Name,
+		Frequency:    savedSearch.Frequency,
+		UnreadAlerts: unread,
+		CreatedAt:    savedSearch.CreatedAt,
+	}
+	_ = json.Unmarshal([]byte(savedSearch.Query), &resp.Query)
+
+	return resp
+}
+
+// matchSavedSearches 用保存的搜索条件加上商品id过滤批量查询es，命中的生成提醒
+func matchSavedSearches(productID string, reason string) error {
+	product, err := db.GetOne[models.Product](
+		db.Equal("id", productID),
+	)
+	if err != nil {
+		return err
+	}
+	if !product.Exists() || !product.IsPublished || !product.IsSelling || product.IsSold {
+		return nil
+	}
+
+	var lastID uint
+	for {
+		savedSearches, err := db.GetAll[models.SavedSearch](
+			db.GreaterThan("id", lastID),
+			db.NotEqual("user_id", product.UserID),
+			db.OrderBy("id", false),
+			db.Page(1, savedSearchBatchSize),
+		)
+		if err != nil {
+			return err
+		}
+		if len(savedSearches) == 0 {
+			return nil
+		}
+		lastID = savedSearches[len(savedSearches)-1].ID
+
+		candidates := make([]models.SavedSearch, 0, len(savedSearches))
+		queries := make([]map[string]interface{}, 0, len(savedSearches))
+		for _, savedSearch := range savedSearches {
+			query, err := buildSavedSearchQuery(savedSearch, productID)
+			if err != nil {
+				log.Printf("skip invalid saved search %d: %v", savedSearch.ID, err)
+				continue
+			}
+
+			candidates = append(candidates, savedSearch)
+			queries = append(queries, query)
+		}
+
+		counts, err := es.MultiCount(es.ProductIndex, queries)
+		if err != nil {
+			return err
+		}
+
+		for i, count := range counts {
+			if count == 0 {
+				continue
+			}
+
+			if err := createSearchAlert(candidates[i], product, reason); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func buildSavedSearchQuery(savedSearch models.SavedSearch, productID string) (map[string]interface{}, error) {
+	var req request.SearchProductReq
+	if err := json.Unmarshal([]byte(savedSearch.Query), &req); err != nil {
+		return nil, err
+	}
+
+	req.PageReq.Fill()
+	query, apiErr := buildSearchReq(&req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// 相关性排序时query被function_score包装，只统计命中数，直接在外层限定商品id
+	query["query"] = map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must": []map[string]interface{}{
+				query["query"].(map[string]interface{}),
+			},
+			"filter": []map[string]interface{}{
+				{"ids": map[string]interface{}{"values": []string{productID}}},
+			},
+		},
+	}
+
+	return query, nil
+}
+
+// createSearchAlert 同一商品同一价格只提醒一次，即时订阅直接推送，其余等待汇总
+func createSearchAlert(savedSearch models.SavedSearch, product models.Product, reason string) error {
+	exists, err := db.GetCount[models.SearchAlert](
+		db.Equal("saved_search_id", savedSearch.ID),
+		db.Equal("product_id", product.ID),
+		db.Equal("reason", reason),
+		db.Equal("price", product.Price),
+	)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	alert := models.SearchAlert{
+		UserID:        savedSearch.UserID,
+		SavedSearchID: savedSearch.ID,
+		ProductID:     product.ID,
+		Reason:        reason,
+		Price:         product.Price,
+		Describe:      product.Describe,
+		Pics:          product.Pics,
+	}
+	if savedSearch.Frequency == models.SavedSearchInstant {
+		now := time.Now()
+		alert.DeliveredAt = &now
+	}
+
+	if err := db.Create(&alert); err != nil {
+		return err
+	}
+
+	if savedSearch.Frequency == models.SavedSearchInstant {
+		notifyUser(savedSearch.UserID, notify.TypeSavedSearch, product.ID, map[string]any{
+			"name":    savedSearch.Name,
+			"reason":  reason,
+			"product": product.Describe,
+			"price":   product.Price,
+		})
+	}
+
+	return nil
+}
+
+// sendSearchDigests 汇总推送每日订阅中未推送的提醒
+func sendSearchDigests() error {
+	pending, err := db.GetAny[[]struct {
+		SavedSearchID uint
+		Count         int64
+	}]("SELECT saved_search_id, COUNT(*) AS count FROM search_alert WHERE delivered_at IS NULL GROUP BY saved_search_id")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, item := range pending {
+		savedSearch, err := db.GetOne[models.SavedSearch](
+			db.Equal("id", item.SavedSearchID),
+		)
+		if err != nil {
+			return err
+		}
+		if !savedSearch.Exists() || now.Sub(savedSearch.LastDigestAt) < digestInterval {
+			continue
+		}
+
+		if err := db.Run(
+			db.Model(&models.SearchAlert{}),
+			db.Equal("saved_search_id", savedSearch.ID),
+			db.WhereSQL("delivered_at IS NULL"),
+			db.Set(map[string]any{"delivered_at": now}),
+		); err != nil {
+			return err
+		}
+
+		if err := db.Run(
+			db.Model(&models.SavedSearch{}),
+			db.Equal("id", savedSearch.ID),
+			db.Set(map[string]any{"last_digest_at": now}),
+		); err != nil {
+			return err
+		}
+
+		if err := sendNotification(savedSearch.UserID, notify.TypeSavedSearchDigest, "", map[string]any{
+			"name":  savedSearch.Name,
+			"count": item.Count,
+		}); err != nil {
+			log.Printf("failed to send search digest %d: %v", savedSearch.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// StartSearchDigestWorker 定时发送保存的搜索的每日汇总
+func StartSearchDigestWorker(ctx context.Context) {
+	go runPeriodically(ctx, digestCheckInterval, func() {
+		if err := sendSearchDigests(); err != nil {
+			log.Printf("failed to send search digests: %v", err)
+		}
+	})
+}
@@ -1,8 +1,13 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/mislu/market-api/internal/core/ranking"
 	"github.com/mislu/market-api/internal/db"
 	"github.com/mislu/market-api/internal/es"
 	"github.com/mislu/market-api/internal/types/exceptions"
@@ -14,101 +19,76 @@ import (
 func SearchProduct(req *request.SearchProductReq) (response.SearchProductResp, exceptions.APIError) {
 	var resp response.SearchProductResp
 
-	if len(req.UserID) > 0 {
-		history, _ := db.GetOne[models.SearchHistory](
-			db.Equal("user_id", req.UserID),
-			db.Equal("keyword", req.Keyword),
-		)
-
-		if len(history.Keyword) > 0 {
-			// 之前搜索过
-			history.SearchTime = time.Now().Unix()
-			db.Update(&history)
-		} else {
-			searchHistory := models.SearchHistory{
-				UserID:     req.UserID,
-				Keyword:    req.Keyword,
-				SearchTime: time.Now().Unix(),
-			}
-			db.Create(&searchHistory)
+	if req.Nearby != nil {
+		if apiErr := resolveNearbyOrigin(req.UserID, req.Nearby); apiErr != nil {
+			return resp, apiErr
 		}
 	}
 
-	query := buildSearchReq(req)
+	query, apiErr := buildSearchReq(req)
+	if apiErr != nil {
+		return resp, apiErr
+	}
 
-	esResp, err := es.Search("m-market", query)
+	attributes, err := boundAttributes(req.Categories)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	productIDs := make([]string, 0, len(esResp))
-	for _, product := range esResp {
-		productIDs = append(productIDs, product["id"].(string))
-	}
-
-	products, err := db.GetAll[models.Product](
-		db.InArray("id", productIDs),
-	)
+	query["aggs"] = buildFacetAggregations(attributes, req.PriceInterval)
+	result, err := es.SearchWithAggregations(es.ProductIndex, query)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	productMap := make(map[string]models.Product, len(products))
-	for _, product := range products {
-		productMap[product.ID] = product
-	}
-	orderedProducts := make([]models.Product, 0, len(productIDs))
-	for _, id := range productIDs {
-		if product, exists := productMap[id]; exists {
-			orderedProducts = append(orderedProducts, product)
-		}
-	}
-
-	userIDs := make([]string, 0, len(orderedProducts))
-	for _, product := range orderedProducts {
-		userIDs = append(userIDs, product.UserID)
+	resp.Facets, err = parseFacets(result.Aggregations, attributes, req.PriceInterval)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
 	}
 
-	users, err := db.GetAll[models.User](
-		db.Fields("id", "username", "avatar"),
-		db.InArray("id", userIDs),
-	)
+	products, err := decodeProductDocuments(result.Hits)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
 
-	userProduct := make([]response.UserProduct, 0, len(orderedProducts))
-	for _, product := range orderedProducts {
-		for _, user := range users {
-			if user.ID == product.UserID {
-				address, err := getProductAddress(product.Location)
-				if err != nil {
-					continue
-				}
-				userProduct = append(userProduct, response.UserProduct{
-					User:    user,
-					Product: product,
-					Address: address.Address,
-				})
-			}
+	if req.Nearby != nil {
+		if err := fillDistances(products, result.Hits, req.Nearby); err != nil {
+			return resp, exceptions.InternalServerError(err)
 		}
 	}
 
-	history := &models.SearchHistory{
-		UserID:     req.UserID,
-		Keyword:    req.Keyword,
-		SearchTime: time.Now().Unix(),
+	// 翻页不重复计数
+	if req.Page == 1 {
+		recordSearchHistory(req.UserID, req.Keyword)
 	}
 
-	db.Create(history)
-
-	resp.Products = userProduct
+	resp.Products = products
+	resp.Total = result.Total
+	resp.HasMore = int64(req.Page*req.Size) < result.Total
 	resp.Page = req.Page
 	resp.Size = req.Size
 	return resp, nil
 }
 
-func buildSearchReq(req *request.SearchProductReq) map[string]interface{} {
+// sortableFields 允许排序的字段，请求中的字段 -> es字段
+var sortableFields = map[string]string{
+	"price":      "price",
+	"createdAt":  "created_at",
+	"reputation": "seller_reputation",
+}
+
+var (
+	errInvalidSortField    = errors.New("invalid sort field")
+	errInvalidSearchFilter = errors.New("invalid search filter")
+	errEmptyKeyword        = errors.New("empty keyword")
+)
+
+func buildSearchReq(req *request.SearchProductReq) (map[string]interface{}, exceptions.APIError) {
+	return buildRankedSearchReq(req, rankingWeights())
+}
+
+// buildRankedSearchReq 按相关性排序时使用weights对文本相关性加权
+func buildRankedSearchReq(req *request.SearchProductReq, weights ranking.Weights) (map[string]interface{}, exceptions.APIError) {
 	mustQueries := []map[string]interface{}{}
 
 	if req.Keyword != "" {
@@ -119,48 +99,350 @@ func buildSearchReq(req *request.SearchProductReq) map[string]interface{} {
 		})
 	}
 
-	query := map[string]interface{}{
-		"from": (req.Page - 1) * req.Size,
-		"size": req.Size,
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": mustQueries,
-			},
+	filters, apiErr := buildSearchFilters(req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	boolQuery := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   mustQueries,
+			"filter": filters,
 		},
 	}
 
+	query := map[string]interface{}{
+		"from":  (req.Page - 1) * req.Size,
+		"size":  req.Size,
+		"query": boolQuery,
+	}
+
+	// 附近搜索默认按距离排序，指定relevance时改为距离衰减参与打分
+	if req.Sort.Field == relevanceSortField || (req.Sort.Field == "" && req.Nearby == nil) {
+		var origin *ranking.Origin
+		if req.Nearby != nil {
+			origin = &ranking.Origin{
+				Lat:   *req.Nearby.Latitude,
+				Lon:   *req.Nearby.Longitude,
+				Scale: req.Nearby.Distance / 2,
+			}
+		}
+
+		query["query"] = ranking.FunctionScore(boolQuery, weights, origin)
+		return query, nil
+	}
+
+	if req.Nearby != nil && (req.Sort.Field == "" || req.Sort.Field == distanceSortField) {
+		query["sort"] = []map[string]interface{}{
+			distanceSort(req.Nearby, req.Sort.Desc),
+		}
+		return query, nil
+	}
+
+	if req.Sort.Field == distanceSortField {
+		return nil, exceptions.BadRequestError(fmt.Errorf("%w: distance requires nearby", errInvalidSortField), exceptions.InvalidSortFieldError)
+	}
+
 	if req.Sort.Field != "" {
+		field, ok := sortableFields[req.Sort.Field]
+		if !ok {
+			return nil, exceptions.BadRequestError(fmt.Errorf("%w: %s", errInvalidSortField, req.Sort.Field), exceptions.InvalidSortFieldError)
+		}
+
 		order := "asc"
 		if req.Sort.Desc {
 			order = "desc"
 		}
 		query["sort"] = []map[string]interface{}{
 			{
-				req.Sort.Field: map[string]interface{}{
+				field: map[string]interface{}{
 					"order": order,
 				},
 			},
 		}
 	}
 
-	return query
+	return query, nil
 }
 
-func GetSearchHistory(req *request.GetSearchHistoryReq) (response.SearchHistoryResp, exceptions.APIError) {
-	var resp response.SearchHistoryResp
+func buildSearchFilters(req *request.SearchProductReq) ([]map[string]interface{}, exceptions.APIError) {
+	filters := []map[string]interface{}{
+		{"term": map[string]interface{}{"is_published": true}},
+	}
+	if !req.IncludeUnavailable {
+		filters = append(filters,
+			map[string]interface{}{"term": map[string]interface{}{"is_selling": true}},
+			map[string]interface{}{"term": map[string]interface{}{"is_sold": false}},
+		)
+	}
+
+	invalid := func(format string, args ...any) exceptions.APIError {
+		err := fmt.Errorf("%w: %s", errInvalidSearchFilter, fmt.Sprintf(format, args...))
+		return exceptions.BadRequestError(err, exceptions.InvalidSearchFilterError)
+	}
+
+	if len(req.Categories) > 0 {
+		categoryIDs, err := expandCategories(req.Categories)
+		if err != nil {
+			return nil, exceptions.InternalServerError(err)
+		}
+
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{
+				"category_ids": categoryIDs,
+			},
+		})
+	}
+
+	if req.MinPrice != nil && req.MaxPrice != nil && *req.MinPrice > *req.MaxPrice {
+		return nil, invalid("minPrice greater than maxPrice")
+	}
+
+	if req.MinPrice != nil || req.MaxPrice != nil {
+		priceRange := map[string]interface{}{}
+		if req.MinPrice != nil {
+			priceRange["gte"] = *req.MinPrice
+		}
+		if req.MaxPrice != nil {
+			priceRange["lte"] = *req.MaxPrice
+		}
+
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{
+				"price": priceRange,
+			},
+		})
+	}
+
+	if len(req.Conditions) > 0 {
+		conditions := make([]string, 0, len(req.Conditions))
+		for _, code := range req.Conditions {
+			condition, ok := productConditions[code]
+			if !ok {
+				return nil, invalid("unknown condition %s", code)
+			}
+			conditions = append(conditions, condition)
+		}
+
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{
+				"condition": conditions,
+			},
+		})
+	}
+
+	if len(req.ShippingMethods) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{
+				"shipping_method": req.ShippingMethods,
+			},
+		})
+	}
+
+	if len(req.Cities) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{
+				"city": req.Cities,
+			},
+		})
+	}
+
+	if len(req.Districts) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{
+				"district": req.Districts,
+			},
+		})
+	}
 
-	history, err := db.GetAll[models.SearchHistory](
-		db.Equal("user_id", req.UserID),
-		db.OrderBy("search_time", true),
-		db.GreaterThan("search_time", time.Now().AddDate(0, -1, 0).Unix()),
-		db.Page(1, 20),
-		db.Distinct("keyword"),
+	if req.Nearby != nil {
+		filters = append(filters, distanceFilter(req.Nearby))
+	}
+
+	if req.MinReputation > 0 {
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{
+				"seller_reputation": map[string]interface{}{
+					"gte": req.MinReputation,
+				},
+			},
+		})
+	}
+
+	if len(req.Attributes) == 0 {
+		return filters, nil
+	}
+
+	attributeIDs := make([]uint, 0, len(req.Attributes))
+	for _, attribute := range req.Attributes {
+		attributeIDs = append(attributeIDs, attribute.AttributeID)
+	}
+
+	templates, err := db.GetAll[models.AttributeTemplate](
+		db.InArray("id", attributeIDs),
 	)
+	if err != nil {
+		return nil, exceptions.InternalServerError(err)
+	}
+
+	templateMap := make(map[uint]models.AttributeTemplate, len(templates))
+	for _, template := range templates {
+		templateMap[template.ID] = template
+	}
+
+	for _, attribute := range req.Attributes {
+		template, ok := templateMap[attribute.AttributeID]
+		if !ok {
+			return nil, invalid("attribute %d not found", attribute.AttributeID)
+		}
+
+		condition, err := buildAttributeCondition(template, attribute)
+		if err != nil {
+			return nil, invalid("attribute %s: %v", template.Name, err)
+		}
+
+		filters = append(filters, map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path": "attributes",
+				"query": map[string]interface{}{
+					"bool": map[string]interface{}{
+						"filter": []map[string]interface{}{
+							{
+								"term": map[string]interface{}{
+									"attributes.attribute_id": template.ID,
+								},
+							},
+							condition,
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return filters, nil
+}
+
+// buildAttributeCondition 根据属性的DataType生成对嵌套字段的过滤条件
+func buildAttributeCondition(template models.AttributeTemplate, filter request.AttributeFilter) (map[string]interface{}, error) {
+	switch template.DataType {
+	case models.DataTypeNumber:
+		numberRange := map[string]interface{}{}
+		for op, value := range map[string]string{"gte": filter.Min, "lte": filter.Max} {
+			if value == "" {
+				continue
+			}
+
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s", value)
+			}
+			numberRange[op] = number
+		}
+
+		if len(numberRange) == 0 {
+			return nil, errors.New("min or max is required")
+		}
+
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				"attributes.number": numberRange,
+			},
+		}, nil
+	case models.DataTypeDate:
+		dateRange := map[string]interface{}{}
+		for op, value := range map[string]string{"gte": filter.Min, "lte": filter.Max} {
+			if value == "" {
+				continue
+			}
+
+			date, err := parseAttributeDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %s", value)
+			}
+			dateRange[op] = date.Format(time.RFC3339)
+		}
+
+		if len(dateRange) == 0 {
+			return nil, errors.New("min or max is required")
+		}
+
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				"attributes.date": dateRange,
+			},
+		}, nil
+	case models.DataTypeBoolean:
+		b, err := strconv.ParseBool(filter.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %s", filter.Value)
+		}
+
+		return map[string]interface{}{
+			"term": map[string]interface{}{
+				"attributes.bool": b,
+			},
+		}, nil
+	case models.DataTypeEnum:
+		values := filter.Values
+		if len(values) == 0 && filter.Value != "" {
+			values = []string{filter.Value}
+		}
+
+		if len(values) == 0 {
+			return nil, errors.New("values is required")
+		}
 
+		for _, value := range values {
+			if !slices.Contains(template.Options, value) {
+				return nil, fmt.Errorf("invalid option %s", value)
+			}
+		}
+
+		return map[string]interface{}{
+			"terms": map[string]interface{}{
+				"attributes.value": values,
+			},
+		}, nil
+	default:
+		if filter.Value == "" {
+			return nil, errors.New("value is required")
+		}
+
+		return map[string]interface{}{
+			"term": map[string]interface{}{
+				"attributes.value": filter.Value,
+			},
+		}, nil
+	}
+}
+
+// expandCategories 返回所选分类及其所有子分类的id
+func expandCategories(categoryIDs []uint) ([]uint, error) {
+	categories, err := loadCategories()
 	if err != nil {
-		return resp, exceptions.InternalServerError(err)
+		return nil, err
 	}
 
-	resp.History = history
-	return resp, nil
+	children := make(map[uint][]uint, len(categories))
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category.ID)
+	}
+
+	visited := make(map[uint]bool, len(categoryIDs))
+	result := make([]uint, 0, len(categoryIDs))
+	queue := append([]uint{}, categoryIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+
+		visited[id] = true
+		result = append(result, id)
+		queue = append(queue, children[id]...)
+	}
+
+	return result, nil
 }
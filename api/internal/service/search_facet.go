@@ -0,0 +1,303 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+const (
+	defaultPriceInterval = 100
+	facetSize            = 20
+	categoryFacetSize    = 500
+)
+
+type facetBucket struct {
+	Key      interface{} `json:"key"`
+	DocCount int64       `json:"doc_count"`
+}
+
+type termsAggregation struct {
+	Buckets []facetBucket `json:"buckets"`
+}
+
+type attributeAggregation struct {
+	Bound struct {
+		IDs struct {
+			Buckets []struct {
+				Key    uint             `json:"key"`
+				Values termsAggregation `json:"values"`
+			} `json:"buckets"`
+		} `json:"ids"`
+	} `json:"bound"`
+}
+
+// boundAttributes 返回所选分类绑定的属性，包括祖先分类中可继承的属性
+func boundAttributes(categoryIDs []uint) ([]models.AttributeTemplate, error) {
+	if len(categoryIDs) == 0 {
+		return nil, nil
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[uint]bool, len(categoryIDs))
+	ancestors := make([]uint, 0)
+	for _, id := range categoryIDs {
+		selected[id] = true
+		ancestors = append(ancestors, categoryAncestors(categories, id)...)
+	}
+
+	bindings, err := db.GetAll[models.CategoryAttribute](
+		db.InArray("category_id", ancestors),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attributeIDs := make([]uint, 0, len(bindings))
+	for _, binding := range bindings {
+		if selected[binding.CategoryID] || binding.IsInherited {
+			attributeIDs = append(attributeIDs, binding.AttributeID)
+		}
+	}
+
+	if len(attributeIDs) == 0 {
+		return nil, nil
+	}
+
+	return db.GetAll[models.AttributeTemplate](
+		db.InArray("id", attributeIDs),
+	)
+}
+
+func buildFacetAggregations(attributes []models.AttributeTemplate, priceInterval float64) map[string]interface{} {
+	if priceInterval <= 0 {
+		priceInterval = defaultPriceInterval
+	}
+
+	aggs := map[string]interface{}{
+		"categories": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": "category_path",
+				"size":  categoryFacetSize,
+			},
+		},
+		"conditions": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": "condition",
+				"size":  facetSize,
+			},
+		},
+		"cities": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": "city",
+				"size":  facetSize,
+			},
+		},
+		"districts": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": "district",
+				"size":  facetSize,
+			},
+		},
+		"price": map[string]interface{}{
+			"histogram": map[string]interface{}{
+				"field":         "price",
+				"interval":      priceInterval,
+				"min_doc_count": 1,
+			},
+		},
+	}
+
+	if len(attributes) == 0 {
+		return aggs
+	}
+
+	attributeIDs := make([]uint, 0, len(attributes))
+	for _, attribute := range attributes {
+		attributeIDs = append(attributeIDs, attribute.ID)
+	}
+
+	aggs["attributes"] = map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path": "attributes",
+		},
+		"aggs": map[string]interface{}{
+			"bound": map[string]interface{}{
+				"filter": map[string]interface{}{
+					"terms": map[string]interface{}{
+						"attributes.attribute_id": attributeIDs,
+					},
+				},
+				"aggs": map[string]interface{}{
+					"ids": map[string]interface{}{
+						"terms": map[string]interface{}{
+							"field": "attributes.attribute_id",
+							"size":  len(attributeIDs),
+						},
+						"aggs": map[string]interface{}{
+							"values": map[string]interface{}{
+								"terms": map[string]interface{}{
+									"field": "attributes.value",
+									"size":  facetSize,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return aggs
+}
+
+func parseFacets(aggregations map[string]json.RawMessage, attributes []models.AttributeTemplate, priceInterval float64) (response.SearchFacets, error) {
+	var facets response.SearchFacets
+	if priceInterval <= 0 {
+		priceInterval = defaultPriceInterval
+	}
+
+	var categoryAgg, conditionAgg, cityAgg, districtAgg, priceAgg termsAggregation
+	for name, target := range map[string]*termsAggregation{
+		"categories": &categoryAgg,
+		"conditions": &conditionAgg,
+		"cities":     &cityAgg,
+		"districts":  &districtAgg,
+		"price":      &priceAgg,
+	} {
+		raw, ok := aggregations[name]
+		if !ok {
+			continue
+		}
+
+		if err := json.Unmarshal(raw, target); err != nil {
+			return facets, err
+		}
+	}
+
+	categoryFacets, err := buildCategoryFacets(categoryAgg)
+	if err != nil {
+		return facets, err
+	}
+
+	facets.Categories = categoryFacets
+	facets.Conditions = toFacetBuckets(conditionAgg)
+	facets.Cities = toFacetBuckets(cityAgg)
+	facets.Districts = toFacetBuckets(districtAgg)
+
+	facets.Price = make([]response.PriceBucket, 0, len(priceAgg.Buckets))
+	for _, bucket := range priceAgg.Buckets {
+		from, _ := bucket.Key.(float64)
+		facets.Price = append(facets.Price, response.PriceBucket{
+			From:  from,
+			To:    from + priceInterval,
+			Count: bucket.DocCount,
+		})
+	}
+
+	facets.Attributes = make([]response.AttributeFacet, 0, len(attributes))
+	raw, ok := aggregations["attributes"]
+	if !ok {
+		return facets, nil
+	}
+
+	var attributeAgg attributeAggregation
+	if err := json.Unmarshal(raw, &attributeAgg); err != nil {
+		return facets, err
+	}
+
+	values := make(map[uint][]response.FacetBucket)
+	for _, bucket := range attributeAgg.Bound.IDs.Buckets {
+		values[bucket.Key] = toFacetBuckets(bucket.Values)
+	}
+
+	for _, attribute := range attributes {
+		facets.Attributes = append(facets.Attributes, response.AttributeFacet{
+			AttributeID: attribute.ID,
+			Name:        attribute.Name,
+			DataType:    string(attribute.DataType),
+			Unit:        attribute.Unit,
+			Values:      values[attribute.ID],
+		})
+	}
+
+	return facets, nil
+}
+
+func toFacetBuckets(agg termsAggregation) []response.FacetBucket {
+	buckets := make([]response.FacetBucket, 0, len(agg.Buckets))
+	for _, bucket := range agg.Buckets {
+		buckets = append(buckets, response.FacetBucket{
+			Value: fmt.Sprint(bucket.Key),
+			Count: bucket.DocCount,
+		})
+	}
+
+	return buckets
+}
+
+// buildCategoryFacets 将category_path的统计结果组装为分类树，只保留有商品的节点
+func buildCategoryFacets(agg termsAggregation) ([]*response.CategoryFacet, error) {
+	if len(agg.Buckets) == 0 {
+		return []*response.CategoryFacet{}, nil
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*response.CategoryFacet, len(agg.Buckets))
+	for _, bucket := range agg.Buckets {
+		id, ok := bucket.Key.(float64)
+		if !ok {
+			continue
+		}
+
+		category, ok := categories[uint(id)]
+		if !ok {
+			continue
+		}
+
+		nodes[category.ID] = &response.CategoryFacet{
+			ID:       category.ID,
+			Name:     category.TypeName,
+			Count:    bucket.DocCount,
+			Children: []*response.CategoryFacet{},
+		}
+	}
+
+	roots := []*response.CategoryFacet{}
+	for id, node := range nodes {
+		parent, ok := nodes[categories[id].ParentID]
+		if ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	sortCategoryFacets(roots)
+	return roots, nil
+}
+
+func sortCategoryFacets(facets []*response.CategoryFacet) {
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].ID < facets[j].ID
+	})
+
+	for _, facet := range facets {
+		sortCategoryFacets(facet.Children)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+)
+
+const (
+	distanceSortField     = "distance"
+	defaultNearbyDistance = 10.0 // 公里
+	earthRadiusKilometers = 6371.0
+	distanceDecimalPlaces = 100.0
+)
+
+var errNearbyOriginMissing = errors.New("nearby search requires a location or a default address")
+
+// resolveNearbyOrigin 未提供坐标时使用用户默认收货地址的坐标
+func resolveNearbyOrigin(userID string, nearby *request.NearbyOption) exceptions.APIError {
+	if nearby.Distance == 0 {
+		nearby.Distance = defaultNearbyDistance
+	}
+
+	if nearby.Latitude != nil && nearby.Longitude != nil {
+		return nil
+	}
+
+	missing := exceptions.BadRequestError(errNearbyOriginMissing, exceptions.NearbyLocationRequiredError)
+	if len(userID) == 0 {
+		return missing
+	}
+
+	userAddress, err := db.GetOne[models.UserAddress](
+		db.Equal("user_id", userID),
+		db.Equal("is_default", true),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+	if !userAddress.Exists() {
+		return missing
+	}
+
+	address, err := getProductAddress(userAddress.AddressID)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+	if address.Latitude == 0 && address.Longitude == 0 {
+		return missing
+	}
+
+	nearby.Latitude = &address.Latitude
+	nearby.Longitude = &address.Longitude
+	return nil
+}
+
+func distanceFilter(nearby *request.NearbyOption) map[string]interface{} {
+	return map[string]interface{}{
+		"geo_distance": map[string]interface{}{
+			"distance": fmt.Sprintf("%gkm", nearby.Distance),
+			"geo": map[string]interface{}{
+				"lat": *nearby.Latitude,
+				"lon": *nearby.Longitude,
+			},
+		},
+	}
+}
+
+func distanceSort(nearby *request.NearbyOption, desc bool) map[string]interface{} {
+	order := "asc"
+	if desc {
+		order = "desc"
+	}
+
+	return map[string]interface{}{
+		"_geo_distance": map[string]interface{}{
+			"geo": map[string]interface{}{
+				"lat": *nearby.Latitude,
+				"lon": *nearby.Longitude,
+			},
+			"order":         order,
+			"unit":          "km",
+			"distance_type": "arc",
+		},
+	}
+}
+
+// fillDistances 按文档坐标计算与搜索位置的距离，hits与products一一对应
+func fillDistances(products []response.UserProduct, hits []json.RawMessage, nearby *request.NearbyOption) error {
+	for i, hit := range hits {
+		var document struct {
+			Geo *request.GeoPoint `json:"geo"`
+		}
+		if err := json.Unmarshal(hit, &document); err != nil {
+			return err
+		}
+		if document.Geo == nil {
+			continue
+		}
+
+		distance := haversine(*nearby.Latitude, *nearby.Longitude, document.Geo.Lat, document.Geo.Lon)
+		distance = math.Round(distance*distanceDecimalPlaces) / distanceDecimalPlaces
+		products[i].Distance = &distance
+	}
+
+	return nil
+}
+
+// haversine 两点间的球面距离，公里
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degree float64) float64 {
+		return degree * math.Pi / 180
+	}
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKilometers * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+	"github.com/mislu/market-api/internal/utils/app"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHistoryRetentionDays = 90
+	defaultHistoryLimit         = 100
+	recentHistoryDays           = 30
+	recentHistorySize           = 20
+	historyQueueSize            = 1024
+	historyPurgeInterval        = time.Hour
+	maxKeywordLength            = 255
+)
+
+type searchRecord struct {
+	userID  string
+	keyword string
+	at      time.Time
+}
+
+// historyQueue 搜索历史异步写入，队列满时丢弃，不影响搜索延迟
+var historyQueue = make(chan searchRecord, historyQueueSize)
+
+// normalizeKeyword 去除首尾空白、合并连续空白、全角转半角并转小写
+func normalizeKeyword(keyword string) string {
+	folded := strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xfee0
+		}
+		return unicode.ToLower(r)
+	}, keyword)
+
+	return strings.Join(strings.Fields(folded), " ")
+}
+
+func recordSearchHistory(userID string, keyword string) {
+	if len(userID) == 0 || len(normalizeKeyword(keyword)) == 0 {
+		return
+	}
+
+	select {
+	case historyQueue <- searchRecord{userID: userID, keyword: strings.TrimSpace(keyword), at: time.Now()}:
+	default:
+		log.Printf("search history queue is full, dropping keyword of user %s", userID)
+	}
+}
+
+// StartSearchHistoryWorker 消费搜索记录并定期清理过期的搜索历史
+func StartSearchHistoryWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(historyPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case record := <-historyQueue:
+				if err := saveSearchHistory(record); err != nil {
+					log.Printf("failed to save search history of user %s: %v", record.userID, err)
+				}
+			case <-ticker.C:
+				if err := purgeSearchHistory(); err != nil {
+					log.Printf("failed to purge search history: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func saveSearchHistory(record searchRecord) error {
+	user, err := db.GetOne[models.User](
+		db.Fields("id", "record_search_history"),
+		db.Equal("id", record.userID),
+	)
+	if err != nil {
+		return err
+	}
+	if !user.Exists() || !user.RecordSearchHistory {
+		return nil
+	}
+
+	keyword := []rune(record.keyword)
+	if len(keyword) > maxKeywordLength {
+		keyword = keyword[:maxKeywordLength]
+	}
+	normalized := []rune(normalizeKeyword(string(keyword)))
+	if len(normalized) > maxKeywordLength {
+		normalized = normalized[:maxKeywordLength]
+	}
+
+	history, err := db.GetOne[models.SearchHistory](
+		db.Equal("user_id", record.userID),
+		db.Equal("normalized", string(normalized)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if history.ID == 0 {
+		err = db.Create(&models.SearchHistory{
+			UserID:     record.userID,
+			Keyword:    string(keyword),
+			Normalized: string(normalized),
+			HitCount:   1,
+			SearchTime: record.at.Unix(),
+		})
+		if err == nil {
+			return nil
+		}
+		// 其他实例并发插入了同一关键词，转为累加
+	}
+
+	return db.Run(
+		db.Model(&models.SearchHistory{}),
+		db.Equal("user_id", record.userID),
+		db.Equal("normalized", string(normalized)),
+		db.Set(map[string]any{
+			"keyword":     string(keyword),
+			"hit_count":   gorm.Expr("hit_count + 1"),
+			"search_time": record.at.Unix(),
+		}),
+	)
+}
+
+// purgeSearchHistory 删除超过保留期的记录，并只保留每个用户最近的HistoryLimit个关键词
+func purgeSearchHistory() error {
+	config := app.GetConfig().Search
+	retentionDays := config.HistoryRetentionDays
+	if retentionDays <= 0 {
+		retentionDays = defaultHistoryRetentionDays
+	}
+	limit := config.HistoryLimit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	if err := db.DeleteByQuery[models.SearchHistory](
+		db.WhereSQL("search_time < ?", time.Now().AddDate(0, 0, -retentionDays).Unix()),
+	); err != nil {
+		return err
+	}
+
+	overflows, err := db.GetAny[[]string](
+		"SELECT user_id FROM search_history GROUP BY user_id HAVING COUNT(*) > ?", limit,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range overflows {
+		kept, err := db.GetAll[models.SearchHistory](
+			db.Fields("id"),
+			db.Equal("user_id", userID),
+			db.OrderBy("search_time", true),
+			db.Page(1, limit),
+		)
+		if err != nil {
+			return err
+		}
+
+		keptIDs := make([]int, 0, len(kept))
+		for _, history := range kept {
+			keptIDs = append(keptIDs, history.ID)
+		}
+
+		if err := db.DeleteByQuery[models.SearchHistory](
+			db.Equal("user_id", userID),
+			db.WhereSQL("id NOT IN ?", keptIDs),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetSearchHistory(req *request.GetSearchHistoryReq) (response.SearchHistoryResp, exceptions.APIError) {
+	resp := response.SearchHistoryResp{
+		History: []models.SearchHistory{},
+	}
+
+	user, err := db.GetOne[models.User](
+		db.Fields("id", "record_search_history"),
+		db.Equal("id", req.UserID),
+	)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+	resp.Enabled = user.RecordSearchHistory
+
+	query := []db.GenericQuery{
+		db.Equal("user_id", req.UserID),
+		db.OrderBy("search_time", true),
+	}
+	if !req.ShowAll {
+		query = append(query,
+			db.GreaterThan("search_time", time.Now().AddDate(0, 0, -recentHistoryDays).Unix()),
+			db.Page(1, recentHistorySize),
+		)
+	}
+
+	history, err := db.GetAll[models.SearchHistory](query...)
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+
+	resp.History = history
+	return resp, nil
+}
+
+func DeleteSearchHistory(req *request.DeleteSearchHistoryReq) exceptions.APIError {
+	// 条件结构体会忽略零值字段，空关键词会删除用户的全部历史
+	normalized := normalizeKeyword(req.Keyword)
+	if len(normalized) == 0 {
+		return exceptions.BadRequestError(errEmptyKeyword, exceptions.EmptyKeywordError)
+	}
+
+	err := db.DeleteByQuery[models.SearchHistory](
+		db.Equal("user_id", req.UserID),
+		db.Equal("normalized", normalized),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+func ClearSearchHistory(userID string) exceptions.APIError {
+	if err := db.DeleteByCondition(models.SearchHistory{UserID: userID}); err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
+
+// UpdateSearchHistorySetting 开启或关闭搜索历史记录，关闭时不删除已有记录
+func UpdateSearchHistorySetting(req *request.UpdateSearchHistorySettingReq) exceptions.APIError {
+	err := db.Run(
+		db.Model(&models.User{}),
+		db.Equal("id", req.UserID),
+		db.Set(map[string]any{"record_search_history": *req.Enabled}),
+	)
+	if err != nil {
+		return exceptions.InternalServerError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mislu/market-api/internal/core/ranking"
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/utils/app"
+	"gorm.io/gorm"
+)
+
+const (
+	relevanceSortField    = "relevance"
+	viewFlushInterval     = time.Minute
+	maxPendingViewProduct = 10000
+)
+
+// rankingWeights 默认权重被配置中出现的项覆盖
+func rankingWeights() ranking.Weights {
+	config := app.GetConfig().Ranking
+	weights := ranking.DefaultWeights()
+
+	for _, item := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{config.Freshness, &weights.Freshness},
+		{config.Reputation, &weights.Reputation},
+		{config.Likes, &weights.Likes},
+		{config.Views, &weights.Views},
+		{config.Discount, &weights.Discount},
+		{config.Distance, &weights.Distance},
+	} {
+		if item.value != nil {
+			*item.target = *item.value
+		}
+	}
+	if len(config.FreshnessScale) > 0 {
+		weights.FreshnessScale = config.FreshnessScale
+	}
+
+	return weights
+}
+
+// RankProducts 使用指定权重按相关性搜索关键词，返回前size个商品id，用于离线评估
+func RankProducts(keyword string, weights ranking.Weights, size int) ([]string, error) {
+	req := &request.SearchProductReq{
+		Keyword: keyword,
+		PageReq: request.PageReq{Page: 1, Size: size},
+	}
+
+	query, apiErr := buildRankedSearchReq(req, weights)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	query["_source"] = []string{"id"}
+
+	result, err := es.SearchWithAggregations(es.ProductIndex, query)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := decodeProductDocuments(result.Hits)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.Product.ID)
+	}
+
+	return ids, nil
+}
+
+// LoggedQueries 近期搜索次数最多的关键词，按次数降序
+func LoggedQueries(since time.Time, limit int) ([]string, error) {
+	rows, err := db.GetAny[[]struct {
+		Normalized string
+		Hits       int64
+	}](
+		"SELECT normalized, SUM(hit_count) AS hits FROM search_history WHERE search_time > ? GROUP BY normalized ORDER BY hits DESC LIMIT ?",
+		since.Unix(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := make([]string, 0, len(rows))
+	for _, row := range rows {
+		queries = append(queries, row.Normalized)
+	}
+
+	return queries, nil
+}
+
+// viewCounter 商品详情的浏览数先在内存中累加，定时批量写回，避免每次浏览都重建索引
+var viewCounter = struct {
+	sync.Mutex
+	pending map[string]int64
+}{pending: make(map[string]int64)}
+
+func recordProductView(productID string) {
+	viewCounter.Lock()
+	defer viewCounter.Unlock()
+
+	if _, ok := viewCounter.pending[productID]; !ok && len(viewCounter.pending) >= maxPendingViewProduct {
+		return
+	}
+	viewCounter.pending[productID]++
+}
+
+func flushProductViews() {
+	viewCounter.Lock()
+	pending := viewCounter.pending
+	viewCounter.pending = make(map[string]int64)
+	viewCounter.Unlock()
+
+	updated := make([]string, 0, len(pending))
+	for productID, count := range pending {
+		err := db.Run(
+			db.Model(&models.Product{}),
+			db.Equal("id", productID),
+			db.Set(map[string]any{"view_count": gorm.Expr("view_count + ?", count)}),
+		)
+		if err != nil {
+			log.Printf("failed to update view count of product %s: %v", productID, err)
+			continue
+		}
+		updated = append(updated, productID)
+	}
+	if len(updated) == 0 {
+		return
+	}
+
+	// 只局部更新es中的浏览数，不重建整个文档
+	products, err := db.GetAll[models.Product](
+		db.Fields("id", "view_count"),
+		db.InArray("id", updated),
+	)
+	if err != nil {
+		log.Printf("failed to load view counts: %v", err)
+		return
+	}
+
+	for _, product := range products {
+		err := es.UpdateDocument(es.ProductIndex, product.ID, map[string]interface{}{
+			"view_count": product.ViewCount,
+		})
+		if err != nil {
+			log.Printf("failed to sync view count of product %s: %v", product.ID, err)
+		}
+	}
+}
+
+// StartViewCounter 定时把浏览数写回MySQL并同步到es
+func StartViewCounter(ctx context.Context) {
+	go runPeriodically(ctx, viewFlushInterval, flushProductViews)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mislu/market-api/internal/db"
+	"github.com/mislu/market-api/internal/es"
+	"github.com/mislu/market-api/internal/types/exceptions"
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+	"github.com/mislu/market-api/internal/types/response"
+	"github.com/mislu/market-api/internal/utils/app"
+)
+
+const (
+	defaultSuggestSize      = 10
+	trendingWindow          = 7 * 24 * time.Hour
+	trendingTTL             = 10 * time.Minute
+	trendingLimit           = 100
+	defaultTrendingMinUsers = 5
+)
+
+// trendingCache 热门搜索按时间窗口聚合，定期刷新，避免每次输入都扫描搜索历史
+var trendingCache struct {
+	sync.Mutex
+	keywords  []string
+	expiresAt time.Time
+}
+
+func SuggestSearch(req *request.SuggestReq) (response.SuggestResp, exceptions.APIError) {
+	resp := response.SuggestResp{
+		Completions: []string{},
+		History:     []string{},
+		Trending:    []string{},
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = defaultSuggestSize
+	}
+	keyword := strings.TrimSpace(req.Keyword)
+
+	if len(keyword) > 0 {
+		completions, didYouMean, err := suggestFromIndex(keyword, size)
+		if err != nil {
+			return resp, exceptions.InternalServerError(err)
+		}
+		resp.Completions = completions
+		resp.DidYouMean = didYouMean
+	}
+
+	if len(req.UserID) > 0 {
+		history, err := suggestFromHistory(req.UserID, keyword, size)
+		if err != nil {
+			return resp, exceptions.InternalServerError(err)
+		}
+		resp.History = history
+	}
+
+	trending, err := trendingKeywords()
+	if err != nil {
+		return resp, exceptions.InternalServerError(err)
+	}
+	for _, trend := range trending {
+		if len(resp.Trending) >= size {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(trend), strings.ToLower(keyword)) {
+			resp.Trending = append(resp.Trending, trend)
+		}
+	}
+
+	return resp, nil
+}
+
+// suggestFromIndex 合并汉字和拼音的补全结果，并给出短语纠错
+func suggestFromIndex(keyword string, size int) ([]string, string, error) {
+	suggest := map[string]interface{}{
+		"completion": map[string]interface{}{
+			"prefix": keyword,
+			"completion": map[string]interface{}{
+				"field":           "suggest",
+				"size":            size,
+				"skip_duplicates": true,
+			},
+		},
+		"did_you_mean": map[string]interface{}{
+			"text": keyword,
+			"phrase": map[string]interface{}{
+				"field":      "describe.shingle",
+				"size":       1,
+				"gram_size":  3,
+				"max_errors": 2,
+				"direct_generator": []map[string]interface{}{
+					{
+						"field":        "describe.shingle",
+						"suggest_mode": "always",
+					},
+				},
+			},
+		},
+	}
+
+	if es.PinyinEnabled() {
+		suggest["pinyin"] = map[string]interface{}{
+			"prefix": keyword,
+			"completion": map[string]interface{}{
+				"field":           "suggest.pinyin",
+				"size":            size,
+				"skip_duplicates": true,
+			},
+		}
+	}
+
+	result, err := es.Suggest(es.ProductIndex, suggest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	completions := []string{}
+	seen := make(map[string]bool)
+	for _, name := range []string{"completion", "pinyin"} {
+		options, err := parseSuggestOptions(result[name])
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, option := range options {
+			if len(completions) >= size {
+				break
+			}
+			if !seen[option] {
+				seen[option] = true
+				completions = append(completions, option)
+			}
+		}
+	}
+
+	corrections, err := parseSuggestOptions(result["did_you_mean"])
+	if err != nil {
+		return nil, "", err
+	}
+
+	didYouMean := ""
+	if len(corrections) > 0 && corrections[0] != keyword {
+		didYouMean = corrections[0]
+	}
+
+	return completions, didYouMean, nil
+}
+
+func parseSuggestOptions(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var entries []struct {
+		Options []struct {
+			Text string `json:"text"`
+		} `json:"options"`
+	}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+
+	options := []string{}
+	for _, entry := range entries {
+		for _, option := range entry.Options {
+			options = append(options, option.Text)
+		}
+	}
+
+	return options, nil
+}
+
+// suggestFromHistory 用户搜索过的以keyword开头的关键词，最近的在前
+func suggestFromHistory(userID string, keyword string, size int) ([]string, error) {
+	query := []db.GenericQuery{
+		db.Equal("user_id", userID),
+		db.OrderBy("search_time", true),
+		db.Page(1, size),
+	}
+	if normalized := normalizeKeyword(keyword); len(normalized) > 0 {
+		query = append(query, db.WhereSQL("normalized LIKE ?", escapeLike(normalized)+"%"))
+	}
+
+	history, err := db.GetAll[models.SearchHistory](query...)
+	if err != nil {
+		return nil, err
+	}
+
+	keywords := make([]string, 0, len(history))
+	for _, item := range history {
+		keywords = append(keywords, item.Keyword)
+	}
+
+	return keywords, nil
+}
+
+// trendingKeywords 近期搜索人数最多的关键词，只统计足够多用户搜索过的词，避免个人搜索的隐私内容(如手机号)出现在热门中
+func trendingKeywords() ([]string, error) {
+	trendingCache.Lock()
+	defer trendingCache.Unlock()
+
+	if time.Now().Before(trendingCache.expiresAt) {
+		return trendingCache.keywords, nil
+	}
+
+	minUsers := app.GetConfig().Search.TrendingMinUsers
+	if minUsers <= 0 {
+		minUsers = defaultTrendingMinUsers
+	}
+
+	rows, err := db.GetAny[[]struct {
+		Keyword string
+		Users   int64
+	}](
+		"SELECT MAX(keyword) AS keyword, COUNT(DISTINCT user_id) AS users FROM search_history WHERE search_time > ? "+
+			"GROUP BY normalized HAVING COUNT(DISTINCT user_id) >= ? ORDER BY users DESC LIMIT ?",
+		time.Now().Add(-trendingWindow).Unix(), minUsers, trendingLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	keywords := make([]string, 0, len(rows))
+	for _, row := range rows {
+		keywords = append(keywords, row.Keyword)
+	}
+
+	trendingCache.keywords = keywords
+	trendingCache.expiresAt = time.Now().Add(trendingTTL)
+	return keywords, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
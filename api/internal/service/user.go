@@ -1,12 +1,12 @@
 package service
 
 import (
-	"context"
 	"errors"
+	"log"
 	"regexp"
+	"slices"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/mislu/market-api/internal/core/recommend"
 	resourcemanager "github.com/mislu/market-api/internal/core/resource_manager"
 	"github.com/mislu/market-api/internal/db"
@@ -20,12 +20,14 @@ import (
 
 var mobileRegex = regexp.MustCompile(`^1[3-9][0-9]{9}$`)
 var (
-	errUserNotFound = errors.New("user does not found")
+	errUserNotFound        = errors.New("user does not found")
+	errInterestTagNotFound = errors.New("interest tag not found")
 )
 
 const (
-	picMaxSize   = 10 << 20
-	videoMaxSize = 10 << 20
+	picMaxSize       = 10 << 20
+	videoMaxSize     = 50 << 20
+	videoMaxDuration = 60 * time.Second
 )
 
 func CreateUser(req *request.CreateUserReq) exceptions.APIError {
@@ -91,7 +93,7 @@ func UpdateBasic(req *request.UpdateBasicReq) exceptions.APIError {
 		user.Username = req.Username
 	}
 
-	if err := db.Update(user); err != nil {
+	if err := updateSeller(user); err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
@@ -142,7 +144,7 @@ func UploadAvatar(req *request.UploadAvatarReq) (response.UploadAvatarResp, exce
 
 	// 忽略删除错误，由定时清理任务清除多余的文件
 	resourcemanager.DeleteFile(resourcemanager.UserBucket, resourcemanager.GetObjectPath(resourcemanager.UserBucket, req.UserID, oldKey))
-	err = db.Update(user)
+	err = updateSeller(user)
 	if err != nil {
 		return resp, exceptions.InternalServerError(err)
 	}
@@ -261,79 +263,96 @@ func GetUserInfo(req *request.GetUserInfoReq) (response.GetUserInfoResp, excepti
 	return resp, nil
 }
 
+// SelectInterestTags 选择或重新选择兴趣标签，覆盖之前的选择，并同步为推荐服务的用户标签
 func SelectInterestTags(req *request.SelectInterestTagsReq) exceptions.APIError {
-	userTags := make([]models.UserInterests, 0, len(req.Tags))
-
-	feedbacks := make([]recommend.Feedback, 0, len(req.Tags))
-	for _, tag := range req.Tags {
-		userTags = append(userTags, models.UserInterests{
-			UserID:        req.UserID,
-			InterestTagID: tag,
-		})
-
-		t, err := db.GetOne[models.InterestTag](
-			db.Equal("id", tag),
+	tagIDs := slices.Clone(req.Tags)
+	slices.Sort(tagIDs)
+	tagIDs = slices.Compact(tagIDs)
+	tags := make([]models.InterestTag, 0)
+	if len(tagIDs) > 0 {
+		var err error
+		tags, err = db.GetAll[models.InterestTag](
+			db.InArray("id", tagIDs),
 		)
 		if err != nil {
 			return exceptions.InternalServerError(err)
 		}
-
-		category, err := db.GetOne[models.Category](
-			db.Equal("id", t.CategoryID),
-		)
-		if err != nil {
-			continue
-		}
-		fakeProduct := models.Product{
-			Model: models.Model{
-				ID: uuid.New().String(),
-			},
-		}
-
-		err = recommend.CreateItem(fakeProduct, []string{category.TypeName}, nil, true)
-		if err == nil {
-			feedbacks = append(feedbacks, recommend.Feedback{
-				UserId:       req.UserID,
-				ItemId:       fakeProduct.ID,
-				FeedbackType: "view",
-				Timestamp:    time.Now().Unix(),
-			})
+		if len(tags) != len(tagIDs) {
+			return exceptions.BadRequestError(errInterestTagNotFound, exceptions.InterestTagNotFoundError)
 		}
 	}
 
-	err := recommend.GlobalWorker.InsertFeedback(context.Background(), feedbacks)
+	user, err := db.GetOne[models.User](
+		db.Equal("id", req.UserID),
+	)
 	if err != nil {
-		return nil
+		return exceptions.InternalServerError(err)
+	}
+	if !user.Exists() {
+		return exceptions.BadRequestError(errUserNotFound, exceptions.UserNotExistsError)
 	}
 
-	err = db.WithTransaction(func(tx *gorm.DB) error {
-		if len(userTags) == 0 {
-			return nil
-		}
+	now := time.Now()
+	userTags := make([]models.UserInterests, 0, len(tags))
+	labels := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		userTags = append(userTags, models.UserInterests{
+			UserID:        req.UserID,
+			InterestTagID: tag.ID,
+			SelectedAt:    now,
+			UpdatedAt:     now,
+		})
+		labels = append(labels, tag.TagName)
+	}
 
-		err := db.Create(&userTags)
+	err = db.WithTransaction(func(tx *gorm.DB) error {
+		err := db.DeleteByCondition(models.UserInterests{UserID: req.UserID}, tx)
 		if err != nil {
 			return err
 		}
 
-		user, err := db.GetOne[models.User](
-			db.Equal("id", req.UserID),
-		)
-		if err != nil {
-			return err
+		if len(userTags) > 0 {
+			if err := db.Create(&userTags, tx); err != nil {
+				return err
+			}
 		}
+
 		user.SelectedTags = true
-		err = db.Update(&user)
-		return err
+		return db.Update(&user, tx)
 	})
-
 	if err != nil {
 		return exceptions.InternalServerError(err)
 	}
 
+	recommend.GlobalWorker.SyncUser(req.UserID, labels)
+	go func() {
+		if _, err := CleanFakeRecommendItems(req.UserID); err != nil {
+			log.Printf("failed to clean fake recommend items of user %s: %v", req.UserID, err)
+		}
+	}()
+
 	return nil
 }
 
+// CleanFakeRecommendItems 删除早期选择兴趣标签时为用户写入推荐服务的虚拟商品，返回删除的数量
+func CleanFakeRecommendItems(userID string) (int, error) {
+	return recommend.DeleteMissingItems(userID, recommend.FeedbackView, func(itemIDs []string) (map[string]bool, error) {
+		products, err := db.GetAll[models.Product](
+			db.Fields("id"),
+			db.InArray("id", itemIDs),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		existing := make(map[string]bool, len(products))
+		for _, product := range products {
+			existing[product.ID] = true
+		}
+		return existing, nil
+	})
+}
+
 func getUserCredit(userID string) (models.Credit, error) {
 	return db.GetOne[models.Credit](
 		db.Equal("user_id", userID),
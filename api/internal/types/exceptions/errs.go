@@ -11,11 +11,12 @@ const (
 
 	// User related errors
 
-	PhoneBoundError         = "The phone number has already been bound."
-	UserNotExistsError      = "User dose not found."
-	InvalidPhoneError       = "Invalid phone number."
-	IncorrectPasswordError  = "Password is incorrect"
-	AvatarSizeExceededError = "Avatar size exceeded 10MB."
+	PhoneBoundError          = "The phone number has already been bound."
+	UserNotExistsError       = "User dose not found."
+	InvalidPhoneError        = "Invalid phone number."
+	IncorrectPasswordError   = "Password is incorrect"
+	AvatarSizeExceededError  = "Avatar size exceeded 10MB."
+	InterestTagNotFoundError = "Interest tag not found."
 
 	// Product related errors
 
@@ -45,6 +46,34 @@ const (
 	CommentNotFoundError       = "Comment not found."
 	// conversation related errors
 
-	UnsupportedFileTypeError = "Unsupported file type error"
-	ImageFileSizeExceedError = "Image file size exceeded"
+	UnsupportedFileTypeError        = "Unsupported file type error"
+	ImageFileSizeExceedError        = "Image file size exceeded"
+	VideoFileSizeExceedError        = "Video file size exceeded."
+	VideoDurationExceedError        = "Video duration exceeded."
+	VideoDurationUnknownError       = "Unsupported video format, only mp4 and mov are accepted."
+	InvalidMediaFileError           = "Invalid media file."
+	ImageDimensionExceedError       = "Image dimensions exceeded."
+	NotConversationParticipantError = "You are not a participant of the conversation."
+	UserBlockedError                = "You can not send messages to this user."
+	CannotBlockSelfError            = "You can not block yourself."
+	ContentRejectedError            = "Message contains prohibited content."
+	ReportNotFoundError             = "Report not found."
+	MessageNotFoundError            = "Message not found."
+	NotMessageSenderError           = "You are not the sender of the message."
+	MessageRecallExpiredError       = "Message can no longer be recalled."
+	MessageEditExpiredError         = "Message can no longer be edited."
+	MessageNotEditableError         = "Message can not be edited."
+
+	// Search related errors
+
+	InvalidSortFieldError       = "Unsupported sort field."
+	InvalidSearchFilterError    = "Invalid search filter."
+	NearbyLocationRequiredError = "Nearby search requires a location or a default address."
+	SavedSearchLimitError       = "Saved search limit reached."
+	SavedSearchNotFoundError    = "Saved search not found."
+	EmptyKeywordError           = "Keyword is empty."
+
+	// Notification related errors
+
+	UnknownNotificationTypeError = "Unknown notification type."
 )
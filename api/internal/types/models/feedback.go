@@ -2,13 +2,35 @@ package models
 
 import "time"
 
+// Feedback 用户对商品的行为，用于兜底推荐的分类偏好和推荐效果分析
 type Feedback struct {
-	ID        string    `gorm:"column:id;type:varchar(36);primary_key" json:"id"`
-	UserID    string    `gorm:"column:user_id;varchar(36)" json:"userID"`
-	ItemID    string    `gorm:"column:item_id;varchar(36)" json:"itemID"`
-	Timestamp time.Time `json:"timestamp"`
+	ID           string    `gorm:"column:id;type:varchar(36);primary_key" json:"id"`
+	UserID       string    `gorm:"column:user_id;varchar(36);index" json:"userID"`
+	ItemID       string    `gorm:"column:item_id;varchar(36);index" json:"itemID"`
+	FeedbackType string    `gorm:"column:feedback_type;type:varchar(20);not null;default:''" json:"feedbackType"`
+	Source       string    `gorm:"column:source;type:varchar(20);not null;default:''" json:"source"` // 点击来源，如feed、search
+	Position     int       `gorm:"column:position;type:int;not null;default:0" json:"position"`      // 点击时在列表中的位置，从0开始
+	Dwell        int       `gorm:"column:dwell;type:int;not null;default:0" json:"dwell"`            // 浏览停留时长，毫秒
+	Experiment   string    `gorm:"column:experiment;type:varchar(50);not null;default:''" json:"-"`  // 记录时用户所在的首页推荐实验
+	Bucket       string    `gorm:"column:bucket;type:varchar(50);not null;default:''" json:"-"`      // 实验分组
+	Timestamp    time.Time `json:"timestamp"`
 }
 
 func (Feedback) TableName() string {
 	return "feedback"
 }
+
+// FeedImpression 首页推荐流展示给用户的商品，用于按实验分组统计点击率
+type FeedImpression struct {
+	ID         uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID     string    `gorm:"column:user_id;type:varchar(36);not null" json:"userID"`
+	ProductID  string    `gorm:"column:product_id;type:varchar(36);not null" json:"productID"`
+	Experiment string    `gorm:"column:experiment;type:varchar(50);not null;index:idx_impression_bucket" json:"experiment"`
+	Bucket     string    `gorm:"column:bucket;type:varchar(50);not null;index:idx_impression_bucket" json:"bucket"`
+	Position   int       `gorm:"column:position;type:int;not null" json:"position"` // 在推荐流中的位置，从0开始
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+}
+
+func (FeedImpression) TableName() string {
+	return "feed_impression"
+}
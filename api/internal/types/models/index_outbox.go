@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IndexOutbox 待同步到es的索引事件，失败的由定时任务按退避重投；
+// 处理成功的保留一段时间，供重建索引时重放重建期间的变更
+type IndexOutbox struct {
+	ID        uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EventType string    `gorm:"column:event_type;type:varchar(30);not null" json:"eventType"`
+	TargetID  string    `gorm:"column:target_id;type:varchar(50);not null" json:"targetID"`
+	Attempts  int       `gorm:"column:attempts;default:0" json:"attempts"`
+	LastError string    `gorm:"column:last_error;type:varchar(500)" json:"lastError"`
+	NextRunAt time.Time `gorm:"column:next_run_at;index" json:"nextRunAt"`
+	// ProcessedAt 处理成功的时间，为空表示未完成
+	ProcessedAt *time.Time `gorm:"column:processed_at;index" json:"processedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func (IndexOutbox) TableName() string {
+	return "index_outbox"
+}
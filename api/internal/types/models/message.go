@@ -12,24 +12,86 @@ type Message struct {
 	FromUserID     string    `gorm:"column:from_userd_id;index;type:varchar(36)" json:"from_user_id"`
 	ToUserID       string    `gorm:"column:to_userd_id;index;type:varchar(36)" json:"to_user_id"`
 	Content        string    `gorm:"type:text" json:"content"`
-	MediaType      string    `gorm:"type:varchar(20)" json:"media_type"` // text/image/link
+	MediaType      string    `gorm:"type:varchar(20)" json:"media_type"` // text/image/video/product/order
 	CreatedAt      time.Time `gorm:"autoCreateTime" json:"-"`
 	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"-"`
 	Timestamp      time.Time `gorm:"timestamp;index" json:"timestamp"`
+	IsRecalled     bool      `gorm:"column:is_recalled;default:false" json:"is_recalled"` // 是否已撤回
+	IsEdited       bool      `gorm:"column:is_edited;default:false" json:"is_edited"`     // 是否被编辑过
 }
 
 func (Message) TableName() string {
 	return "message"
 }
 
+func (m Message) Exists() bool {
+	return len(m.ID) > 0
+}
+
+const (
+	ReportStatusPending  = "pending"
+	ReportStatusResolved = "resolved"
+	ReportStatusRejected = "rejected"
+)
+
+// MessageReport 用户对消息的举报，等待管理员审核
+type MessageReport struct {
+	ID             int       `gorm:"column:id;type:bigint;primary_key;auto_increment" json:"id"`
+	MessageID      string    `gorm:"column:message_id;type:varchar(50);not null;index" json:"messageID"`
+	ReporterID     string    `gorm:"column:reporter_id;type:varchar(36);not null" json:"reporterID"`
+	ReportedUserID string    `gorm:"column:reported_user_id;type:varchar(36);not null;index" json:"reportedUserID"`
+	Reason         string    `gorm:"column:reason;type:varchar(255);not null" json:"reason"`
+	Content        string    `gorm:"column:content;type:text" json:"content"` // 举报时的消息内容快照
+	Status         string    `gorm:"column:status;type:varchar(20);not null;index" json:"status"`
+	Remark         string    `gorm:"column:remark;type:varchar(255)" json:"remark"` // 管理员处理备注
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (MessageReport) TableName() string {
+	return "message_report"
+}
+
+// ProductCard 商品卡片消息内容，为发送时刻的商品快照
+type ProductCard struct {
+	ProductID string  `json:"productID"`
+	Describe  string  `json:"describe"`
+	Price     float64 `json:"price"`
+	Pic       string  `json:"pic"`
+	IsSold    bool    `json:"isSold"`
+	IsSelling bool    `json:"isSelling"`
+}
+
+// OrderCard 订单卡片消息内容，为发送时刻的订单快照
+type OrderCard struct {
+	OrderID     string  `json:"orderID"`
+	ProductID   string  `json:"productID"`
+	Describe    string  `json:"describe"`
+	Pic         string  `json:"pic"`
+	Status      int     `json:"status"`
+	TotalAmount float64 `json:"totalAmount"`
+}
+
+// MessageDeletion 记录用户"仅对自己删除"的消息
+type MessageDeletion struct {
+	MessageID string    `gorm:"column:message_id;primaryKey;type:varchar(50)" json:"messageID"`
+	UserID    string    `gorm:"column:user_id;primaryKey;type:varchar(36)" json:"userID"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+func (MessageDeletion) TableName() string {
+	return "message_deletion"
+}
+
 type Conversation struct {
 	gorm.Model         `json:"-"`
 	FromUserID         string    `gorm:"type:varchar(36);index:from_to_user_id" json:"fromUserID"`
 	ToUserID           string    `gorm:"type:varchar(36);index:from_to_user_id" json:"toUserID"`
 	LastMessageContent string    `gorm:"column:last_message_content;type:varchar(200)" json:"lastMessageContent"`
 	LastMessageTime    time.Time `gorm:"column:last_message_time;index" json:"lastMessageTime"`
-	LastReadMessageID  string    `gorm:"column:last_read_message_id;type:varchar(36)" json:"lastReadMessageID"` // 记录离线前的最后一条消息
+	LastReadMessageID  string    `gorm:"column:last_read_message_id;type:varchar(36)" json:"lastReadMessageID"` // 已读游标，记录用户在该会话中已读的最后一条消息
 	MarkDeleted        bool      `gorm:"column:mark_deleted;default:false" json:"-"`
+	ProductID          string    `gorm:"column:product_id;type:varchar(36)" json:"productID"` // 发起会话时关联的商品
 }
 
 func (Conversation) TableName() string {
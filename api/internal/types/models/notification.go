@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type Notification struct {
+	ID        int64     `gorm:"column:id;type:bigint;primary_key;auto_increment" json:"id"`
+	UserID    string    `gorm:"column:user_id;type:varchar(36);not null;index:idx_user_read,priority:1" json:"userID"`
+	Type      string    `gorm:"column:type;type:varchar(32);not null" json:"type"`
+	Title     string    `gorm:"column:title;type:varchar(64);not null" json:"title"`
+	Content   string    `gorm:"column:content;type:varchar(255);not null" json:"content"`
+	RelatedID string    `gorm:"column:related_id;type:varchar(36)" json:"relatedID"` // 关联的订单或商品id
+	IsRead    bool      `gorm:"column:is_read;type:bool;not null;default:false;index:idx_user_read,priority:2" json:"isRead"`
+	CreatedAt time.Time `gorm:"column:created_at;type:datetime;not null" json:"createdAt"`
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
+
+// NotificationPreference 用户对某类通知的接收设置，没有记录时使用默认值
+type NotificationPreference struct {
+	UserID string `gorm:"column:user_id;type:varchar(36);not null;primary_key" json:"-"`
+	Type   string `gorm:"column:type;type:varchar(32);not null;primary_key" json:"type"`
+	InApp  bool   `gorm:"column:in_app;type:bool;not null" json:"inApp"` // 站内信及WebSocket实时推送
+	Push   bool   `gorm:"column:push;type:bool;not null" json:"push"`    // 短信/邮件/推送等站外渠道
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preference"
+}
+
+func (p NotificationPreference) Exists() bool {
+	return len(p.UserID) > 0
+}
@@ -26,6 +26,8 @@ type Product struct {
 	IsPublished bool `gorm:"column:is_published;type:bool;default:false;" json:"isPublished"` // 是否通过审核
 	IsSold      bool `gorm:"column:is_sold;type:bool;default:false;" json:"isSold"`           // 是否售出
 	IsSelling   bool `gorm:"column:is_selling;type:bool;default:true;" json:"isSelling"`      // 是否下架
+
+	ViewCount int64 `gorm:"column:view_count;default:0" json:"viewCount"`
 }
 
 func (Product) TableName() string {
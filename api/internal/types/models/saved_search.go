@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	SavedSearchInstant = "instant" // 匹配后立即提醒
+	SavedSearchDaily   = "daily"   // 每天汇总一次
+
+	SearchAlertNew       = "new"        // 新发布的商品
+	SearchAlertPriceDrop = "price_drop" // 商品降价后满足条件
+)
+
+// SavedSearch 用户保存的搜索条件，Query为SearchProductReq的json
+type SavedSearch struct {
+	ID           uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID       string    `gorm:"column:user_id;type:varchar(36);not null;index" json:"userID"`
+	Name         string    `gorm:"column:name;type:varchar(50);not null" json:"name"`
+	Query        string    `gorm:"column:query;type:text;not null" json:"query"`
+	Frequency    string    `gorm:"column:frequency;type:varchar(10);not null;default:instant" json:"frequency"`
+	LastDigestAt time.Time `gorm:"column:last_digest_at" json:"lastDigestAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (SavedSearch) TableName() string {
+	return "saved_search"
+}
+
+func (s SavedSearch) Exists() bool {
+	return s.ID > 0
+}
+
+// SearchAlert 商品命中保存的搜索时产生的提醒，记录命中时的商品标题和价格
+type SearchAlert struct {
+	ID            uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID        string     `gorm:"column:user_id;type:varchar(36);not null;index" json:"userID"`
+	SavedSearchID uint       `gorm:"column:saved_search_id;not null;uniqueIndex:idx_alert" json:"savedSearchID"`
+	ProductID     string     `gorm:"column:product_id;type:varchar(36);not null;uniqueIndex:idx_alert" json:"productID"`
+	Reason        string     `gorm:"column:reason;type:varchar(20);not null;uniqueIndex:idx_alert" json:"reason"`
+	Price         float64    `gorm:"column:price;type:decimal(10,2);not null;uniqueIndex:idx_alert" json:"price"`
+	Describe      string     `gorm:"column:describe;type:varchar(255);not null" json:"describe"`
+	Pics          string     `gorm:"column:pics;type:varchar(500);not null" json:"pics"`
+	IsRead        bool       `gorm:"column:is_read;default:false" json:"isRead"`
+	DeliveredAt   *time.Time `gorm:"column:delivered_at;index" json:"-"` // 为空表示等待汇总推送
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+func (SearchAlert) TableName() string {
+	return "search_alert"
+}
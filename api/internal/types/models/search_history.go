@@ -1,10 +1,13 @@
 package models
 
+// SearchHistory 每个用户的每个关键词只有一行，Normalized为归一化后的关键词
 type SearchHistory struct {
 	ID         int    `gorm:"column:id;type:bigint;primary_key;auto_increment" json:"-"`
-	UserID     string `gorm:"column:user_id;type:varchar(36);not null;index:idx_user_id"`
-	Keyword    string `gorm:"column:keyword;type:varchar(255);not null"`
-	SearchTime int64  `gorm:"column:search_time;type:bigint;not null"`
+	UserID     string `gorm:"column:user_id;type:varchar(36);not null;uniqueIndex:idx_user_keyword"`
+	Keyword    string `gorm:"column:keyword;type:varchar(255);not null"` // 最近一次输入的原文
+	Normalized string `gorm:"column:normalized;type:varchar(255);not null;uniqueIndex:idx_user_keyword" json:"-"`
+	HitCount   int64  `gorm:"column:hit_count;type:bigint;not null;default:1"`
+	SearchTime int64  `gorm:"column:search_time;type:bigint;not null;index:idx_search_time"` // 最近一次搜索时间
 }
 
 func (SearchHistory) TableName() string {
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type User struct {
 	Model
 
@@ -10,6 +12,8 @@ type User struct {
 	Avatar       string `gorm:"column:avatar;type:varchar(100)" json:"avatar"`
 	Salt         string `gorm:"column:salt;type:varchar(100)" json:"-"`
 	SelectedTags bool   `gorm:"column:selected_tags;" json:"selected_tags"`
+
+	RecordSearchHistory bool `gorm:"column:record_search_history;default:true" json:"recordSearchHistory"` // 关闭后不再记录搜索历史
 }
 
 func (User) TableName() string {
@@ -19,3 +23,14 @@ func (User) TableName() string {
 func (u User) Exists() bool {
 	return len(u.ID) > 0
 }
+
+// UserBlock 用户拉黑关系，被拉黑的用户无法向UserID发起会话或发送消息
+type UserBlock struct {
+	UserID        string    `gorm:"column:user_id;type:varchar(36);primaryKey" json:"userID"`
+	BlockedUserID string    `gorm:"column:blocked_user_id;type:varchar(36);primaryKey" json:"blockedUserID"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (UserBlock) TableName() string {
+	return "user_block"
+}
@@ -52,3 +52,19 @@ type UpdateInterestTagReq struct {
 type DeleteInterestTagReq struct {
 	TagID int `form:"tagID" json:"tagID" binding:"required"`
 }
+
+type GetMessageReportsReq struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending resolved rejected"`
+	PageReq
+}
+
+type ReviewMessageReportReq struct {
+	ReportID int    `form:"reportID" json:"reportID" binding:"required"`
+	Status   string `form:"status" json:"status" binding:"required,oneof=resolved rejected"`
+	Remark   string `form:"remark" json:"remark" binding:"omitempty,max=255"`
+}
+
+type GetExperimentMetricsReq struct {
+	Experiment string `form:"experiment"`                            // 默认为当前实验
+	Days       int    `form:"days" binding:"omitempty,gte=1,lte=90"` // 统计最近几天，默认7天
+}
@@ -1,6 +1,9 @@
 package request
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+	"time"
+)
 
 type ConversationIDReq struct {
 	ConversationID string `form:"conversationID" uri:"conversationID"`
@@ -12,7 +15,7 @@ type Message struct {
 	From      string `json:"from"`
 	To        string `json:"to"`
 	Content   string `json:"content"`
-	MediaType string `json:"mediaType"` // text/image/link/video
+	MediaType string `json:"mediaType"` // text/image/video/product/order, product/order的content为对应id
 	Type      uint   `json:"type"`      // message/ack
 }
 
@@ -24,7 +27,7 @@ type UploadMediaFileReq struct {
 type CreateConversationReq struct {
 	FromUserID string `form:"fromUserID" binding:"required"`
 	ToUserID   string `form:"toUserID" binding:"required"`
-	ProductID  string `form:"productID" binding:"required"` // 弃用
+	ProductID  string `form:"productID"` // 从商品页发起会话时关联的商品
 }
 
 type GetConversationListReq struct {
@@ -34,5 +37,38 @@ type GetConversationListReq struct {
 type GetMessagesReq struct {
 	FromUserID string `form:"fromUserID" binding:"required"`
 	ToUserID   string `form:"toUserID" binding:"required"`
+	Before     string `form:"before"` // 加载早于该消息id的消息
+	After      string `form:"after"`  // 加载晚于该消息id的消息
+	Around     string `form:"around"` // 跳转到该消息，并返回前后的消息
 	PageReq
 }
+
+type SearchMessagesReq struct {
+	Keyword string `form:"keyword" binding:"required"`
+	PeerID  string `form:"peerID"` // 为空时搜索所有会话
+	PageReq
+}
+
+// MessageDocument 消息索引中的文档
+type MessageDocument struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	FromUserID     string    `json:"from_user_id"`
+	Participants   []string  `json:"participants"`
+	DeletedFor     []string  `json:"deleted_for"`
+	Content        string    `json:"content"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+type MessageIDReq struct {
+	MessageID string `uri:"messageID" binding:"required"`
+}
+
+type DeleteMessageReq struct {
+	MessageIDReq
+}
+
+type ReportMessageReq struct {
+	MessageIDReq
+	Reason string `form:"reason" json:"reason" binding:"required,max=255"`
+}
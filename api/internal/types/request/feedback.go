@@ -0,0 +1,11 @@
+package request
+
+// RecordFeedbackReq 客户端上报的用户行为
+type RecordFeedbackReq struct {
+	Type      string `json:"type" binding:"required,oneof=view click share"`
+	ProductID string `json:"productID" binding:"required"`
+	Source    string `json:"source" binding:"omitempty,oneof=feed search similar seller also_viewed"` // 点击来源
+	Position  int    `json:"position" binding:"gte=0"`                                                // 点击时在列表中的位置
+	Dwell     int    `json:"dwell" binding:"omitempty,gte=0"`                                         // 浏览停留时长，毫秒，立即离开时为0
+	UserID    string
+}
@@ -0,0 +1,17 @@
+package request
+
+type GetNotificationsReq struct {
+	UnreadOnly bool   `form:"unread"`
+	Type       string `form:"type"`
+	PageReq
+}
+
+type MarkNotificationsReadReq struct {
+	IDs []int64 `form:"ids" json:"ids"` // 为空时全部标记为已读
+}
+
+type UpdateNotificationPreferenceReq struct {
+	Type  string `form:"type" json:"type" binding:"required"` // notify.Types中的类型，由service校验
+	InApp *bool  `form:"inApp" json:"inApp" binding:"required"`
+	Push  *bool  `form:"push" json:"push" binding:"required"`
+}
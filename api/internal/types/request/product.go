@@ -26,24 +26,70 @@ type CreateProductReq struct {
 	AttributesJson string `form:"attributes" binding:"required"`
 }
 
+// ProductDocument 商品在es中的完整文档，列表和搜索只读取es
 type ProductDocument struct {
-	ID         string        `json:"id"`
-	Describe   string        `json:"describe"`
-	Category   []string      `json:"category"`
-	CreatedAt  time.Time     `json:"created_at"`
-	Attributes []AttributeES `json:"attributes"`
-	Price      float64       `json:"price"`
+	ID               string        `json:"id"`
+	Describe         string        `json:"describe"`
+	Category         []string      `json:"category"`
+	CategoryIDs      []uint        `json:"category_ids"`
+	CategoryPath     []uint        `json:"category_path"`
+	CreatedAt        time.Time     `json:"created_at"`
+	Attributes       []AttributeES `json:"attributes"`
+	Price            float64       `json:"price"`
+	OriginalPrice    float64       `json:"original_price"`
+	Pics             string        `json:"pics"`
+	Condition        string        `json:"condition"`
+	UsedTime         string        `json:"used_time"`
+	ShippingMethod   string        `json:"shipping_method"`
+	ShippingPrice    float64       `json:"shipping_price"`
+	CanSelfPickup    bool          `json:"can_self_pickup"`
+	PublishAt        time.Time     `json:"publish_at"`
+	IsPublished      bool          `json:"is_published"`
+	IsSold           bool          `json:"is_sold"`
+	IsSelling        bool          `json:"is_selling"`
+	LikeCount        int64         `json:"like_count"`
+	ViewCount        int64         `json:"view_count"`
+	SellerID         string        `json:"seller_id"`
+	SellerReputation float64       `json:"seller_reputation"`
+	Seller           SellerES      `json:"seller"`
+	Location         string        `json:"location"` // 地址id
+	Address          string        `json:"address"`
+	Province         string        `json:"province"`
+	City             string        `json:"city"`
+	District         string        `json:"district"`
+	Geo              *GeoPoint     `json:"geo,omitempty"`
+	Suggest          []string      `json:"suggest,omitempty"` // 自动补全的输入，仅在售商品有值
+}
+
+// SellerES 卖家摘要和评价统计
+type SellerES struct {
+	ID              string `json:"id"`
+	Username        string `json:"username"`
+	Avatar          string `json:"avatar"`
+	TotalComment    int    `json:"total_comment"`
+	PositiveComment int    `json:"positive_comment"`
+	NegativeComment int    `json:"negative_comment"`
+}
+
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
 }
 
 // AttributeES 定义嵌套 attributes 字段
 type AttributeES struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	AttributeID uint       `json:"attribute_id"`
+	Key         string     `json:"key"`
+	Value       string     `json:"value"`
+	Number      *float64   `json:"number,omitempty"` // NUMBER类型
+	Date        *time.Time `json:"date,omitempty"`   // DATE类型
+	Bool        *bool      `json:"bool,omitempty"`   // BOOLEAN类型
 }
 
 type GetProductReq struct {
 	UserIDReq
 	ProductIDReq
+	Related bool `form:"related"` // 是否返回相似商品、卖家的其他商品和看过的人还看过
 }
 
 type UpdateProductReq struct {
@@ -89,7 +135,15 @@ func (p *PageReq) Fill() {
 }
 
 type GetProductListReq struct {
-	PageReq
+	Page   int    `form:"page" json:"page" binding:"omitempty,gte=1"`
+	Size   int    `form:"size" json:"size" binding:"omitempty,gte=1,lte=50"`
+	Cursor string `form:"cursor" json:"cursor"` // 上一页返回的会话游标，第一页不传
+}
+
+func (r *GetProductListReq) Fill() {
+	page := PageReq{Page: r.Page, Size: r.Size}
+	page.Fill()
+	r.Page, r.Size = page.Page, page.Size
 }
 
 type UpdateProductPriceReq struct {
@@ -1,17 +1,39 @@
 package request
 
 type SearchProductReq struct {
-	Keyword    string            `json:"keyword"`
-	Categories []string          `json:"categories"`
-	Attributes []AttributeFilter `json:"attributes"`
-	Sort       SortOption        `json:"sort"`
-	UserID     string
+	Keyword            string            `json:"keyword"`
+	Categories         []uint            `json:"categories"` // 分类id，包含其所有子分类
+	Attributes         []AttributeFilter `json:"attributes"`
+	MinPrice           *float64          `json:"minPrice" binding:"omitempty,gte=0"`
+	MaxPrice           *float64          `json:"maxPrice" binding:"omitempty,gte=0"`
+	Conditions         []string          `json:"conditions"`      // new/good/excellent/used
+	ShippingMethods    []string          `json:"shippingMethods"` // 发货方式
+	MinReputation      float64           `json:"minReputation" binding:"omitempty,gte=0,lte=100"`
+	PriceInterval      float64           `json:"priceInterval" binding:"omitempty,gt=0"` // 价格分布的区间宽度
+	Sort               SortOption        `json:"sort"`
+	IncludeUnavailable bool              `json:"includeUnavailable"` // 默认只返回在售商品
+	Cities             []string          `json:"cities"`
+	Districts          []string          `json:"districts"`
+	Nearby             *NearbyOption     `json:"nearby"` // 附近搜索，未指定排序时按距离由近到远
+	UserID             string
 	PageReq
 }
 
+// AttributeFilter 按属性的DataType选择过滤方式:
+// STRING/BOOLEAN使用Value，ENUM使用Values，NUMBER/DATE使用Min/Max
 type AttributeFilter struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	AttributeID uint     `json:"attributeID" binding:"required"`
+	Value       string   `json:"value"`
+	Values      []string `json:"values"`
+	Min         string   `json:"min"`
+	Max         string   `json:"max"`
+}
+
+// NearbyOption 未提供坐标时使用用户的默认收货地址
+type NearbyOption struct {
+	Latitude  *float64 `json:"latitude" binding:"omitempty,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,gte=-180,lte=180"`
+	Distance  float64  `json:"distance" binding:"omitempty,gt=0,lte=500"` // 公里
 }
 
 type SortOption struct {
@@ -21,5 +43,46 @@ type SortOption struct {
 
 type GetSearchHistoryReq struct {
 	UserIDReq
-	ShowAll bool `json:"showAll"`
+	ShowAll bool `form:"showAll" json:"showAll"` // 为true时返回保留期内的全部记录
+}
+
+type DeleteSearchHistoryReq struct {
+	Keyword string `form:"keyword" binding:"required"`
+	UserID  string
+}
+
+type UpdateSearchHistorySettingReq struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+	UserID  string
+}
+
+// SuggestReq Keyword为空时只返回搜索历史和热门搜索
+type SuggestReq struct {
+	Keyword string `form:"keyword"`
+	Size    int    `form:"size" binding:"omitempty,gte=1,lte=20"`
+	UserID  string
+}
+
+type CreateSavedSearchReq struct {
+	Name      string           `json:"name" binding:"required,max=50"`
+	Query     SearchProductReq `json:"query"`
+	Frequency string           `json:"frequency" binding:"omitempty,oneof=instant daily"` // 默认instant
+	UserID    string
+}
+
+type SavedSearchIDReq struct {
+	SavedSearchID uint `uri:"savedSearchID" binding:"required"`
+	UserID        string
+}
+
+type GetSearchAlertsReq struct {
+	SavedSearchID uint `form:"savedSearchID"` // 为空时返回所有保存的搜索的提醒
+	UnreadOnly    bool `form:"unreadOnly"`
+	UserID        string
+	PageReq
+}
+
+type MarkSearchAlertsReadReq struct {
+	SavedSearchID uint `json:"savedSearchID"` // 为空时标记全部
+	UserID        string
 }
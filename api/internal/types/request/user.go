@@ -47,3 +47,7 @@ type SelectInterestTagsReq struct {
 	UserIDReq
 	Tags []int `form:"tags" json:"tags"`
 }
+
+type BlockUserReq struct {
+	TargetUserID string `uri:"targetUserID" binding:"required"`
+}
@@ -3,7 +3,14 @@ package response
 import "github.com/mislu/market-api/internal/types/models"
 
 type UploadMediaFileResp struct {
-	Url string `json:"url"`
+	Url           string  `json:"url"`
+	MediaType     string  `json:"mediaType"`
+	ThumbnailUrl  string  `json:"thumbnailUrl,omitempty"`
+	CompressedUrl string  `json:"compressedUrl,omitempty"`
+	PosterUrl     string  `json:"posterUrl,omitempty"`
+	Width         int     `json:"width,omitempty"`
+	Height        int     `json:"height,omitempty"`
+	Duration      float64 `json:"duration,omitempty"` // 视频时长，单位秒
 }
 
 type GetConversationListResp []ConversationWithUnReadCount
@@ -14,8 +21,24 @@ type ConversationWithUnReadCount struct {
 	models.User
 }
 
-
 type GetMessagesResp struct {
-	Messages []models.Message
+	Messages []ChatMessage
+	HasOlder bool `json:"hasOlder"`
+	HasNewer bool `json:"hasNewer"`
+	PageResp
+}
+
+type SearchMessagesResp struct {
+	Messages []ChatMessage `json:"messages"`
+	PageResp
+}
+
+type ChatMessage struct {
+	models.Message
+	Card any `json:"card,omitempty"` // 商品/订单卡片的结构化内容
+}
+
+type GetMessageReportsResp struct {
+	Reports []models.MessageReport `json:"reports"`
 	PageResp
-}
\ No newline at end of file
+}
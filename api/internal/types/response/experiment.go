@@ -0,0 +1,23 @@
+package response
+
+import "time"
+
+type GetExperimentMetricsResp struct {
+	Experiment string                    `json:"experiment"`
+	Since      time.Time                 `json:"since"`
+	Buckets    []ExperimentBucketMetrics `json:"buckets"`
+}
+
+// ExperimentBucketMetrics 实验分组的指标，点击只统计来自推荐流的点击
+type ExperimentBucketMetrics struct {
+	Bucket      string  `json:"bucket"`
+	Strategy    string  `json:"strategy"`
+	Users       int64   `json:"users"`       // 看到推荐流的用户数
+	Impressions int64   `json:"impressions"` // 展示次数
+	Clicks      int64   `json:"clicks"`
+	Likes       int64   `json:"likes"`
+	Purchasers  int64   `json:"purchasers"` // 下单的用户数
+	CTR         float64 `json:"ctr"`        // 点击数 / 展示次数
+	LikeRate    float64 `json:"likeRate"`   // 收藏数 / 展示次数
+	Conversion  float64 `json:"conversion"` // 下单的用户数 / 看到推荐流的用户数
+}
@@ -0,0 +1,15 @@
+package response
+
+import "github.com/mislu/market-api/internal/types/models"
+
+type GetNotificationsResp struct {
+	Notifications []models.Notification `json:"notifications"`
+	PageResp
+}
+
+type GetUnreadNotificationCountResp struct {
+	Total int64            `json:"total"`
+	Types map[string]int64 `json:"types"`
+}
+
+type GetNotificationPreferencesResp []models.NotificationPreference
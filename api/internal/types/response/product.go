@@ -13,7 +13,9 @@ type UploadFileFailure struct {
 
 type GetProductResp struct {
 	UserProduct
-	// TODO comment
+	Similar        []UserProduct `json:"similar,omitempty"`        // 相似商品
+	SellerProducts []UserProduct `json:"sellerProducts,omitempty"` // 卖家的其他在售商品
+	AlsoViewed     []UserProduct `json:"alsoViewed,omitempty"`     // 看过该商品的人还看过
 }
 
 type UserProduct struct {
@@ -23,7 +25,9 @@ type UserProduct struct {
 	Categories     []uint          `json:"categories"`
 	Attributes     map[uint]string `json:"attributes"`
 	IsLiked        bool            `json:"isLiked"`
+	LikeCount      int64           `json:"likeCount"`
 	Address        string          `json:"address"`
+	Distance       *float64        `json:"distance,omitempty"` // 附近搜索时与搜索位置的距离，公里
 }
 
 type GetUserProductsResp struct {
@@ -42,6 +46,7 @@ type PageResp struct {
 
 type GetProductListResp struct {
 	Products []UserProduct `json:"products"`
+	Cursor   string        `json:"cursor"` // 请求下一页时带上，用于跨页去重
 	PageResp
 }
 
@@ -1,12 +1,83 @@
 package response
 
-import "github.com/mislu/market-api/internal/types/models"
+import (
+	"time"
+
+	"github.com/mislu/market-api/internal/types/models"
+	"github.com/mislu/market-api/internal/types/request"
+)
 
 type SearchProductResp struct {
 	Products []UserProduct `json:"products"`
+	Facets   SearchFacets  `json:"facets"`
 	PageResp
 }
 
+// SearchFacets 当前搜索条件下各维度的商品数
+type SearchFacets struct {
+	Categories []*CategoryFacet `json:"categories"`
+	Attributes []AttributeFacet `json:"attributes"`
+	Price      []PriceBucket    `json:"price"`
+	Conditions []FacetBucket    `json:"conditions"`
+	Cities     []FacetBucket    `json:"cities"`
+	Districts  []FacetBucket    `json:"districts"`
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// CategoryFacet 分类树节点，Count包含所有子分类下的商品
+type CategoryFacet struct {
+	ID       uint             `json:"id"`
+	Name     string           `json:"name"`
+	Count    int64            `json:"count"`
+	Children []*CategoryFacet `json:"children"`
+}
+
+type AttributeFacet struct {
+	AttributeID uint          `json:"attributeID"`
+	Name        string        `json:"name"`
+	DataType    string        `json:"dataType"`
+	Unit        string        `json:"unit"`
+	Values      []FacetBucket `json:"values"`
+}
+
+// PriceBucket 价格区间[From, To)
+type PriceBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int64   `json:"count"`
+}
+
 type SearchHistoryResp struct {
 	History []models.SearchHistory `json:"history"`
-}
\ No newline at end of file
+	Enabled bool                   `json:"enabled"` // 是否记录搜索历史
+}
+
+// SuggestResp 搜索框的输入提示
+type SuggestResp struct {
+	Completions []string `json:"completions"`          // 在售商品标题和分类名的补全，支持拼音和首字母
+	History     []string `json:"history"`              // 当前用户搜索过的关键词
+	Trending    []string `json:"trending"`             // 全站近期热门搜索
+	DidYouMean  string   `json:"didYouMean,omitempty"` // 疑似输错时的纠正
+}
+
+type SavedSearchResp struct {
+	ID           uint                     `json:"id"`
+	Name         string                   `json:"name"`
+	Query        request.SearchProductReq `json:"query"`
+	Frequency    string                   `json:"frequency"`
+	UnreadAlerts int64                    `json:"unreadAlerts"`
+	CreatedAt    time.Time                `json:"createdAt"`
+}
+
+type GetSavedSearchesResp struct {
+	SavedSearches []SavedSearchResp `json:"savedSearches"`
+}
+
+type GetSearchAlertsResp struct {
+	Alerts []models.SearchAlert `json:"alerts"`
+	PageResp
+}
@@ -18,3 +18,5 @@ type GetUserInfoResp struct {
 	models.Credit
 	// TODO 添加商品信息
 }
+
+type GetBlockedUsersResp []models.User
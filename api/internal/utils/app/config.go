@@ -30,9 +30,56 @@ type Config struct {
 	} `mapstructure:"oss"`
 
 	ES struct {
+		Engine    string   `mapstructure:"engine"` // elasticsearch|embedded，默认elasticsearch
 		Addresses []string `mapstructure:"addresses"`
+		Username  string   `mapstructure:"username"`
+		Password  string   `mapstructure:"password"`
+		Pinyin    bool     `mapstructure:"pinyin"` // 开启拼音搜索和补全，需要es安装analysis-pinyin插件
+		Indices   struct {
+			Product string `mapstructure:"product"` // 商品索引别名
+			Message string `mapstructure:"message"` // 消息索引别名
+		} `mapstructure:"indices"`
+		Embedded struct {
+			Path          string `mapstructure:"path"`           // 快照文件，为空时只保存在内存中
+			FlushInterval int    `mapstructure:"flush_interval"` // 保存快照的间隔，秒
+		} `mapstructure:"embedded"`
 	} `mapstructure:"es"`
 
+	Search struct {
+		HistoryRetentionDays int `mapstructure:"history_retention_days"` // 搜索历史保留天数
+		HistoryLimit         int `mapstructure:"history_limit"`          // 每个用户最多保留的关键词数
+		TrendingMinUsers     int `mapstructure:"trending_min_users"`     // 至少多少个用户搜索过才会出现在热门搜索中
+	} `mapstructure:"search"`
+
+	// Ranking 搜索排序中各信号的权重，未配置的使用默认值，配置为0表示关闭
+	Ranking struct {
+		Freshness      *float64 `mapstructure:"freshness"`
+		FreshnessScale string   `mapstructure:"freshness_scale"`
+		Reputation     *float64 `mapstructure:"reputation"`
+		Likes          *float64 `mapstructure:"likes"`
+		Views          *float64 `mapstructure:"views"`
+		Discount       *float64 `mapstructure:"discount"`
+		Distance       *float64 `mapstructure:"distance"`
+	} `mapstructure:"ranking"`
+
+	// Experiment 首页推荐的A/B实验，未配置分组时所有用户使用推荐服务
+	Experiment struct {
+		Feed struct {
+			Name    string `mapstructure:"name"`
+			Buckets []struct {
+				Name     string `mapstructure:"name"`
+				Weight   int    `mapstructure:"weight"`
+				Strategy string `mapstructure:"strategy"` // recommend|local|latest
+			} `mapstructure:"buckets"`
+		} `mapstructure:"feed"`
+	} `mapstructure:"experiment"`
+
+	Indexer struct {
+		RelayInterval      int `mapstructure:"relay_interval"`       // 重投未完成索引事件的间隔，秒
+		DriftCheckInterval int `mapstructure:"drift_check_interval"` // MySQL与es一致性检查的间隔，秒
+		OutboxRetention    int `mapstructure:"outbox_retention"`     // 已处理的索引事件保留时长，小时，需长于一次重建索引的耗时
+	} `mapstructure:"indexer"`
+
 	Alipay struct {
 		APPID string `mapstructure:"app_id"`
 	} `mapstructure:"alipay"`
@@ -46,11 +93,23 @@ type Config struct {
 				Size int `mapstructure:"size"`
 			} `mapstructure:"memory"`
 		} `mapstructure:"mq"`
+		// Breaker 推荐服务的熔断配置，连续失败Threshold次后Cooldown秒内使用兜底推荐
+		Breaker struct {
+			Threshold int `mapstructure:"threshold"`
+			Cooldown  int `mapstructure:"cooldown"`
+			Timeout   int `mapstructure:"timeout"` // 毫秒
+		} `mapstructure:"breaker"`
 	} `mapstructure:"gorse"`
 
 	Rabbit struct {
 		Url string `mapstructure:"url"`
 	}
+
+	Moderation struct {
+		Keywords     []string `mapstructure:"keywords"`
+		Patterns     []string `mapstructure:"patterns"`
+		AllowContact bool     `mapstructure:"allow_contact"` // 是否允许在聊天中发送联系方式
+	} `mapstructure:"moderation"`
 }
 
 var config *Config